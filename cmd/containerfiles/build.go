@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+	"github.com/cheesesashimi/zacks-container-playground/internal/containerfile"
+)
+
+// Builds the given Containerfile, or spec, printing each step as it runs
+// followed by the ID of the built image.
+func buildCmd(args []string) error {
+	fs := flag.NewFlagSet("build", flag.ContinueOnError)
+	builder := fs.String("builder", "podman", "The builder to use: podman or buildah.")
+	buildContext := fs.String("context", "", "The build context directory. Defaults to an empty directory.")
+	tag := fs.String("t", "", "The tag for the built image. Defaults to the Containerfile's tag.")
+	verbose := fs.Bool("v", false, "Stream the builder's output instead of only its steps.")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected a single file to build, got %d", fs.NArg())
+	}
+
+	cf, err := loadContainerfile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	opts := containerfile.BuildOpts{
+		Builder: containerfile.Builder(*builder),
+		Build:   command.BuildOpts{BuildContext: *buildContext, Tag: *tag},
+	}
+
+	if *verbose {
+		opts.Out = os.Stderr
+	} else {
+		opts.OnEvent = func(event containerfile.BuildEvent) {
+			if event.Kind == containerfile.EventStep || event.Kind == containerfile.EventCacheHit {
+				fmt.Fprintln(os.Stderr, event.Line)
+			}
+		}
+	}
+
+	result, err := containerfile.Build(ctx, cf, opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(result.Digest)
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/buildcontext"
+)
+
+// Lists the files within a build context which the given Containerfile, or
+// spec, copies, or generates an ignore file which excludes everything else.
+func contextCmd(args []string) error {
+	fs := flag.NewFlagSet("context", flag.ContinueOnError)
+	dir := fs.String("dir", ".", "The build context directory.")
+	hash := fs.Bool("hash", false, "Print a content hash of the copied files instead of listing them.")
+	generate := fs.Bool("generate", false, "Print a .containerignore which excludes everything that is not copied.")
+	write := fs.Bool("w", false, "With -generate, write the .containerignore into the build context instead.")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected a single Containerfile, got %d", fs.NArg())
+	}
+
+	cf, err := loadContainerfile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *generate {
+		ig, err := buildcontext.IgnoreFor(cf)
+		if err != nil {
+			return err
+		}
+
+		if *write {
+			return ig.WriteFile(*dir)
+		}
+
+		fmt.Print(ig.String())
+		return nil
+	}
+
+	bctx, err := buildcontext.Open(*dir)
+	if err != nil {
+		return err
+	}
+
+	fileSet, err := bctx.Files(cf)
+	if err != nil {
+		return err
+	}
+
+	for _, warning := range fileSet.Warnings {
+		fmt.Fprintln(os.Stderr, "warning:", warning)
+	}
+
+	if *hash {
+		out, err := fileSet.Hash()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(out)
+		return nil
+	}
+
+	for _, file := range fileSet.Files {
+		fmt.Println(file)
+	}
+
+	return nil
+}
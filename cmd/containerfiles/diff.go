@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/containerfile"
+	"github.com/cheesesashimi/zacks-container-playground/internal/spec"
+)
+
+// Prints the structural changes between two Containerfiles, each of which may
+// be given either as a Containerfile or as a YAML or JSON spec.
+func diffCmd(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "Print the changes as JSON.")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		return fmt.Errorf("expected two files to compare, got %d", fs.NArg())
+	}
+
+	before, err := loadContainerfile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	after, err := loadContainerfile(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	d := containerfile.Compare(before, after)
+
+	if *asJSON {
+		out, err := d.JSON()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(out))
+		return nil
+	}
+
+	if d.Empty() {
+		fmt.Println("no changes")
+		return nil
+	}
+
+	fmt.Print(d.String())
+
+	return nil
+}
+
+// Parses the given file as a spec if it has a YAML or JSON extension and as a
+// Containerfile otherwise.
+func loadContainerfile(file string) (*containerfile.Containerfile, error) {
+	switch filepath.Ext(file) {
+	case ".yaml", ".yml", ".json":
+		s, err := spec.ParseFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		cf, err := s.Containerfile()
+		if err != nil {
+			return nil, fmt.Errorf("could not convert %s: %w", file, err)
+		}
+
+		return cf, nil
+	}
+
+	return containerfile.ParseFile(file)
+}
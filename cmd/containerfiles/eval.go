@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+	"github.com/cheesesashimi/zacks-container-playground/internal/containerfile"
+)
+
+// Prints the given Containerfile, or spec, with the values of its ARG and ENV
+// variables substituted, and reports references to undefined variables.
+func evalCmd(args []string) error {
+	fs := flag.NewFlagSet("eval", flag.ContinueOnError)
+	buildArgs := stringSliceFlag{}
+	fs.Var(&buildArgs, "build-arg", "A NAME=VALUE build arg. May be given multiple times.")
+	env := stringSliceFlag{}
+	fs.Var(&env, "env", "A NAME=VALUE variable set by the base image, e.g., PATH. May be given multiple times.")
+	quiet := fs.Bool("q", false, "Only report undefined variables.")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected a single file to evaluate, got %d", fs.NArg())
+	}
+
+	cf, err := loadContainerfile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	opts := containerfile.EvalOpts{Env: map[string]string{}}
+
+	for _, arg := range buildArgs {
+		name, val, ok := strings.Cut(arg, "=")
+		if !ok {
+			return fmt.Errorf("expected NAME=VALUE, got %q", arg)
+		}
+
+		opts.BuildArgs = append(opts.BuildArgs, command.BuildArg{Name: name, Value: val})
+	}
+
+	for _, arg := range env {
+		name, val, ok := strings.Cut(arg, "=")
+		if !ok {
+			return fmt.Errorf("expected NAME=VALUE, got %q", arg)
+		}
+
+		opts.Env[name] = val
+	}
+
+	ev, err := cf.Evaluate(opts)
+	if err != nil {
+		return err
+	}
+
+	if !*quiet {
+		fmt.Print(ev.Containerfile.String())
+	}
+
+	for _, undefined := range ev.Undefined {
+		fmt.Fprintln(os.Stderr, undefined)
+	}
+
+	if len(ev.Undefined) != 0 {
+		return fmt.Errorf("%d undefined variable reference(s)", len(ev.Undefined))
+	}
+
+	return nil
+}
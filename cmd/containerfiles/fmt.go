@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/containerfile"
+)
+
+// Reformats the given Containerfiles, or stdin if none are given, much like
+// gofmt does for Go source.
+func fmtCmd(args []string) error {
+	fs := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	write := fs.Bool("w", false, "Write the result to each file instead of stdout.")
+	list := fs.Bool("l", false, "List the files whose formatting differs instead of printing them.")
+	comments := fs.Bool("comments", false, "Precede each stage with a comment describing it.")
+	width := fs.Int("width", 80, "The column past which lines are wrapped.")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opts := containerfile.FormatOpts{Width: *width, Comments: *comments}
+
+	if fs.NArg() == 0 {
+		if *write || *list {
+			return fmt.Errorf("-w and -l require files")
+		}
+
+		in, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		out, err := formatContainerfile(in, opts)
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(out)
+		return nil
+	}
+
+	for _, file := range fs.Args() {
+		in, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		out, err := formatContainerfile(in, opts)
+		if err != nil {
+			return fmt.Errorf("could not format %s: %w", file, err)
+		}
+
+		changed := !bytes.Equal(in, []byte(out))
+
+		if *list && changed {
+			fmt.Println(file)
+		}
+
+		if *write && changed {
+			if err := os.WriteFile(file, []byte(out), 0o644); err != nil {
+				return err
+			}
+		}
+
+		if !*list && !*write {
+			fmt.Print(out)
+		}
+	}
+
+	return nil
+}
+
+func formatContainerfile(in []byte, opts containerfile.FormatOpts) (string, error) {
+	cf, err := containerfile.Parse(bytes.NewReader(in))
+	if err != nil {
+		return "", err
+	}
+
+	return cf.Format(opts), nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/lockfile"
+)
+
+// Resolves the digests of the images which the given Containerfile, or spec,
+// pulls and writes them to its lockfile.
+func lockCmd(args []string) error {
+	fs := flag.NewFlagSet("lock", flag.ContinueOnError)
+	lockPath := fs.String("lock", "containerfile.lock", "The lockfile to update.")
+	refresh := fs.Bool("refresh", false, "Resolve every image again rather than only those which are not yet locked.")
+	authfile := fs.String("authfile", "", "The registry auth file passed to skopeo.")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected a single Containerfile, got %d", fs.NArg())
+	}
+
+	cf, err := loadContainerfile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	lock, err := readLockfile(*lockPath)
+	if err != nil {
+		return err
+	}
+
+	opts := lockfile.UpdateOpts{
+		Resolver: &lockfile.SkopeoResolver{Authfile: *authfile},
+		Refresh:  *refresh,
+	}
+
+	updated, changes, err := lockfile.Update(context.Background(), cf, lock, opts)
+	if err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+
+	return updated.WriteFile(*lockPath)
+}
+
+// Prints the given Containerfile, or spec, with each image pinned to the
+// digest in its lockfile.
+func pinCmd(args []string) error {
+	fs := flag.NewFlagSet("pin", flag.ContinueOnError)
+	lockPath := fs.String("lock", "containerfile.lock", "The lockfile to pin images from.")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected a single Containerfile, got %d", fs.NArg())
+	}
+
+	cf, err := loadContainerfile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	lock, err := lockfile.ReadFile(*lockPath)
+	if err != nil {
+		return err
+	}
+
+	pinned, err := lock.Pin(cf)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(pinned.String())
+
+	return nil
+}
+
+// Reads the given lockfile, returning an empty one if it does not exist yet.
+func readLockfile(name string) (*lockfile.Lockfile, error) {
+	lock, err := lockfile.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return lockfile.New(), nil
+	}
+
+	return lock, err
+}
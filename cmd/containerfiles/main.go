@@ -2,20 +2,13 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 
 	"github.com/cheesesashimi/zacks-container-playground/internal/command"
 	"github.com/cheesesashimi/zacks-container-playground/internal/containerfile"
 )
 
-type packageManager string
-
-const (
-	dnf    packageManager = "dnf"
-	yum    packageManager = "yum"
-	aptGet packageManager = "apt-get"
-)
-
 // Holds options applicable for all containerfiles that we generate.
 type otherContainerfileOpts struct {
 	// The base image to pull
@@ -25,7 +18,7 @@ type otherContainerfileOpts struct {
 	// The packages to install
 	packages []string
 	// The package manager to call for installation
-	packageManager packageManager
+	packageManager command.PackageManager
 }
 
 // Generates the containerfile.
@@ -37,7 +30,7 @@ func (o *otherContainerfileOpts) containerfile() containerfile.Containerfile {
 				Image: o.baseImage,
 				Steps: []containerfile.ContainerfileStep{
 					&containerfile.MultiCommandRunStep{
-						Commands: append(o.getPackageCommands(), &command.CommandLiteral{"useradd", o.username}),
+						Commands: append(o.getPackageCommands(), &command.Useradd{Name: o.username}),
 					},
 					containerfile.NewUserStep(o.username),
 				},
@@ -46,52 +39,29 @@ func (o *otherContainerfileOpts) containerfile() containerfile.Containerfile {
 	}
 }
 
-// Gets the appropriate package command(s) given the supplied package manager.
+// Gets the appropriate package command(s) given the supplied package manager,
+// detecting it from the base image if one was not supplied.
 func (o *otherContainerfileOpts) getPackageCommands() []containerfile.Command {
-	if o.packageManager == dnf {
-		return []containerfile.Command{
-			&command.DnfInstall{
-				Yes:      true,
-				Packages: o.packages,
-			},
-		}
-	}
-
-	if o.packageManager == aptGet {
-		return []containerfile.Command{
-			// Needs an update step before we can install packages.
-			&command.AptGetUpdate{},
-			&command.AptGetInstall{
-				Yes:      true,
-				Packages: o.packages,
-			},
+	pm := o.packageManager
+	if pm == nil {
+		detected, ok := command.DetectPackageManager(o.baseImage)
+		if !ok {
+			// Default to dnf if no other option exists.
+			detected = command.Dnf{}
 		}
-	}
 
-	if o.packageManager == yum {
-		return []containerfile.Command{
-			&command.YumInstall{
-				Yes:      true,
-				Packages: o.packages,
-			},
-		}
+		pm = detected
 	}
 
-	// Default to dnf if no other option exists.
-	return []containerfile.Command{
-		&command.DnfInstall{
-			Yes:      true,
-			Packages: o.packages,
-		},
-	}
+	return pm.Install(o.packages)
 }
 
 // Returns the containerfiles that were templatized in the slide deck.
 func generateOtherContainerfiles() []containerfile.Containerfile {
-	items := map[string]packageManager{
-		"registry.fedoraproject.org/fedora:latest": dnf,
-		"quay.io/centos/stream:9":                  yum,
-		"ubuntu:latest":                            aptGet,
+	items := map[string]command.PackageManager{
+		"registry.fedoraproject.org/fedora:latest": command.Dnf{},
+		"quay.io/centos/stream:9":                  command.Yum{},
+		"ubuntu:latest":                            command.AptGet{},
 	}
 
 	out := []containerfile.Containerfile{}
@@ -148,7 +118,8 @@ func generateGolangBuildContainerfile() containerfile.Containerfile {
 	}
 }
 
-func main() {
+// Prints the example Containerfiles.
+func printExamples() {
 	fmt.Println("Golang Containerfile:")
 	cfile := generateGolangBuildContainerfile()
 	fmt.Println(cfile.String())
@@ -157,3 +128,35 @@ func main() {
 		fmt.Println(cfile.String())
 	}
 }
+
+// Each subcommand receives the remaining command-line arguments.
+var subcommands = map[string]func(args []string) error{
+	"build":   buildCmd,
+	"context": contextCmd,
+	"diff":    diffCmd,
+	"eval":    evalCmd,
+	"fmt":     fmtCmd,
+	"lock":    lockCmd,
+	"matrix":  matrix,
+	"pin":     pinCmd,
+	"render":  render,
+	"sbom":    sbomCmd,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		printExamples()
+		return
+	}
+
+	subcommand, ok := subcommands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown subcommand %q\n", os.Args[1])
+		os.Exit(2)
+	}
+
+	if err := subcommand(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
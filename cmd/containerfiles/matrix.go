@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/spec"
+)
+
+// Generates every combination of the given matrix into an output directory.
+func matrix(args []string) error {
+	fs := flag.NewFlagSet("matrix", flag.ContinueOnError)
+	file := fs.String("f", "", "Path to a YAML or JSON matrix.")
+	outDir := fs.String("o", "output", "Directory to write the Containerfiles and index to.")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *file == "" {
+		return fmt.Errorf("a matrix must be given with -f")
+	}
+
+	m, err := spec.ParseMatrixFile(*file)
+	if err != nil {
+		return err
+	}
+
+	variants, err := m.Write(*outDir)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range variants {
+		fmt.Println(filepath.Join(*outDir, v.File))
+	}
+
+	fmt.Println(filepath.Join(*outDir, spec.MatrixIndexFile))
+
+	return nil
+}
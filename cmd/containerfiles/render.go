@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/spec"
+)
+
+// Renders each of the given YAML or JSON specs into a Containerfile on
+// stdout.
+func render(args []string) error {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	files := stringSliceFlag{}
+	fs.Var(&files, "f", "Path to a YAML or JSON spec. May be given multiple times.")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	files = append(files, fs.Args()...)
+
+	if len(files) == 0 {
+		return fmt.Errorf("at least one spec must be given with -f")
+	}
+
+	for i, file := range files {
+		s, err := spec.ParseFile(file)
+		if err != nil {
+			return err
+		}
+
+		cf, err := s.Containerfile()
+		if err != nil {
+			return fmt.Errorf("could not convert %s: %w", file, err)
+		}
+
+		if i != 0 {
+			fmt.Println()
+		}
+
+		fmt.Print(cf.String())
+	}
+
+	return nil
+}
+
+// Allows a flag to be given multiple times.
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return fmt.Sprintf("%v", *s)
+}
+
+func (s *stringSliceFlag) Set(val string) error {
+	*s = append(*s, val)
+	return nil
+}
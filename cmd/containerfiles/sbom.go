@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/sbom"
+)
+
+// Prints the declared software bill of materials for the given Containerfile,
+// or spec.
+func sbomCmd(args []string) error {
+	fs := flag.NewFlagSet("sbom", flag.ContinueOnError)
+	format := fs.String("format", "json", "The output format: json, spdx, or cyclonedx.")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected a single file to analyze, got %d", fs.NArg())
+	}
+
+	cf, err := loadContainerfile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	inv, err := sbom.Analyze(cf)
+	if err != nil {
+		return err
+	}
+
+	var out []byte
+
+	switch *format {
+	case "json":
+		out, err = inv.JSON()
+	case "spdx":
+		out, err = inv.SPDX(sbom.DocumentOpts{})
+	case "cyclonedx":
+		out, err = inv.CycloneDX(sbom.DocumentOpts{})
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(out))
+
+	return nil
+}
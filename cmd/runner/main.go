@@ -3,9 +3,10 @@ package main
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 
 	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+	"github.com/cheesesashimi/zacks-container-playground/internal/kube"
+	"github.com/cheesesashimi/zacks-container-playground/internal/plan"
 )
 
 func podmanrun() {
@@ -56,15 +57,17 @@ func podmanrun() {
 
 func podmanbuild() {
 	pb := &command.PodmanBuild{
-		Tag: "quay.io/zzlotnik/something:latest",
-		Labels: []command.Label{
-			{
-				Name:  "label1",
-				Value: "value1",
-			},
-			{
-				Name:  "label2",
-				Value: "value2",
+		BuildOpts: command.BuildOpts{
+			Tag: "quay.io/zzlotnik/something:latest",
+			Labels: []command.Label{
+				{
+					Name:  "label1",
+					Value: "value1",
+				},
+				{
+					Name:  "label2",
+					Value: "value2",
+				},
 			},
 		},
 	}
@@ -92,15 +95,17 @@ func podmantag() {
 
 func buildahbuild() {
 	b := &command.BuildahBuild{
-		Authfile: "/path/to/authfile",
-		File:     "/path/to/containerfile",
-		Proxy: &command.Proxy{
-			Http:    "http://path.to.proxy",
-			Https:   "https://path.to.https.proxy",
-			NoProxy: "",
+		BuildOpts: command.BuildOpts{
+			Authfile: "/path/to/authfile",
+			File:     "/path/to/containerfile",
+			Proxy: &command.Proxy{
+				Http:    "http://path.to.proxy",
+				Https:   "https://path.to.https.proxy",
+				NoProxy: "",
+			},
+			Tag: "quay.io/zzlotnik/something:latest",
 		},
 		StorageDriver: "vfs",
-		Tag:           "quay.io/zzlotnik/something:latest",
 	}
 
 	fmt.Println(b.Command())
@@ -127,47 +132,35 @@ func emulateBuildahBuildAndPush() {
 
 	mountOpts := "z,rw"
 
-	items := []interface{ Command() *command.Command }{
-		&command.P11KitExtract{
-			Format:    "openssl-bundle",
-			Filter:    "certificates",
-			Overwrite: true,
-			Comment:   true,
-			DestPath:  filepath.Join(destRoot, "/openssl/ca-bundle.trust.crt"),
-		},
-		&command.P11KitExtract{
-			Format:    "pem-bundle",
-			Filter:    "ca-anchors",
-			Overwrite: true,
-			Comment:   true,
-			Purpose:   "server-auth",
-			DestPath:  filepath.Join(destRoot, "/pem/tls-ca-bundle.pem"),
-		},
-		&command.BuildahBuild{
-			Authfile: authfile,
-			LogLevel: "DEBUG",
-			File:     "/path/to/containerfile",
-			Proxy: &command.Proxy{
-				Http:    "http://proxy.host.com",
-				Https:   "https://proxy.host.com",
-				NoProxy: "",
-			},
-			StorageDriver: "vfs",
-			Tag:           image,
-			Volumes: []command.Volume{
-				{
-					HostPath:      "$ETC_PKI_RPM_GPG_MOUNTPOINT",
-					ContainerPath: "$ETC_PKI_RPM_GPG_MOUNTPOINT",
-					Opts:          mountOpts,
+	bp := &plan.BuildahBuildAndPush{
+		CADestRoot: destRoot,
+		Build: &command.BuildahBuild{
+			BuildOpts: command.BuildOpts{
+				Authfile: authfile,
+				File:     "/path/to/containerfile",
+				Proxy: &command.Proxy{
+					Http:    "http://proxy.host.com",
+					Https:   "https://proxy.host.com",
+					NoProxy: "",
 				},
-				{
-					HostPath:      "$ETC_YUM_REPOS_D_MOUNTPOINT",
-					ContainerPath: "$ETC_YUM_REPOS_D_MOUNTPOINT",
-					Opts:          mountOpts,
+				Tag: image,
+				Volumes: []command.Volume{
+					{
+						HostPath:      "$ETC_PKI_RPM_GPG_MOUNTPOINT",
+						ContainerPath: "$ETC_PKI_RPM_GPG_MOUNTPOINT",
+						Opts:          mountOpts,
+					},
+					{
+						HostPath:      "$ETC_YUM_REPOS_D_MOUNTPOINT",
+						ContainerPath: "$ETC_YUM_REPOS_D_MOUNTPOINT",
+						Opts:          mountOpts,
+					},
 				},
 			},
+			LogLevel:      "DEBUG",
+			StorageDriver: "vfs",
 		},
-		&command.BuildahPush{
+		Push: &command.BuildahPush{
 			Authfile:   authfile,
 			LogLevel:   "DEBUG",
 			Image:      image,
@@ -176,9 +169,38 @@ func emulateBuildahBuildAndPush() {
 		},
 	}
 
-	for _, item := range items {
-		fmt.Println(item.Command())
+	if err := bp.Plan().DryRun(os.Stdout); err != nil {
+		panic(err)
 	}
+
+	// Capturing the digest runs Go code, which cannot run within a Pod. The
+	// digestfile is still written by the push step.
+	podPlan := plan.New()
+	for _, step := range bp.Plan().Steps {
+		if step.Action == nil {
+			podPlan.Add(step)
+		}
+	}
+
+	pod, err := kube.PodForPlan(podPlan, kube.Opts{
+		Name:               "buildah-build-and-push",
+		Image:              "quay.io/buildah/stable:latest",
+		ServiceAccountName: "builder",
+		RootlessBuildah:    true,
+		Volumes: []command.Volume{
+			{HostPath: "/path/to", ContainerPath: "/path/to"},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	out, err := kube.Marshal(kube.JobForPod(pod))
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Print(string(out))
 }
 
 func main() {
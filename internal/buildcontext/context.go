@@ -0,0 +1,237 @@
+package buildcontext
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/containerfile"
+)
+
+// A directory which is sent to the builder, along with its ignore file.
+type Context struct {
+	Dir    string
+	Ignore *Ignore
+}
+
+// Opens the build context rooted at the given directory, reading its
+// .containerignore or .dockerignore file.
+func Open(dir string) (*Context, error) {
+	ig, err := ReadIgnore(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Context{Dir: dir, Ignore: ig}, nil
+}
+
+// A problem with a COPY or ADD source.
+type Warning struct {
+	// The stage's name, or "stage N" for unnamed stages.
+	Stage string
+	// The instruction which refers to the source.
+	Line   string
+	Source string
+	Msg    string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s: %s: %s %s", w.Stage, w.Line, w.Source, w.Msg)
+}
+
+// The files within a build context which a Containerfile reads.
+type FileSet struct {
+	Dir string
+	// Slash-separated paths relative to Dir in sorted order. Directories are
+	// omitted while symlinks are included as they are.
+	Files    []string
+	Warnings []Warning
+}
+
+// A file or directory within the build context.
+type entry struct {
+	name     string
+	dir      bool
+	excluded bool
+}
+
+// Computes the exact set of files which the COPY and ADD instructions of the
+// given Containerfile read from the build context, skipping those which are
+// excluded by the ignore file. A warning is given for each source which does
+// not exist or is entirely excluded.
+func (c *Context) Files(cf *containerfile.Containerfile) (*FileSet, error) {
+	entries, err := c.walk()
+	if err != nil {
+		return nil, err
+	}
+
+	fileSet := &FileSet{Dir: c.Dir, Files: []string{}, Warnings: []Warning{}}
+	files := map[string]bool{}
+
+	for _, cp := range cf.ContextCopies() {
+		for _, src := range cp.Sources {
+			warning := Warning{Stage: cp.Stage, Line: cp.Line, Source: src}
+
+			matched, found, err := c.match(entries, src)
+			if err != nil {
+				warning.Msg = err.Error()
+				fileSet.Warnings = append(fileSet.Warnings, warning)
+				continue
+			}
+
+			if !found {
+				warning.Msg = "does not exist in the build context"
+				fileSet.Warnings = append(fileSet.Warnings, warning)
+				continue
+			}
+
+			if len(matched) == 0 {
+				// An empty directory.
+				continue
+			}
+
+			included := 0
+			for _, e := range matched {
+				if !e.excluded {
+					files[e.name] = true
+					included++
+				}
+			}
+
+			if included == 0 {
+				warning.Msg = "is excluded by the ignore file"
+				fileSet.Warnings = append(fileSet.Warnings, warning)
+			}
+		}
+	}
+
+	for name := range files {
+		fileSet.Files = append(fileSet.Files, name)
+	}
+
+	slices.Sort(fileSet.Files)
+
+	return fileSet, nil
+}
+
+// Lists every file and directory within the build context.
+func (c *Context) walk() ([]entry, error) {
+	entries := []entry{}
+
+	err := filepath.WalkDir(c.Dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(c.Dir, p)
+		if err != nil {
+			return err
+		}
+
+		if rel == "." {
+			return nil
+		}
+
+		name := filepath.ToSlash(rel)
+		entries = append(entries, entry{name: name, dir: d.IsDir(), excluded: c.Ignore.Excludes(name)})
+
+		return nil
+	})
+
+	return entries, err
+}
+
+// Returns the files matched by the given source, along with whether it
+// matched anything at all. Directories match each of the files within them.
+func (c *Context) match(entries []entry, src string) ([]entry, bool, error) {
+	src = cleanPath(src)
+
+	if src == "." {
+		return slices.DeleteFunc(slices.Clone(entries), func(e entry) bool { return e.dir }), true, nil
+	}
+
+	re, err := globRegexp(src)
+	if err != nil {
+		return nil, false, fmt.Errorf("is not a valid glob: %w", err)
+	}
+
+	matchedDirs := []string{}
+	out := []entry{}
+
+	for _, e := range entries {
+		if re.MatchString(e.name) {
+			if e.dir {
+				matchedDirs = append(matchedDirs, e.name+"/")
+				continue
+			}
+
+			out = append(out, e)
+			continue
+		}
+
+		if !e.dir && slices.ContainsFunc(matchedDirs, func(dir string) bool { return strings.HasPrefix(e.name, dir) }) {
+			out = append(out, e)
+		}
+	}
+
+	return out, len(out) != 0 || len(matchedDirs) != 0, nil
+}
+
+// Returns a content hash of the file set suitable for use as a cache key. The
+// hash covers the path, permissions, and content of each file, or the target
+// of each symlink, so it changes whenever the result of the COPY and ADD
+// instructions could.
+func (f *FileSet) Hash() (string, error) {
+	h := sha256.New()
+
+	for _, name := range f.Files {
+		p := filepath.Join(f.Dir, filepath.FromSlash(name))
+
+		info, err := os.Lstat(p)
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(h, "%s\x00%o\x00%d\x00", name, info.Mode(), info.Size())
+
+		if info.Mode()&fs.ModeSymlink != 0 {
+			target, err := os.Readlink(p)
+			if err != nil {
+				return "", err
+			}
+
+			fmt.Fprintf(h, "%s\x00", target)
+			continue
+		}
+
+		if err := hashFile(h, p); err != nil {
+			return "", err
+		}
+	}
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(w io.Writer, p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// Reports whether the given path is within the file set.
+func (f *FileSet) Contains(name string) bool {
+	_, found := slices.BinarySearch(f.Files, cleanPath(name))
+	return found
+}
@@ -0,0 +1,134 @@
+package buildcontext
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/containerfile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Creates a build context with the given files, keyed by slash-separated
+// path.
+func newContextDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+
+	return dir
+}
+
+func newSourcesContainerfile(steps ...containerfile.ContainerfileStep) *containerfile.Containerfile {
+	return &containerfile.Containerfile{
+		Stages: []*containerfile.Stage{{Name: "builder", Image: "golang", Steps: steps}},
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := newContextDir(t, map[string]string{
+		".containerignore":    "**/*_test.go\nconfig/secret.yaml\n",
+		"go.mod":              "module example.com/app\n",
+		"go.sum":              "",
+		"README.md":           "# app\n",
+		"cmd/app/main.go":     "package main\n",
+		"cmd/app/app_test.go": "package main\n",
+		"config/app.yaml":     "debug: false\n",
+		"config/secret.yaml":  "token: hunter2\n",
+	})
+
+	bctx, err := Open(dir)
+	require.NoError(t, err)
+
+	cf := newSourcesContainerfile(
+		containerfile.NewRawStep("COPY go.mod go.sum ./"),
+		&containerfile.CopyStep{Src: "cmd", Dest: "/src/cmd"},
+		containerfile.NewRawStep("COPY config/*.yaml /etc/app/"),
+		containerfile.NewRawStep("COPY config/secret.yaml vendor /missing/"),
+		&containerfile.CopyStep{From: "other", Src: "/nope", Dest: "/nope"},
+	)
+
+	fileSet, err := bctx.Files(cf)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"cmd/app/main.go",
+		"config/app.yaml",
+		"go.mod",
+		"go.sum",
+	}, fileSet.Files)
+
+	assert.True(t, fileSet.Contains("/cmd/app/main.go"))
+	assert.False(t, fileSet.Contains("README.md"))
+
+	assert.Equal(t, []Warning{
+		{Stage: "builder", Line: "COPY config/secret.yaml vendor /missing/", Source: "config/secret.yaml", Msg: "is excluded by the ignore file"},
+		{Stage: "builder", Line: "COPY config/secret.yaml vendor /missing/", Source: "vendor", Msg: "does not exist in the build context"},
+	}, fileSet.Warnings)
+
+	assert.Equal(t, "builder: COPY config/secret.yaml vendor /missing/: vendor does not exist in the build context", fileSet.Warnings[1].String())
+}
+
+func TestFilesCopyAll(t *testing.T) {
+	dir := newContextDir(t, map[string]string{
+		".dockerignore": "*.md\n!CHANGELOG.md\n",
+		"main.go":       "package main\n",
+		"README.md":     "# app\n",
+		"CHANGELOG.md":  "# changes\n",
+	})
+
+	bctx, err := Open(dir)
+	require.NoError(t, err)
+
+	fileSet, err := bctx.Files(newSourcesContainerfile(containerfile.CopyAllStep()))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{".dockerignore", "CHANGELOG.md", "main.go"}, fileSet.Files)
+	assert.Empty(t, fileSet.Warnings)
+}
+
+func TestHash(t *testing.T) {
+	dir := newContextDir(t, map[string]string{
+		"main.go":   "package main\n",
+		"README.md": "# app\n",
+	})
+
+	bctx, err := Open(dir)
+	require.NoError(t, err)
+
+	cf := newSourcesContainerfile(&containerfile.CopyStep{Src: "main.go", Dest: "/src/"})
+
+	hash := func() string {
+		t.Helper()
+
+		fileSet, err := bctx.Files(cf)
+		require.NoError(t, err)
+
+		out, err := fileSet.Hash()
+		require.NoError(t, err)
+
+		return out
+	}
+
+	first := hash()
+	assert.Regexp(t, `^sha256:[0-9a-f]{64}$`, first)
+	assert.Equal(t, first, hash())
+
+	// Files which are not copied do not affect the hash.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("# changed\n"), 0o644))
+	assert.Equal(t, first, hash())
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package app\n"), 0o644))
+	changed := hash()
+	assert.NotEqual(t, first, changed)
+
+	require.NoError(t, os.Chmod(filepath.Join(dir, "main.go"), 0o755))
+	assert.NotEqual(t, changed, hash())
+}
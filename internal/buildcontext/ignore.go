@@ -0,0 +1,255 @@
+package buildcontext
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/containerfile"
+)
+
+// The names of the ignore files which are read, in order of preference.
+var ignoreFiles = []string{".containerignore", ".dockerignore"}
+
+// A single line of an ignore file.
+type Pattern struct {
+	// A slash-separated glob relative to the root of the build context.
+	Glob string
+	// Set for patterns beginning with !, which re-include paths excluded by
+	// earlier patterns.
+	Negate bool
+	re     *regexp.Regexp
+}
+
+func NewPattern(line string) (*Pattern, error) {
+	p := &Pattern{}
+
+	if glob, ok := strings.CutPrefix(line, "!"); ok {
+		p.Negate = true
+		line = strings.TrimSpace(glob)
+	}
+
+	p.Glob = cleanPath(line)
+
+	re, err := globRegexp(p.Glob)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", line, err)
+	}
+
+	p.re = re
+
+	return p, nil
+}
+
+func (p *Pattern) String() string {
+	if p.Negate {
+		return "!" + p.Glob
+	}
+
+	return p.Glob
+}
+
+// Reports whether the pattern matches the given path or any of the
+// directories containing it.
+func (p *Pattern) Match(name string) bool {
+	for name != "." && name != "/" && name != "" {
+		if p.re.MatchString(name) {
+			return true
+		}
+
+		name = path.Dir(name)
+	}
+
+	return false
+}
+
+// The patterns of a .containerignore or .dockerignore file. The last pattern
+// which matches a path decides whether it is excluded.
+type Ignore struct {
+	Patterns []*Pattern
+}
+
+// Parses an ignore file. Blank lines and lines beginning with # are skipped.
+func ParseIgnore(r io.Reader) (*Ignore, error) {
+	ig := &Ignore{}
+	scanner := bufio.NewScanner(r)
+
+	for num := 1; scanner.Scan(); num++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p, err := NewPattern(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", num, err)
+		}
+
+		ig.Patterns = append(ig.Patterns, p)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return ig, nil
+}
+
+// Reads the .containerignore file in the given directory, falling back to
+// .dockerignore as buildah does. Returns an empty Ignore if neither exists.
+func ReadIgnore(dir string) (*Ignore, error) {
+	for _, name := range ignoreFiles {
+		f, err := os.Open(filepath.Join(dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer f.Close()
+
+		ig, err := ParseIgnore(f)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %s: %w", f.Name(), err)
+		}
+
+		return ig, nil
+	}
+
+	return &Ignore{}, nil
+}
+
+// Generates an ignore file which excludes everything the Containerfile does
+// not COPY or ADD from the build context. Nothing is excluded if the whole
+// context is copied, e.g., by containerfile.CopyAllStep.
+func IgnoreFor(cf *containerfile.Containerfile) (*Ignore, error) {
+	sources := cf.ContextSources()
+
+	ig := &Ignore{}
+
+	for _, src := range sources {
+		if cleanPath(src) == "." {
+			return ig, nil
+		}
+	}
+
+	lines := append([]string{"*"}, prefixAll("!", sources)...)
+
+	for _, line := range lines {
+		p, err := NewPattern(line)
+		if err != nil {
+			return nil, err
+		}
+
+		ig.Patterns = append(ig.Patterns, p)
+	}
+
+	return ig, nil
+}
+
+// Reports whether the given slash-separated path, relative to the root of
+// the build context, is excluded.
+func (ig *Ignore) Excludes(name string) bool {
+	name = cleanPath(name)
+	excluded := false
+
+	for _, p := range ig.Patterns {
+		if p.Match(name) {
+			excluded = !p.Negate
+		}
+	}
+
+	return excluded
+}
+
+// Renders the patterns one per line.
+func (ig *Ignore) String() string {
+	sb := &strings.Builder{}
+
+	for _, p := range ig.Patterns {
+		fmt.Fprintln(sb, p.String())
+	}
+
+	return sb.String()
+}
+
+// Writes the patterns to the .containerignore file in the given directory.
+func (ig *Ignore) WriteFile(dir string) error {
+	return os.WriteFile(filepath.Join(dir, ignoreFiles[0]), []byte(ig.String()), 0o644)
+}
+
+// Cleans the given path and makes it relative to the root of the build
+// context, which is itself ".".
+func cleanPath(name string) string {
+	cleaned := strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+	if cleaned == "" {
+		return "."
+	}
+
+	return cleaned
+}
+
+func prefixAll(prefix string, in []string) []string {
+	out := []string{}
+
+	for _, s := range in {
+		out = append(out, prefix+s)
+	}
+
+	return out
+}
+
+// Converts a glob into a regular expression. In addition to the syntax of
+// path.Match, ** matches any number of directories.
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	sb := &strings.Builder{}
+	sb.WriteString("^")
+
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			sb.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '\\' && i+1 < len(glob):
+			i++
+			sb.WriteString(regexp.QuoteMeta(string(glob[i])))
+		case c == '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end == -1 {
+				return nil, path.ErrBadPattern
+			}
+
+			class := glob[i+1 : i+end]
+			if negated, ok := strings.CutPrefix(class, "!"); ok {
+				class = "^" + negated
+			}
+
+			sb.WriteString("[" + class + "]")
+			i += end
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	sb.WriteString("$")
+
+	return regexp.Compile(sb.String())
+}
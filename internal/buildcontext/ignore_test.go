@@ -0,0 +1,125 @@
+package buildcontext
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/containerfile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnoreExcludes(t *testing.T) {
+	ig, err := ParseIgnore(strings.NewReader(`# Build outputs
+bin
+/tmp/
+*.log
+!important.log
+**/*.swp
+docs/**
+!docs/README.md
+src/[!a]*.go
+`))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		excluded bool
+	}{
+		{name: "bin", excluded: true},
+		{name: "bin/app", excluded: true},
+		{name: "cmd/bin", excluded: false},
+		{name: "tmp/cache/file", excluded: true},
+		{name: "build.log", excluded: true},
+		{name: "logs/build.log", excluded: false},
+		{name: "important.log", excluded: false},
+		{name: "main.go.swp", excluded: true},
+		{name: "a/b/c/main.go.swp", excluded: true},
+		{name: "docs/guide/intro.md", excluded: true},
+		{name: "docs/README.md", excluded: false},
+		{name: "src/main.go", excluded: true},
+		{name: "src/app.go", excluded: false},
+		{name: "./src/main.go", excluded: true},
+		{name: "go.mod", excluded: false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.excluded, ig.Excludes(testCase.name))
+		})
+	}
+}
+
+func TestParseIgnoreErrors(t *testing.T) {
+	_, err := ParseIgnore(strings.NewReader("bin\nsrc/[a\n"))
+	assert.EqualError(t, err, `line 2: invalid pattern "src/[a": syntax error in pattern`)
+}
+
+func TestReadIgnore(t *testing.T) {
+	dir := t.TempDir()
+
+	ig, err := ReadIgnore(dir)
+	require.NoError(t, err)
+	assert.Empty(t, ig.Patterns)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".dockerignore"), []byte("bin\n"), 0o644))
+
+	ig, err = ReadIgnore(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "bin\n", ig.String())
+
+	// .containerignore takes precedence.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".containerignore"), []byte("*.log\n!keep.log\n"), 0o644))
+
+	ig, err = ReadIgnore(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "*.log\n!keep.log\n", ig.String())
+}
+
+func TestIgnoreFor(t *testing.T) {
+	cf := &containerfile.Containerfile{
+		Stages: []*containerfile.Stage{
+			{
+				Name:  "builder",
+				Image: "golang",
+				Steps: []containerfile.ContainerfileStep{
+					containerfile.NewRawStep("COPY go.mod go.sum ./"),
+					&containerfile.CopyStep{Src: "./cmd", Dest: "/src/cmd"},
+				},
+			},
+			{
+				Image: "fedora",
+				Steps: []containerfile.ContainerfileStep{
+					&containerfile.CopyStep{From: "builder", Src: "/app", Dest: "/app"},
+					containerfile.NewRawStep("ADD https://example.com/file.tar.gz config/*.yaml /etc/app/"),
+				},
+			},
+		},
+	}
+
+	ig, err := IgnoreFor(cf)
+	require.NoError(t, err)
+
+	assert.Equal(t, "*\n!cmd\n!config/*.yaml\n!go.mod\n!go.sum\n", ig.String())
+	assert.False(t, ig.Excludes("cmd/app/main.go"))
+	assert.False(t, ig.Excludes("config/app.yaml"))
+	assert.True(t, ig.Excludes("config/app.json"))
+	assert.True(t, ig.Excludes("README.md"))
+
+	dir := t.TempDir()
+	require.NoError(t, ig.WriteFile(dir))
+
+	read, err := ReadIgnore(dir)
+	require.NoError(t, err)
+	assert.Equal(t, ig.String(), read.String())
+
+	t.Run("Copying everything excludes nothing", func(t *testing.T) {
+		cf.Stages[0].Steps = append(cf.Stages[0].Steps, containerfile.CopyAllStep())
+
+		ig, err := IgnoreFor(cf)
+		require.NoError(t, err)
+		assert.Empty(t, ig.Patterns)
+	})
+}
@@ -0,0 +1,69 @@
+package command
+
+import "time"
+
+// The annotation keys defined by the OCI image spec. See:
+// https://github.com/opencontainers/image-spec/blob/main/annotations.md
+const (
+	AnnotationCreated     = "org.opencontainers.image.created"
+	AnnotationSource      = "org.opencontainers.image.source"
+	AnnotationVersion     = "org.opencontainers.image.version"
+	AnnotationRevision    = "org.opencontainers.image.revision"
+	AnnotationLicenses    = "org.opencontainers.image.licenses"
+	AnnotationTitle       = "org.opencontainers.image.title"
+	AnnotationDescription = "org.opencontainers.image.description"
+	AnnotationBaseName    = "org.opencontainers.image.base.name"
+	AnnotationBaseDigest  = "org.opencontainers.image.base.digest"
+)
+
+// The org.opencontainers.image.* annotations. The same set is commonly applied
+// both as image labels and as manifest annotations, e.g.:
+//
+//	b.Labels = append(b.Labels, a.Labels()...)
+//	b.Annotations = append(b.Annotations, a.Labels()...)
+type ImageAnnotations struct {
+	// When the image was built. Rendered as RFC 3339.
+	Created time.Time
+	// The URL of the source code the image was built from.
+	Source string
+	// The version of the packaged software.
+	Version string
+	// The source control revision, e.g., a git commit SHA.
+	Revision string
+	// An SPDX license expression, e.g., Apache-2.0.
+	Licenses    string
+	Title       string
+	Description string
+	// The pullspec of the base image, e.g., quay.io/fedora/fedora:40.
+	BaseName string
+	// The digest of the base image, e.g., sha256:...
+	BaseDigest string
+}
+
+// Returns the annotations which are set as labels, in a stable order.
+func (a ImageAnnotations) Labels() []Label {
+	created := ""
+	if !a.Created.IsZero() {
+		created = a.Created.UTC().Format(time.RFC3339)
+	}
+
+	out := []Label{}
+
+	for _, label := range []Label{
+		{Name: AnnotationCreated, Value: created},
+		{Name: AnnotationSource, Value: a.Source},
+		{Name: AnnotationVersion, Value: a.Version},
+		{Name: AnnotationRevision, Value: a.Revision},
+		{Name: AnnotationLicenses, Value: a.Licenses},
+		{Name: AnnotationTitle, Value: a.Title},
+		{Name: AnnotationDescription, Value: a.Description},
+		{Name: AnnotationBaseName, Value: a.BaseName},
+		{Name: AnnotationBaseDigest, Value: a.BaseDigest},
+	} {
+		if label.Value != "" {
+			out = append(out, label)
+		}
+	}
+
+	return out
+}
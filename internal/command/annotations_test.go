@@ -0,0 +1,43 @@
+package command
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImageAnnotations(t *testing.T) {
+	a := ImageAnnotations{
+		Created:     time.Date(2024, 7, 1, 12, 0, 0, 0, time.FixedZone("EDT", -4*60*60)),
+		Source:      "https://github.com/org/repo",
+		Revision:    "abc123",
+		Title:       "My Image",
+		Description: "Does things",
+		BaseName:    "quay.io/fedora/fedora:40",
+	}
+
+	assert.Equal(t, []Label{
+		{Name: AnnotationCreated, Value: "2024-07-01T16:00:00Z"},
+		{Name: AnnotationSource, Value: "https://github.com/org/repo"},
+		{Name: AnnotationRevision, Value: "abc123"},
+		{Name: AnnotationTitle, Value: "My Image"},
+		{Name: AnnotationDescription, Value: "Does things"},
+		{Name: AnnotationBaseName, Value: "quay.io/fedora/fedora:40"},
+	}, a.Labels())
+
+	assert.Empty(t, ImageAnnotations{}.Labels())
+
+	short := ImageAnnotations{Title: "My Image", Revision: "abc123"}
+
+	pb := &PodmanBuild{BuildOpts: BuildOpts{Labels: short.Labels(), Annotations: short.Labels()}}
+	assert.Equal(t, []string{
+		"podman", "build",
+		"--label", "org.opencontainers.image.revision=abc123",
+		"--label", "org.opencontainers.image.title=My Image",
+		"--annotation", "org.opencontainers.image.revision=abc123",
+		"--annotation", "org.opencontainers.image.title=My Image",
+		".",
+	}, pb.Command().Cmd().Args)
+	assert.Equal(t, `podman build --label org.opencontainers.image.revision=abc123 --label 'org.opencontainers.image.title=My Image' --annotation org.opencontainers.image.revision=abc123 --annotation 'org.opencontainers.image.title=My Image' .`, pb.Command().Unredacted())
+}
@@ -0,0 +1,336 @@
+package command
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Holds the options which are common to both buildah build and podman build.
+// Both builders embed this struct and render it identically so that switching
+// from one to the other does not require re-mapping fields.
+type BuildOpts struct {
+	Annotations  []Label
+	Arch         string
+	Authfile     string
+	BuildArgFile string
+	BuildArgs    []BuildArg
+	BuildContext string
+	CacheFrom    []string
+	CacheTo      []string
+	File         string
+	// Either oci or docker.
+	Format    string
+	IIDFile   string
+	Isolation string
+	Labels    []Label
+	// When nil, the builder default is used.
+	Layers    *bool
+	Manifest  string
+	Network   string
+	NoCache   bool
+	Platforms []string
+	Proxy     *Proxy
+	// One of always, missing, never, or newer.
+	Pull    string
+	Secrets []BuildSecret
+	Squash  bool
+	// Either "default" or id=path pairs, e.g., "default=$SSH_AUTH_SOCK".
+	SSH       []string
+	Tag       string
+	Target    string
+	Timestamp string
+	Volumes   []Volume
+}
+
+// Renders the common build flags along with any builder-specific value flags.
+func (b *BuildOpts) flags(extraValOpts map[string]string) []Flag {
+	valOpts := map[string]string{
+		"arch":           b.Arch,
+		"authfile":       b.Authfile,
+		"build-arg-file": b.BuildArgFile,
+		"file":           b.File,
+		"format":         b.Format,
+		"iidfile":        b.IIDFile,
+		"isolation":      b.Isolation,
+		"manifest":       b.Manifest,
+		"network":        b.Network,
+		"tag":            b.Tag,
+		"target":         b.Target,
+		"timestamp":      b.Timestamp,
+	}
+
+	for name, val := range extraValOpts {
+		valOpts[name] = val
+	}
+
+	buildFlags := mapToValFlags(valOpts)
+
+	if b.Pull != "" {
+		// --pull takes an optional value, so it must be joined with an equal sign.
+		buildFlags = append(buildFlags, &DoubleEqualValueFlag{
+			Name:  "pull",
+			Value: b.Pull,
+		})
+	}
+
+	buildFlags = append(buildFlags, mapToSwitchFlags(map[string]bool{
+		"no-cache": b.NoCache,
+		"squash":   b.Squash,
+	})...)
+
+	buildFlags = append(buildFlags, mapToOptSwitchFlags(map[string]*bool{
+		"layers": b.Layers,
+	})...)
+
+	buildFlags = append(buildFlags, platformFlags(b.Platforms)...)
+
+	for _, buildArg := range b.BuildArgs {
+		buildFlags = append(buildFlags, buildArg.flag())
+	}
+
+	for _, label := range b.Labels {
+		buildFlags = append(buildFlags, label.flag())
+	}
+
+	for _, annotation := range b.Annotations {
+		buildFlags = append(buildFlags, &DoubleValueFlag{
+			Name:  "annotation",
+			Value: annotation.render(),
+		})
+	}
+
+	for _, secret := range b.Secrets {
+		buildFlags = append(buildFlags, secret.flag())
+	}
+
+	buildFlags = append(buildFlags, repeatedValFlags("ssh", b.SSH)...)
+	buildFlags = append(buildFlags, repeatedValFlags("cache-from", b.CacheFrom)...)
+	buildFlags = append(buildFlags, repeatedValFlags("cache-to", b.CacheTo)...)
+
+	for _, volume := range b.Volumes {
+		buildFlags = append(buildFlags, volume.flag())
+	}
+
+	if b.Proxy != nil {
+		buildFlags = append(buildFlags, b.Proxy.flags()...)
+	}
+
+	return buildFlags
+}
+
+// Constructs the build command for the given binary.
+func (b *BuildOpts) command(binary string, extraValOpts map[string]string) *Command {
+	buildCtx := b.BuildContext
+	if buildCtx == "" {
+		buildCtx = "."
+	}
+
+	return NewCommand(binary, []Arg{
+		&Subcommand{
+			Name:  "build",
+			Flags: b.flags(extraValOpts),
+		},
+		PositionalArg(buildCtx),
+	})
+}
+
+// Represents a secret passed to podman or buildah build via --secret.
+type BuildSecret struct {
+	ID string
+	// The path to the file containing the secret.
+	Src string
+	// The name of the env var containing the secret.
+	Env string
+	// Either file or env.
+	Type string
+}
+
+func (b *BuildSecret) flag() Flag {
+	out := []string{fmt.Sprintf("id=%s", b.ID)}
+
+	for _, item := range []Label{
+		{Name: "src", Value: b.Src},
+		{Name: "env", Value: b.Env},
+		{Name: "type", Value: b.Type},
+	} {
+		if item.Value != "" {
+			out = append(out, item.render())
+		}
+	}
+
+	return &DoubleValueFlag{
+		Name:  "secret",
+		Value: strings.Join(out, ","),
+	}
+}
+
+// Converts each value into a separate instance of the given flag.
+func repeatedValFlags(name string, vals []string) []Flag {
+	out := []Flag{}
+
+	for _, val := range vals {
+		out = append(out, &DoubleValueFlag{
+			Name:  name,
+			Value: val,
+		})
+	}
+
+	return out
+}
+
+// Recognizes "<binary> <subcommand> [flags] [context]" for the flags which
+// BuildOpts can represent. The value flags for builder-specific options are
+// given as extra.
+func recognizeBuildOpts(argv []string, subcommands []string, extra map[string]*string) (*BuildOpts, bool) {
+	if len(argv) < 2 || !slices.Contains(subcommands, argv[1]) {
+		return nil, false
+	}
+
+	b := &BuildOpts{}
+
+	layers := ""
+	annotations := []string{}
+	buildArgs := []string{}
+	labels := []string{}
+	platforms := []string{}
+	secrets := []string{}
+	volumes := []string{}
+
+	values := map[string]*string{
+		"--arch":           &b.Arch,
+		"--authfile":       &b.Authfile,
+		"--build-arg-file": &b.BuildArgFile,
+		"-f":               &b.File,
+		"--file":           &b.File,
+		"--format":         &b.Format,
+		"--iidfile":        &b.IIDFile,
+		"--isolation":      &b.Isolation,
+		"--manifest":       &b.Manifest,
+		"--network":        &b.Network,
+		"-t":               &b.Tag,
+		"--tag":            &b.Tag,
+		"--target":         &b.Target,
+		"--timestamp":      &b.Timestamp,
+	}
+
+	for name, target := range extra {
+		values[name] = target
+	}
+
+	flags := &argvFlags{
+		switches: map[string]*bool{
+			"--no-cache": &b.NoCache,
+			"--squash":   &b.Squash,
+		},
+		values: values,
+		optValues: map[string]*string{
+			"--layers": &layers,
+			"--pull":   &b.Pull,
+		},
+		repeated: map[string]*[]string{
+			"--annotation": &annotations,
+			"--build-arg":  &buildArgs,
+			"--cache-from": &b.CacheFrom,
+			"--cache-to":   &b.CacheTo,
+			"--label":      &labels,
+			"--platform":   &platforms,
+			"--secret":     &secrets,
+			"--ssh":        &b.SSH,
+			"-v":           &volumes,
+			"--volume":     &volumes,
+		},
+	}
+
+	positionals, ok := flags.parse(argv[2:])
+	if !ok || len(positionals) > 1 {
+		return nil, false
+	}
+
+	if len(positionals) == 1 && positionals[0] != "." {
+		b.BuildContext = positionals[0]
+	}
+
+	if layers != "" {
+		val, err := strconv.ParseBool(layers)
+		if err != nil {
+			return nil, false
+		}
+
+		b.Layers = &val
+	}
+
+	for _, platform := range platforms {
+		b.Platforms = append(b.Platforms, strings.Split(platform, ",")...)
+	}
+
+	if b.Annotations, ok = parseLabels(annotations); !ok {
+		return nil, false
+	}
+
+	if b.Labels, ok = parseLabels(labels); !ok {
+		return nil, false
+	}
+
+	parsedBuildArgs, ok := parseLabels(buildArgs)
+	if !ok {
+		return nil, false
+	}
+
+	for _, buildArg := range parsedBuildArgs {
+		b.BuildArgs = append(b.BuildArgs, BuildArg(buildArg))
+	}
+
+	for _, secret := range secrets {
+		parsed, ok := parseBuildSecret(secret)
+		if !ok {
+			return nil, false
+		}
+
+		b.Secrets = append(b.Secrets, parsed)
+	}
+
+	for _, volume := range volumes {
+		parsed, ok := parseVolume(volume)
+		if !ok {
+			return nil, false
+		}
+
+		b.Volumes = append(b.Volumes, parsed)
+	}
+
+	return b, true
+}
+
+// Parses the value of a --secret flag, e.g., id=mysecret,src=/path.
+func parseBuildSecret(s string) (BuildSecret, bool) {
+	items, ok := parseLabels(strings.Split(s, ","))
+	if !ok {
+		return BuildSecret{}, false
+	}
+
+	secret := BuildSecret{}
+
+	for _, item := range items {
+		switch item.Name {
+		case "id":
+			secret.ID = item.Value
+		case "src":
+			secret.Src = item.Value
+		case "env":
+			secret.Env = item.Value
+		case "type":
+			secret.Type = item.Value
+		default:
+			return BuildSecret{}, false
+		}
+	}
+
+	// The id is always rendered first.
+	if secret.ID == "" || items[0].Name != "id" {
+		return BuildSecret{}, false
+	}
+
+	return secret, true
+}
@@ -0,0 +1,68 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildOpts(t *testing.T) {
+	layers := false
+
+	opts := BuildOpts{
+		Annotations:  []Label{{Name: "org.opencontainers.image.title", Value: "image"}},
+		BuildArgFile: "build-args.conf",
+		BuildArgs:    []BuildArg{{Name: "ARG", Value: "val"}},
+		CacheFrom:    []string{"quay.io/org/cache"},
+		CacheTo:      []string{"quay.io/org/cache"},
+		File:         "Containerfile",
+		Format:       "oci",
+		IIDFile:      "/tmp/iidfile",
+		Isolation:    "chroot",
+		Labels:       []Label{{Name: "label", Value: "value"}},
+		Layers:       &layers,
+		Network:      "host",
+		NoCache:      true,
+		Pull:         "always",
+		Secrets:      []BuildSecret{{ID: "creds", Src: "/path/to/creds"}},
+		Squash:       true,
+		SSH:          []string{"default"},
+		Tag:          "quay.io/org/image:latest",
+		Target:       "final",
+		Timestamp:    "0",
+	}
+
+	expectedFlags := strings.Join([]string{
+		"--build-arg-file build-args.conf",
+		"--file Containerfile",
+		"--format oci",
+		"--iidfile /tmp/iidfile",
+		"--isolation chroot",
+		"--network host",
+		"--tag quay.io/org/image:latest",
+		"--target final",
+		"--timestamp 0",
+		"--pull=always",
+		"--no-cache",
+		"--squash",
+		"--layers=false",
+		"--build-arg ARG=val",
+		"--label label=value",
+		"--annotation org.opencontainers.image.title=image",
+		"--secret id=creds,src=/path/to/creds",
+		"--ssh default",
+		"--cache-from quay.io/org/cache",
+		"--cache-to quay.io/org/cache",
+		".",
+	}, " ")
+
+	pb := &PodmanBuild{BuildOpts: opts}
+	assert.Equal(t, "podman build "+expectedFlags, pb.Command().String())
+
+	bb := &BuildahBuild{BuildOpts: opts}
+	assert.Equal(t, "buildah build "+expectedFlags, bb.Command().String())
+
+	bb.StorageDriver = "vfs"
+	assert.Contains(t, bb.Command().String(), "--storage-driver vfs")
+}
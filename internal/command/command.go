@@ -1,6 +1,7 @@
 package command
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
 	"strings"
@@ -9,8 +10,10 @@ import (
 // Basic command struct which holds a list of arguments and environment
 // variables.
 type Command struct {
-	args []Arg
-	env  map[string]string
+	args      []Arg
+	env       map[string]string
+	secretEnv map[string]bool
+	policy    *Policy
 }
 
 func NewCommand(name string, args []Arg) *Command {
@@ -19,32 +22,53 @@ func NewCommand(name string, args []Arg) *Command {
 	}
 }
 
-func (c *Command) envVars() []string {
+func (c *Command) envVars(redact bool) []string {
 	envVars := []string{}
 
-	for name, val := range c.env {
+	for _, name := range sortedKeys(c.env) {
+		val := c.env[name]
+		if redact && c.secretEnv[name] {
+			val = Redacted
+		}
+
 		envVars = append(envVars, fmt.Sprintf("%s=%s", name, val))
 	}
 
 	return envVars
 }
 
+// Returns the name of the binary which the command runs.
+func (c *Command) Name() string {
+	return c.args[0].Arg()[0]
+}
+
 // Emits a string representation of the command including each environment
-// variable.
+// variable. Secret values are replaced with ***.
 func (c *Command) String() string {
-	out := append(c.envVars(), renderArgs(c.args)...)
+	out := c.envVars(true)
+
+	for _, arg := range c.args {
+		out = append(out, redactArg(arg)...)
+	}
+
 	return strings.Join(out, " ")
 }
 
 // Emits an instantiated exec.Cmd instance ready for execution.
 func (c *Command) Cmd() *exec.Cmd {
-	cmd := exec.Command(c.args[0].Arg()[0], renderArgs(c.args[1:])...)
+	return c.CmdContext(context.Background())
+}
+
+// Emits an instantiated exec.Cmd instance which is killed when the given
+// context is done.
+func (c *Command) CmdContext(ctx context.Context) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, c.args[0].Arg()[0], renderArgs(c.args[1:])...)
 
 	if c.env == nil {
 		return cmd
 	}
 
-	cmd.Env = append(cmd.Env, c.envVars()...)
+	cmd.Env = append(cmd.Env, c.envVars(false)...)
 	return cmd
 }
 
@@ -54,6 +78,18 @@ func NewCommandWithEnv(name string, args []Arg, env map[string]string) *Command
 	return cmd
 }
 
+// Implemented by each of the specialized command structs which know how to
+// construct a Command from their options.
+type Commander interface {
+	Command() *Command
+}
+
+// Allows an already constructed Command to be used wherever a Commander is
+// accepted.
+func (c *Command) Command() *Command {
+	return c
+}
+
 // Represents a positional argument to give to a command.
 type PositionalArg string
 
@@ -77,6 +113,16 @@ func (s *Subcommand) Arg() []string {
 	return append(out, renderFlags(s.Flags)...)
 }
 
+func (s *Subcommand) Redact() []string {
+	out := []string{s.Name}
+
+	for _, flag := range s.Flags {
+		out = append(out, redactArg(flag)...)
+	}
+
+	return out
+}
+
 // Represents a flag argument such as --full-name, -single, or -s.
 type Flag interface {
 	Arg() []string
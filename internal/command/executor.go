@@ -0,0 +1,58 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+// Executes commands. Implementations other than ExecExecutor are mostly
+// useful for testing code which runs commands without actually running them.
+type Executor interface {
+	// Runs the command to completion, writing its output to the given writers.
+	// Any policy attached to the command is ignored; see ExecuteWithPolicy.
+	// A non-zero exit code should be reported as an *ExitError or an
+	// *exec.ExitError.
+	Execute(ctx context.Context, cmd *Command, stdout, stderr io.Writer) error
+}
+
+// Executes commands as local processes.
+type ExecExecutor struct{}
+
+func (ExecExecutor) Execute(ctx context.Context, cmd *Command, stdout, stderr io.Writer) error {
+	c := cmd.CmdContext(ctx)
+	c.Stdout = stdout
+	c.Stderr = stderr
+	return c.Run()
+}
+
+// Represents a command which exited with a non-zero exit code.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// Returns the exit code reported by the given error. Nil errors have an exit
+// code of 0 while errors which do not carry an exit code return -1.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	exitErr := &ExitError{}
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+
+	execExitErr := &exec.ExitError{}
+	if errors.As(err, &execExitErr) {
+		return execExitErr.ExitCode()
+	}
+
+	return -1
+}
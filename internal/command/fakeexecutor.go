@@ -0,0 +1,96 @@
+package command
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+)
+
+// A canned response for a command executed by the FakeExecutor.
+type FakeResponse struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	// Returned instead of an *ExitError, if set.
+	Err error
+	// Called before the response is written, e.g., to create files that the
+	// real command would have created or to block until the context is done.
+	Func func(ctx context.Context, cmd *Command) error
+}
+
+// An Executor which does not run anything. Instead, it records each command
+// and replies with a canned response.
+type FakeExecutor struct {
+	// Keyed by the rendered command. If no exact match is found, the longest
+	// key which is a prefix of the rendered command is used.
+	Responses map[string]FakeResponse
+	// Used when no response matches.
+	Default FakeResponse
+
+	mu       sync.Mutex
+	executed []string
+}
+
+func (f *FakeExecutor) Execute(ctx context.Context, cmd *Command, stdout, stderr io.Writer) error {
+	rendered := cmd.String()
+
+	f.mu.Lock()
+	f.executed = append(f.executed, rendered)
+	f.mu.Unlock()
+
+	resp := f.response(rendered)
+
+	if resp.Func != nil {
+		if err := resp.Func(ctx, cmd); err != nil {
+			return err
+		}
+	}
+
+	if _, err := io.WriteString(stdout, resp.Stdout); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(stderr, resp.Stderr); err != nil {
+		return err
+	}
+
+	if resp.Err != nil {
+		return resp.Err
+	}
+
+	if resp.ExitCode != 0 {
+		return &ExitError{Code: resp.ExitCode}
+	}
+
+	return nil
+}
+
+// Returns the rendered commands in the order they were executed.
+func (f *FakeExecutor) Executed() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := make([]string, len(f.executed))
+	copy(out, f.executed)
+	return out
+}
+
+func (f *FakeExecutor) response(rendered string) FakeResponse {
+	if resp, ok := f.Responses[rendered]; ok {
+		return resp
+	}
+
+	longest := ""
+	for key := range f.Responses {
+		if strings.HasPrefix(rendered, key) && len(key) > len(longest) {
+			longest = key
+		}
+	}
+
+	if longest != "" {
+		return f.Responses[longest]
+	}
+
+	return f.Default
+}
@@ -0,0 +1,153 @@
+package command
+
+import (
+	"strings"
+)
+
+// Constructs the commands needed to build a Containerfile for multiple
+// platforms and push the resulting manifest list. By default, buildah is used.
+type ManifestListBuild struct {
+	// Use podman instead of buildah for every command.
+	Podman bool
+	// Perform a separate build for each platform instead of a single build
+	// with a comma-separated --platform flag.
+	PerPlatform  bool
+	Authfile     string
+	BuildArgs    []BuildArg
+	BuildContext string
+	File         string
+	// The name of the local manifest list, e.g., localhost/image:latest.
+	Manifest string
+	// Platforms in the form os/arch[/variant], e.g., linux/arm64.
+	Platforms []string
+	// Where to push the manifest list to. If empty, the manifest list is pushed
+	// to the location given by its name.
+	Destination string
+	TLSVerify   *bool
+}
+
+// Returns the commands in the order that they should be executed.
+func (m *ManifestListBuild) Commands() []*Command {
+	out := []*Command{m.createCommand()}
+
+	if m.PerPlatform {
+		for _, platform := range m.Platforms {
+			out = append(out, m.buildCommand([]string{platform}))
+		}
+	} else {
+		out = append(out, m.buildCommand(m.Platforms))
+	}
+
+	return append(out, m.pushCommand())
+}
+
+func (m *ManifestListBuild) createCommand() *Command {
+	if m.Podman {
+		pmc := &PodmanManifestCreate{Name: m.Manifest}
+		return pmc.Command()
+	}
+
+	bmc := &BuildahManifestCreate{Name: m.Manifest}
+	return bmc.Command()
+}
+
+func (m *ManifestListBuild) buildCommand(platforms []string) *Command {
+	opts := BuildOpts{
+		Authfile:     m.Authfile,
+		BuildArgs:    m.BuildArgs,
+		BuildContext: m.BuildContext,
+		File:         m.File,
+		Manifest:     m.Manifest,
+		Platforms:    platforms,
+	}
+
+	if m.Podman {
+		pb := &PodmanBuild{BuildOpts: opts}
+		return pb.Command()
+	}
+
+	bb := &BuildahBuild{BuildOpts: opts}
+	return bb.Command()
+}
+
+func (m *ManifestListBuild) pushCommand() *Command {
+	if m.Podman {
+		pmp := &PodmanManifestPush{
+			All:         true,
+			Authfile:    m.Authfile,
+			TLSVerify:   m.TLSVerify,
+			List:        m.Manifest,
+			Destination: m.Destination,
+		}
+
+		return pmp.Command()
+	}
+
+	bmp := &BuildahManifestPush{
+		All:         true,
+		Authfile:    m.Authfile,
+		TLSVerify:   m.TLSVerify,
+		List:        m.Manifest,
+		Destination: m.Destination,
+	}
+
+	return bmp.Command()
+}
+
+// Renders the --platform flag for a build from a list of platforms.
+func platformFlags(platforms []string) []Flag {
+	if len(platforms) == 0 {
+		return []Flag{}
+	}
+
+	return []Flag{
+		&DoubleValueFlag{
+			Name:  "platform",
+			Value: strings.Join(platforms, ","),
+		},
+	}
+}
+
+// Constructs a "<binary> manifest <subcommand>" command. Empty positional
+// arguments are omitted.
+func manifestCommand(binary, subcommand string, flags []Flag, positional ...string) *Command {
+	args := []Arg{
+		PositionalArg("manifest"),
+		&Subcommand{
+			Name:  subcommand,
+			Flags: flags,
+		},
+	}
+
+	for _, item := range positional {
+		if item != "" {
+			args = append(args, PositionalArg(item))
+		}
+	}
+
+	return NewCommand(binary, args)
+}
+
+// Constructs a manifest create command, which is identical for buildah and
+// podman.
+func manifestCreateCommand(binary string, all, amend bool, name string, images []string) *Command {
+	createFlags := mapToSwitchFlags(map[string]bool{
+		"all":   all,
+		"amend": amend,
+	})
+
+	return manifestCommand(binary, "create", createFlags, append([]string{name}, images...)...)
+}
+
+// Renders the flags shared by the manifest add and push commands.
+func manifestFlags(all bool, valOpts map[string]string, tlsVerify *bool) []Flag {
+	out := mapToSwitchFlags(map[string]bool{
+		"all": all,
+	})
+
+	out = append(out, mapToValFlags(valOpts)...)
+
+	return append(out, mapToOptSwitchFlags(map[string]*bool{
+		"tls-verify": tlsVerify,
+	})...)
+}
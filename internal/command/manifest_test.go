@@ -0,0 +1,126 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildahManifestCommands(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    interface{ Command() *Command }
+		expected string
+	}{
+		{
+			name: "Build with platforms and manifest",
+			input: &BuildahBuild{
+				BuildOpts: BuildOpts{
+					File:      "Containerfile",
+					Manifest:  "localhost/image:latest",
+					Platforms: []string{"linux/amd64", "linux/arm64"},
+				},
+			},
+			expected: "buildah build --file Containerfile --manifest localhost/image:latest --platform linux/amd64,linux/arm64 .",
+		},
+		{
+			name: "Create",
+			input: &BuildahManifestCreate{
+				Name: "localhost/image:latest",
+			},
+			expected: "buildah manifest create localhost/image:latest",
+		},
+		{
+			name: "Add",
+			input: &BuildahManifestAdd{
+				Arch:    "arm64",
+				Variant: "v8",
+				List:    "localhost/image:latest",
+				Image:   "quay.io/org/image:arm64",
+			},
+			expected: "buildah manifest add --arch arm64 --variant v8 localhost/image:latest quay.io/org/image:arm64",
+		},
+		{
+			name: "Annotate",
+			input: &BuildahManifestAnnotate{
+				Annotations: []Label{{Name: "key", Value: "value"}},
+				OS:          "linux",
+				List:        "localhost/image:latest",
+				Image:       "sha256:abcd",
+			},
+			expected: "buildah manifest annotate --os linux --annotation key=value localhost/image:latest sha256:abcd",
+		},
+		{
+			name: "Inspect",
+			input: &BuildahManifestInspect{
+				List: "localhost/image:latest",
+			},
+			expected: "buildah manifest inspect localhost/image:latest",
+		},
+		{
+			name: "Push",
+			input: &BuildahManifestPush{
+				All:         true,
+				Digestfile:  "/tmp/digestfile",
+				List:        "localhost/image:latest",
+				Destination: "docker://quay.io/org/image:latest",
+			},
+			expected: "buildah manifest push --all --digestfile /tmp/digestfile localhost/image:latest docker://quay.io/org/image:latest",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, testCase.input.Command().String())
+		})
+	}
+}
+
+func TestManifestListBuild(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    *ManifestListBuild
+		expected []string
+	}{
+		{
+			name: "Buildah single build",
+			input: &ManifestListBuild{
+				File:        "Containerfile",
+				Manifest:    "localhost/image:latest",
+				Platforms:   []string{"linux/amd64", "linux/arm64"},
+				Destination: "docker://quay.io/org/image:latest",
+			},
+			expected: []string{
+				"buildah manifest create localhost/image:latest",
+				"buildah build --file Containerfile --manifest localhost/image:latest --platform linux/amd64,linux/arm64 .",
+				"buildah manifest push --all localhost/image:latest docker://quay.io/org/image:latest",
+			},
+		},
+		{
+			name: "Podman per-platform builds",
+			input: &ManifestListBuild{
+				Podman:      true,
+				PerPlatform: true,
+				Manifest:    "quay.io/org/image:latest",
+				Platforms:   []string{"linux/amd64", "linux/arm64"},
+			},
+			expected: []string{
+				"podman manifest create quay.io/org/image:latest",
+				"podman build --manifest quay.io/org/image:latest --platform linux/amd64 .",
+				"podman build --manifest quay.io/org/image:latest --platform linux/arm64 .",
+				"podman manifest push --all quay.io/org/image:latest",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			out := []string{}
+			for _, cmd := range testCase.input.Commands() {
+				out = append(out, cmd.String())
+			}
+
+			assert.Equal(t, testCase.expected, out)
+		})
+	}
+}
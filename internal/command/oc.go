@@ -19,13 +19,11 @@ func newOcCommand(kubeconfig string, args []Arg) *Command {
 }
 
 func (l *Login) Command(kubeconfig string) *Command {
-	args := ArgLiterals{
-		"login",
-		"--token", l.Token,
-		"--server", l.Server,
-	}
-
-	return newOcCommand(kubeconfig, args.Arg())
+	return newOcCommand(kubeconfig, []Arg{
+		PositionalArg("login"),
+		&SecretValueFlag{Name: "token", Value: l.Token},
+		&DoubleValueFlag{Name: "server", Value: l.Server},
+	})
 }
 
 type RegistryLogin struct {
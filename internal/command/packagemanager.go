@@ -0,0 +1,399 @@
+package command
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Represents a package repository to add to a package manager.
+type PackageRepo struct {
+	// A unique name for the repository. This is used for the repo file name or
+	// alias, depending upon the package manager.
+	Name string
+	// The URL of the repository or .repo file. For apt-get, this is the full
+	// sources.list entry, e.g., "deb http://deb.example.com stable main".
+	URL string
+}
+
+// Abstracts over the various package managers so that callers can construct
+// the appropriate commands for a given base image without switching on the
+// package manager themselves. Each operation returns the ordered commands
+// needed to perform it noninteractively.
+type PackageManager interface {
+	// The name of the package manager, e.g., dnf.
+	Name() string
+	Install(packages []string) []Commander
+	Remove(packages []string) []Commander
+	// Refreshes the package metadata.
+	Update() []Commander
+	// Removes any caches left behind by the other operations.
+	CleanCache() []Commander
+	RepoAdd(repo PackageRepo) []Commander
+	// The directories holding the package manager's cache.
+	CacheDirs() []string
+}
+
+// The dnf package manager as found in Fedora < 41, CentOS Stream, and RHEL.
+type Dnf struct{}
+
+func (Dnf) Name() string { return "dnf" }
+
+func (Dnf) Install(packages []string) []Commander {
+	return []Commander{&DnfInstall{Yes: true, Packages: packages}}
+}
+
+func (Dnf) Remove(packages []string) []Commander {
+	return []Commander{packageCommand("dnf", "remove", "-y", packages)}
+}
+
+func (Dnf) Update() []Commander {
+	return []Commander{CommandLiteral{"dnf", "makecache"}}
+}
+
+func (Dnf) CleanCache() []Commander {
+	return []Commander{CommandLiteral{"dnf", "clean", "all"}}
+}
+
+func (Dnf) RepoAdd(repo PackageRepo) []Commander {
+	return []Commander{CommandLiteral{"dnf", "config-manager", "--add-repo", repo.URL}}
+}
+
+func (Dnf) CacheDirs() []string {
+	return []string{"/var/cache/dnf"}
+}
+
+// The dnf5 package manager as found in Fedora >= 41. It is still invoked as
+// dnf, but has a different config-manager syntax and cache location.
+type Dnf5 struct{}
+
+func (Dnf5) Name() string { return "dnf5" }
+
+func (Dnf5) Install(packages []string) []Commander {
+	return Dnf{}.Install(packages)
+}
+
+func (Dnf5) Remove(packages []string) []Commander {
+	return Dnf{}.Remove(packages)
+}
+
+func (Dnf5) Update() []Commander {
+	return Dnf{}.Update()
+}
+
+func (Dnf5) CleanCache() []Commander {
+	return Dnf{}.CleanCache()
+}
+
+func (Dnf5) RepoAdd(repo PackageRepo) []Commander {
+	return []Commander{CommandLiteral{"dnf", "config-manager", "addrepo", "--from-repofile=" + repo.URL}}
+}
+
+func (Dnf5) CacheDirs() []string {
+	return []string{"/var/cache/libdnf5"}
+}
+
+// The yum package manager as found in CentOS 7 and RHEL 7.
+type Yum struct{}
+
+func (Yum) Name() string { return "yum" }
+
+func (Yum) Install(packages []string) []Commander {
+	return []Commander{&YumInstall{Yes: true, Packages: packages}}
+}
+
+func (Yum) Remove(packages []string) []Commander {
+	return []Commander{packageCommand("yum", "remove", "-y", packages)}
+}
+
+func (Yum) Update() []Commander {
+	return []Commander{CommandLiteral{"yum", "makecache"}}
+}
+
+func (Yum) CleanCache() []Commander {
+	return []Commander{CommandLiteral{"yum", "clean", "all"}}
+}
+
+func (Yum) RepoAdd(repo PackageRepo) []Commander {
+	return []Commander{CommandLiteral{"yum-config-manager", "--add-repo", repo.URL}}
+}
+
+func (Yum) CacheDirs() []string {
+	return []string{"/var/cache/yum"}
+}
+
+// The microdnf package manager as found in the UBI minimal images.
+type Microdnf struct{}
+
+func (Microdnf) Name() string { return "microdnf" }
+
+func (Microdnf) Install(packages []string) []Commander {
+	return []Commander{&MicrodnfInstall{Yes: true, Packages: packages}}
+}
+
+func (Microdnf) Remove(packages []string) []Commander {
+	return []Commander{packageCommand("microdnf", "remove", "-y", packages)}
+}
+
+func (Microdnf) Update() []Commander {
+	return []Commander{CommandLiteral{"microdnf", "makecache"}}
+}
+
+func (Microdnf) CleanCache() []Commander {
+	return []Commander{CommandLiteral{"microdnf", "clean", "all"}}
+}
+
+// microdnf has no config-manager, so the .repo file is downloaded directly.
+func (Microdnf) RepoAdd(repo PackageRepo) []Commander {
+	return []Commander{repoFileDownload(repo)}
+}
+
+func (Microdnf) CacheDirs() []string {
+	return []string{"/var/cache/yum"}
+}
+
+// The apt-get package manager as found in Debian and Ubuntu.
+type AptGet struct{}
+
+func (AptGet) Name() string { return "apt-get" }
+
+// The package lists are empty in most base images, so they must be updated
+// before any packages can be installed.
+func (a AptGet) Install(packages []string) []Commander {
+	return append(a.Update(), &AptGetInstall{Yes: true, Packages: packages})
+}
+
+func (AptGet) Remove(packages []string) []Commander {
+	return []Commander{packageCommand("apt-get", "remove", "-y", packages)}
+}
+
+func (AptGet) Update() []Commander {
+	return []Commander{&AptGetUpdate{}}
+}
+
+func (AptGet) CleanCache() []Commander {
+	return []Commander{
+		CommandLiteral{"apt-get", "clean"},
+		deleteGlob("/var/lib/apt/lists/*"),
+	}
+}
+
+func (AptGet) RepoAdd(repo PackageRepo) []Commander {
+	dest := fmt.Sprintf("/etc/apt/sources.list.d/%s.list", repo.Name)
+	return []Commander{shellCommand(fmt.Sprintf("echo %s > %s", ShellQuote(repo.URL), ShellQuote(dest)))}
+}
+
+func (AptGet) CacheDirs() []string {
+	return []string{"/var/cache/apt", "/var/lib/apt/lists"}
+}
+
+// The apk package manager as found in Alpine.
+type Apk struct{}
+
+func (Apk) Name() string { return "apk" }
+
+func (Apk) Install(packages []string) []Commander {
+	return []Commander{&ApkAdd{NoCache: true, Packages: packages}}
+}
+
+func (Apk) Remove(packages []string) []Commander {
+	return []Commander{packageCommand("apk", "del", "", packages)}
+}
+
+func (Apk) Update() []Commander {
+	return []Commander{CommandLiteral{"apk", "update"}}
+}
+
+func (Apk) CleanCache() []Commander {
+	return []Commander{deleteGlob("/var/cache/apk/*")}
+}
+
+func (Apk) RepoAdd(repo PackageRepo) []Commander {
+	return []Commander{shellCommand(fmt.Sprintf("echo %s >> /etc/apk/repositories", ShellQuote(repo.URL)))}
+}
+
+func (Apk) CacheDirs() []string {
+	return []string{"/var/cache/apk"}
+}
+
+// The zypper package manager as found in openSUSE and SLES.
+type Zypper struct{}
+
+func (Zypper) Name() string { return "zypper" }
+
+func (Zypper) Install(packages []string) []Commander {
+	return []Commander{&ZypperInstall{Yes: true, Packages: packages}}
+}
+
+func (Zypper) Remove(packages []string) []Commander {
+	return []Commander{packageCommand("zypper", "remove", "-y", packages)}
+}
+
+func (Zypper) Update() []Commander {
+	return []Commander{CommandLiteral{"zypper", "--non-interactive", "refresh"}}
+}
+
+func (Zypper) CleanCache() []Commander {
+	return []Commander{CommandLiteral{"zypper", "clean", "--all"}}
+}
+
+func (Zypper) RepoAdd(repo PackageRepo) []Commander {
+	return []Commander{CommandLiteral{"zypper", "addrepo", repo.URL, repo.Name}}
+}
+
+func (Zypper) CacheDirs() []string {
+	return []string{"/var/cache/zypp"}
+}
+
+// The rpm-ostree package manager as found in image-mode / CoreOS derived
+// images.
+type RpmOstree struct{}
+
+func (RpmOstree) Name() string { return "rpm-ostree" }
+
+func (RpmOstree) Install(packages []string) []Commander {
+	return []Commander{&RpmOstreeInstall{Packages: packages}}
+}
+
+func (RpmOstree) Remove(packages []string) []Commander {
+	return []Commander{packageCommand("rpm-ostree", "uninstall", "", packages)}
+}
+
+func (RpmOstree) Update() []Commander {
+	return []Commander{CommandLiteral{"rpm-ostree", "refresh-md"}}
+}
+
+func (RpmOstree) CleanCache() []Commander {
+	return []Commander{CommandLiteral{"rpm-ostree", "cleanup", "-m"}}
+}
+
+// rpm-ostree has no notion of adding a repo, so the .repo file is downloaded
+// directly.
+func (RpmOstree) RepoAdd(repo PackageRepo) []Commander {
+	return []Commander{repoFileDownload(repo)}
+}
+
+func (RpmOstree) CacheDirs() []string {
+	return []string{"/var/cache/rpm-ostree"}
+}
+
+// Returns the package manager with the given name, e.g., apt-get.
+func PackageManagerByName(name string) (PackageManager, bool) {
+	for _, pm := range []PackageManager{Dnf{}, Dnf5{}, Yum{}, Microdnf{}, AptGet{}, Apk{}, Zypper{}, RpmOstree{}} {
+		if pm.Name() == name {
+			return pm, true
+		}
+	}
+
+	return nil, false
+}
+
+// Returns the package manager for the given OS family, which corresponds to
+// the ID field in /etc/os-release.
+func PackageManagerForOSFamily(family string) (PackageManager, bool) {
+	switch strings.ToLower(family) {
+	case "fedora":
+		return Dnf5{}, true
+	case "rhel", "centos", "rocky", "almalinux", "ol":
+		return Dnf{}, true
+	case "debian", "ubuntu":
+		return AptGet{}, true
+	case "alpine":
+		return Apk{}, true
+	case "opensuse", "opensuse-leap", "opensuse-tumbleweed", "sles", "sle":
+		return Zypper{}, true
+	case "fedora-coreos", "rhcos":
+		return RpmOstree{}, true
+	}
+
+	return nil, false
+}
+
+// Guesses the package manager for the given base image pullspec by looking at
+// its repository name and tag, e.g., quay.io/centos/stream:9 or ubuntu:latest.
+func DetectPackageManager(image string) (PackageManager, bool) {
+	repo, tag := splitImageName(image)
+	name := path.Base(repo)
+
+	switch {
+	case strings.Contains(repo, "coreos") || name == "rhcos":
+		return RpmOstree{}, true
+	case strings.Contains(name, "minimal") && (strings.HasPrefix(name, "ubi") || strings.Contains(repo, "ubi")):
+		return Microdnf{}, true
+	case name == "fedora":
+		if version, ok := majorVersion(tag); ok && version < 41 {
+			return Dnf{}, true
+		}
+
+		return Dnf5{}, true
+	case name == "centos" || strings.Contains(repo, "centos"):
+		if version, ok := majorVersion(tag); ok && version <= 7 {
+			return Yum{}, true
+		}
+
+		return Dnf{}, true
+	case strings.HasPrefix(name, "ubi") || strings.HasPrefix(name, "rhel") || strings.Contains(repo, "bootc"):
+		return Dnf{}, true
+	case strings.Contains(repo, "suse"):
+		return Zypper{}, true
+	}
+
+	return PackageManagerForOSFamily(name)
+}
+
+// Splits an image pullspec into its repository and tag, discarding any
+// digest.
+func splitImageName(image string) (string, string) {
+	image, _, _ = strings.Cut(image, "@")
+
+	slash := strings.LastIndex(image, "/")
+	colon := strings.LastIndex(image, ":")
+
+	if colon > slash {
+		return image[:colon], image[colon+1:]
+	}
+
+	return image, ""
+}
+
+// Parses the leading major version from an image tag, e.g., 9 from 9.4.
+func majorVersion(tag string) (int, bool) {
+	major, _, _ := strings.Cut(tag, ".")
+	major, _, _ = strings.Cut(major, "-")
+
+	version, err := strconv.Atoi(major)
+	if err != nil {
+		return 0, false
+	}
+
+	return version, true
+}
+
+// Constructs a "<binary> <subcommand> [yes] <packages...>" command.
+func packageCommand(binary, subcommand, yes string, packages []string) CommandLiteral {
+	out := CommandLiteral{binary, subcommand}
+
+	if yes != "" {
+		out = append(out, yes)
+	}
+
+	return append(out, packages...)
+}
+
+// Downloads a .repo file into /etc/yum.repos.d.
+func repoFileDownload(repo PackageRepo) CommandLiteral {
+	return CommandLiteral{"curl", "-fsSLo", fmt.Sprintf("/etc/yum.repos.d/%s.repo", repo.Name), repo.URL}
+}
+
+// Forcibly and recursively removes whatever the given glob matches. The glob
+// is left unquoted so that the shell running the RUN instruction expands it.
+func deleteGlob(glob string) *Command {
+	return NewCommand("rm", []Arg{PositionalArg("-f"), PositionalArg("-r"), ShellArg(glob)})
+}
+
+// Runs the given script via sh -c for operations which need redirection. The
+// script is a single argument, which is quoted when rendered for a shell.
+func shellCommand(script string) *Command {
+	return NewCommand("sh", []Arg{PositionalArg("-c"), PositionalArg(script)})
+}
@@ -0,0 +1,157 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func renderCommanders(cmds []Commander) []string {
+	out := []string{}
+
+	for _, cmd := range cmds {
+		out = append(out, cmd.Command().Unredacted())
+	}
+
+	return out
+}
+
+func TestPackageManagers(t *testing.T) {
+	repo := PackageRepo{Name: "example", URL: "https://example.com/example.repo"}
+	packages := []string{"git", "golang"}
+
+	testCases := []struct {
+		pm              PackageManager
+		expectedInstall []string
+		expectedRemove  []string
+		expectedUpdate  []string
+		expectedClean   []string
+		expectedRepoAdd []string
+	}{
+		{
+			pm:              Dnf{},
+			expectedInstall: []string{"dnf install -y git golang"},
+			expectedRemove:  []string{"dnf remove -y git golang"},
+			expectedUpdate:  []string{"dnf makecache"},
+			expectedClean:   []string{"dnf clean all"},
+			expectedRepoAdd: []string{"dnf config-manager --add-repo https://example.com/example.repo"},
+		},
+		{
+			pm:              Dnf5{},
+			expectedInstall: []string{"dnf install -y git golang"},
+			expectedRemove:  []string{"dnf remove -y git golang"},
+			expectedUpdate:  []string{"dnf makecache"},
+			expectedClean:   []string{"dnf clean all"},
+			expectedRepoAdd: []string{"dnf config-manager addrepo --from-repofile=https://example.com/example.repo"},
+		},
+		{
+			pm:              Yum{},
+			expectedInstall: []string{"yum install -y git golang"},
+			expectedRemove:  []string{"yum remove -y git golang"},
+			expectedUpdate:  []string{"yum makecache"},
+			expectedClean:   []string{"yum clean all"},
+			expectedRepoAdd: []string{"yum-config-manager --add-repo https://example.com/example.repo"},
+		},
+		{
+			pm:              Microdnf{},
+			expectedInstall: []string{"microdnf install -y git golang"},
+			expectedRemove:  []string{"microdnf remove -y git golang"},
+			expectedUpdate:  []string{"microdnf makecache"},
+			expectedClean:   []string{"microdnf clean all"},
+			expectedRepoAdd: []string{"curl -fsSLo /etc/yum.repos.d/example.repo https://example.com/example.repo"},
+		},
+		{
+			pm:              AptGet{},
+			expectedInstall: []string{"apt-get update", "apt-get install -y git golang"},
+			expectedRemove:  []string{"apt-get remove -y git golang"},
+			expectedUpdate:  []string{"apt-get update"},
+			expectedClean:   []string{"apt-get clean", "rm -f -r /var/lib/apt/lists/*"},
+			expectedRepoAdd: []string{`sh -c 'echo https://example.com/example.repo > /etc/apt/sources.list.d/example.list'`},
+		},
+		{
+			pm:              Apk{},
+			expectedInstall: []string{"apk add --no-cache git golang"},
+			expectedRemove:  []string{"apk del git golang"},
+			expectedUpdate:  []string{"apk update"},
+			expectedClean:   []string{"rm -f -r /var/cache/apk/*"},
+			expectedRepoAdd: []string{`sh -c 'echo https://example.com/example.repo >> /etc/apk/repositories'`},
+		},
+		{
+			pm:              Zypper{},
+			expectedInstall: []string{"zypper install -y git golang"},
+			expectedRemove:  []string{"zypper remove -y git golang"},
+			expectedUpdate:  []string{"zypper --non-interactive refresh"},
+			expectedClean:   []string{"zypper clean --all"},
+			expectedRepoAdd: []string{"zypper addrepo https://example.com/example.repo example"},
+		},
+		{
+			pm:              RpmOstree{},
+			expectedInstall: []string{"rpm-ostree install git golang"},
+			expectedRemove:  []string{"rpm-ostree uninstall git golang"},
+			expectedUpdate:  []string{"rpm-ostree refresh-md"},
+			expectedClean:   []string{"rpm-ostree cleanup -m"},
+			expectedRepoAdd: []string{"curl -fsSLo /etc/yum.repos.d/example.repo https://example.com/example.repo"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.pm.Name(), func(t *testing.T) {
+			assert.Equal(t, testCase.expectedInstall, renderCommanders(testCase.pm.Install(packages)))
+			assert.Equal(t, testCase.expectedRemove, renderCommanders(testCase.pm.Remove(packages)))
+			assert.Equal(t, testCase.expectedUpdate, renderCommanders(testCase.pm.Update()))
+			assert.Equal(t, testCase.expectedClean, renderCommanders(testCase.pm.CleanCache()))
+			assert.Equal(t, testCase.expectedRepoAdd, renderCommanders(testCase.pm.RepoAdd(repo)))
+			assert.NotEmpty(t, testCase.pm.CacheDirs())
+		})
+	}
+}
+
+func TestDetectPackageManager(t *testing.T) {
+	testCases := []struct {
+		image    string
+		expected string
+	}{
+		{image: "registry.fedoraproject.org/fedora:latest", expected: "dnf5"},
+		{image: "registry.fedoraproject.org/fedora:40", expected: "dnf"},
+		{image: "quay.io/fedora/fedora-coreos:stable", expected: "rpm-ostree"},
+		{image: "quay.io/centos/stream:9", expected: "dnf"},
+		{image: "centos:7", expected: "yum"},
+		{image: "registry.access.redhat.com/ubi9/ubi-minimal:latest", expected: "microdnf"},
+		{image: "registry.access.redhat.com/ubi9/ubi:9.4", expected: "dnf"},
+		{image: "ubuntu:latest", expected: "apt-get"},
+		{image: "docker.io/library/debian@sha256:abcd", expected: "apt-get"},
+		{image: "alpine:3.20", expected: "apk"},
+		{image: "registry.opensuse.org/opensuse/tumbleweed:latest", expected: "zypper"},
+		{image: "localhost:5000/opensuse-leap", expected: "zypper"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.image, func(t *testing.T) {
+			pm, ok := DetectPackageManager(testCase.image)
+			if !assert.True(t, ok) {
+				return
+			}
+
+			assert.Equal(t, testCase.expected, pm.Name())
+		})
+	}
+
+	_, ok := DetectPackageManager("quay.io/org/unknown:latest")
+	assert.False(t, ok)
+}
+
+func TestShellCommand(t *testing.T) {
+	script := "printf 'café\\t%s\\n' \"$HOME\"\t> /tmp/out"
+	cmd := shellCommand(script)
+
+	// The script is passed to sh -c as a single argument, exactly as given.
+	assert.Equal(t, []string{"sh", "-c", script}, cmd.Cmd().Args)
+
+	// When rendered for a shell, the script is quoted as a single word.
+	assert.Equal(t, `sh -c 'printf '\''café\t%s\n'\'' "$HOME"	> /tmp/out'`, cmd.Unredacted())
+
+	words, err := ParseShellWords(cmd.Unredacted())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sh", "-c", script}, words.Cmd().Args)
+}
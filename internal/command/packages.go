@@ -38,6 +38,47 @@ func (a *AptGetUpdate) Command() *Command {
 	return cmd.Command()
 }
 
+// Represents a call to microdnf install.
+type MicrodnfInstall struct {
+	Yes      bool
+	Packages []string
+}
+
+func (m *MicrodnfInstall) Command() *Command {
+	return getGenericInstallerCommand("microdnf", m.Yes, m.Packages)
+}
+
+// Represents a call to zypper install.
+type ZypperInstall struct {
+	Yes      bool
+	Packages []string
+}
+
+func (z *ZypperInstall) Command() *Command {
+	return getGenericInstallerCommand("zypper", z.Yes, z.Packages)
+}
+
+// Represents a call to apk add.
+type ApkAdd struct {
+	NoCache  bool
+	Packages []string
+}
+
+func (a *ApkAdd) Command() *Command {
+	args := []Arg{
+		&Subcommand{
+			Name: "add",
+			Flags: mapToSwitchFlags(map[string]bool{
+				"no-cache": a.NoCache,
+			}),
+		},
+	}
+
+	args = append(args, itemsToPositionalArgs(a.Packages)...)
+
+	return NewCommand("apk", args)
+}
+
 func getGenericInstallerCommand(installer string, yes bool, packages []string) *Command {
 	args := []Arg{
 		&Subcommand{
@@ -54,3 +95,89 @@ func getGenericInstallerCommand(installer string, yes bool, packages []string) *
 
 	return NewCommand(installer, args)
 }
+
+// Recognizes "<binary> install [yes flag] PACKAGES...", with the flags in any
+// position.
+func recognizeGenericInstall(argv []string, yesFlags ...string) (bool, []string, bool) {
+	yes := false
+
+	flags := &argvFlags{switches: map[string]*bool{}}
+	for _, flag := range yesFlags {
+		flags.switches[flag] = &yes
+	}
+
+	positionals, ok := flags.parse(argv[1:])
+	if !ok || len(positionals) < 2 || positionals[0] != "install" {
+		return false, nil, false
+	}
+
+	return yes, positionals[1:], true
+}
+
+func recognizeDnfInstall(argv []string) (Commander, bool) {
+	yes, packages, ok := recognizeGenericInstall(argv, "-y", "--assumeyes")
+	if !ok {
+		return nil, false
+	}
+
+	return &DnfInstall{Yes: yes, Packages: packages}, true
+}
+
+func recognizeYumInstall(argv []string) (Commander, bool) {
+	yes, packages, ok := recognizeGenericInstall(argv, "-y", "--assumeyes")
+	if !ok {
+		return nil, false
+	}
+
+	return &YumInstall{Yes: yes, Packages: packages}, true
+}
+
+func recognizeAptGetInstall(argv []string) (Commander, bool) {
+	yes, packages, ok := recognizeGenericInstall(argv, "-y", "--yes", "--assume-yes")
+	if !ok {
+		return nil, false
+	}
+
+	return &AptGetInstall{Yes: yes, Packages: packages}, true
+}
+
+func recognizeAptGetUpdate(argv []string) (Commander, bool) {
+	if len(argv) != 2 || argv[1] != "update" {
+		return nil, false
+	}
+
+	return &AptGetUpdate{}, true
+}
+
+func recognizeMicrodnfInstall(argv []string) (Commander, bool) {
+	yes, packages, ok := recognizeGenericInstall(argv, "-y", "--assumeyes")
+	if !ok {
+		return nil, false
+	}
+
+	return &MicrodnfInstall{Yes: yes, Packages: packages}, true
+}
+
+func recognizeZypperInstall(argv []string) (Commander, bool) {
+	yes, packages, ok := recognizeGenericInstall(argv, "-y", "--no-confirm")
+	if !ok {
+		return nil, false
+	}
+
+	return &ZypperInstall{Yes: yes, Packages: packages}, true
+}
+
+func recognizeApkAdd(argv []string) (Commander, bool) {
+	a := &ApkAdd{}
+
+	flags := &argvFlags{switches: map[string]*bool{"--no-cache": &a.NoCache}}
+
+	positionals, ok := flags.parse(argv[1:])
+	if !ok || len(positionals) < 2 || positionals[0] != "add" {
+		return nil, false
+	}
+
+	a.Packages = positionals[1:]
+
+	return a, true
+}
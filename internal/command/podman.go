@@ -1,6 +1,9 @@
 package command
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // Represents a label given to podman or buildah build
 type Label struct {
@@ -11,10 +14,14 @@ type Label struct {
 func (l *Label) flag() Flag {
 	return &DoubleValueFlag{
 		Name:  "label",
-		Value: fmt.Sprintf("%s=%s", l.Name, l.Value),
+		Value: l.render(),
 	}
 }
 
+func (l *Label) render() string {
+	return fmt.Sprintf("%s=%s", l.Name, l.Value)
+}
+
 // Represents a build arg passed to podman or buildah build
 type BuildArg struct {
 	Name  string
@@ -109,42 +116,11 @@ func (p *PodmanPush) Command() *Command {
 
 // Represents a podman build command
 type PodmanBuild struct {
-	BuildContext string
-	Tag          string
-	Target       string
-	BuildArgs    []BuildArg
-	Labels       []Label
-	File         string
+	BuildOpts
 }
 
 func (p *PodmanBuild) Command() *Command {
-	buildFlags := mapToValFlags(map[string]string{
-		"tag":    p.Tag,
-		"target": p.Target,
-		"file":   p.File,
-	})
-
-	for _, buildArg := range p.BuildArgs {
-		buildFlags = append(buildFlags, buildArg.flag())
-	}
-
-	for _, label := range p.Labels {
-		buildFlags = append(buildFlags, label.flag())
-	}
-
-	buildCtx := p.BuildContext
-	if buildCtx == "" {
-		buildCtx = "."
-	}
-
-	return NewCommand("podman", []Arg{
-		&Subcommand{
-			Name:  "build",
-			Flags: buildFlags,
-		},
-
-		PositionalArg(buildCtx),
-	})
+	return p.BuildOpts.command("podman", nil)
 }
 
 // Represents a podman run command.
@@ -196,7 +172,430 @@ func (p *PodmanRun) Command() *Command {
 	}, p.ImageOpts...))
 }
 
-// Represents an unimplemented podman pull command
+// Represents a podman pull command.
 type PodmanPull struct {
-	Image string
+	Arch      string
+	Authfile  string
+	Image     string
+	Platform  string
+	Quiet     bool
+	TLSVerify *bool
+}
+
+func (p *PodmanPull) Command() *Command {
+	pullFlags := mapToSwitchFlags(map[string]bool{
+		"quiet": p.Quiet,
+	})
+
+	pullFlags = append(pullFlags, mapToValFlags(map[string]string{
+		"arch":     p.Arch,
+		"authfile": p.Authfile,
+		"platform": p.Platform,
+	})...)
+
+	pullFlags = append(pullFlags, mapToOptSwitchFlags(map[string]*bool{
+		"tls-verify": p.TLSVerify,
+	})...)
+
+	return NewCommand("podman", []Arg{
+		&Subcommand{
+			Name:  "pull",
+			Flags: pullFlags,
+		},
+		PositionalArg(p.Image),
+	})
+}
+
+// Represents a podman exec command.
+type PodmanExec struct {
+	Interactive bool
+	Tty         bool
+	Detach      bool
+	Privileged  bool
+	User        string
+	Workdir     string
+	Env         []PodmanEnv
+	Container   string
+	ExecOpts    []Arg
+}
+
+func (p *PodmanExec) Command() *Command {
+	execFlags := mapToSwitchFlags(map[string]bool{
+		"interactive": p.Interactive,
+		"tty":         p.Tty,
+		"detach":      p.Detach,
+		"privileged":  p.Privileged,
+	})
+
+	execFlags = append(execFlags, mapToValFlags(map[string]string{
+		"user":    p.User,
+		"workdir": p.Workdir,
+	})...)
+
+	for _, env := range p.Env {
+		execFlags = append(execFlags, env.flag())
+	}
+
+	return NewCommand("podman", append([]Arg{
+		&Subcommand{
+			Name:  "exec",
+			Flags: execFlags,
+		},
+		PositionalArg(p.Container),
+	}, p.ExecOpts...))
+}
+
+// Represents a podman stop command.
+type PodmanStop struct {
+	All        bool
+	Ignore     bool
+	Time       string
+	Containers []string
+}
+
+func (p *PodmanStop) Command() *Command {
+	stopFlags := mapToSwitchFlags(map[string]bool{
+		"all":    p.All,
+		"ignore": p.Ignore,
+	})
+
+	stopFlags = append(stopFlags, mapToValFlags(map[string]string{
+		"time": p.Time,
+	})...)
+
+	return NewCommand("podman", append([]Arg{
+		&Subcommand{
+			Name:  "stop",
+			Flags: stopFlags,
+		},
+	}, itemsToPositionalArgs(p.Containers)...))
+}
+
+// Represents a podman rm command.
+type PodmanRm struct {
+	All        bool
+	Force      bool
+	Ignore     bool
+	Volumes    bool
+	Time       string
+	Containers []string
+}
+
+func (p *PodmanRm) Command() *Command {
+	rmFlags := mapToSwitchFlags(map[string]bool{
+		"all":     p.All,
+		"force":   p.Force,
+		"ignore":  p.Ignore,
+		"volumes": p.Volumes,
+	})
+
+	rmFlags = append(rmFlags, mapToValFlags(map[string]string{
+		"time": p.Time,
+	})...)
+
+	return NewCommand("podman", append([]Arg{
+		&Subcommand{
+			Name:  "rm",
+			Flags: rmFlags,
+		},
+	}, itemsToPositionalArgs(p.Containers)...))
+}
+
+// Represents a podman logs command.
+type PodmanLogs struct {
+	Follow     bool
+	Timestamps bool
+	Since      string
+	Until      string
+	Tail       string
+	Container  string
+}
+
+func (p *PodmanLogs) Command() *Command {
+	logsFlags := mapToSwitchFlags(map[string]bool{
+		"follow":     p.Follow,
+		"timestamps": p.Timestamps,
+	})
+
+	logsFlags = append(logsFlags, mapToValFlags(map[string]string{
+		"since": p.Since,
+		"until": p.Until,
+		"tail":  p.Tail,
+	})...)
+
+	return NewCommand("podman", []Arg{
+		&Subcommand{
+			Name:  "logs",
+			Flags: logsFlags,
+		},
+		PositionalArg(p.Container),
+	})
+}
+
+// Represents a podman inspect command.
+type PodmanInspect struct {
+	Format string
+	Type   string
+	Names  []string
+}
+
+func (p *PodmanInspect) Command() *Command {
+	return NewCommand("podman", append([]Arg{
+		&Subcommand{
+			Name: "inspect",
+			Flags: mapToValFlags(map[string]string{
+				"format": p.Format,
+				"type":   p.Type,
+			}),
+		},
+	}, itemsToPositionalArgs(p.Names)...))
+}
+
+// Represents a podman cp command. Either Src or Dest should be prefixed with
+// the container name, e.g., "my-container:/etc/hosts".
+type PodmanCp struct {
+	Archive   *bool
+	Overwrite bool
+	Src       string
+	Dest      string
+}
+
+func (p *PodmanCp) Command() *Command {
+	cpFlags := mapToSwitchFlags(map[string]bool{
+		"overwrite": p.Overwrite,
+	})
+
+	cpFlags = append(cpFlags, mapToOptSwitchFlags(map[string]*bool{
+		"archive": p.Archive,
+	})...)
+
+	return NewCommand("podman", []Arg{
+		&Subcommand{
+			Name:  "cp",
+			Flags: cpFlags,
+		},
+		PositionalArg(p.Src),
+		PositionalArg(p.Dest),
+	})
+}
+
+// Represents a podman save command.
+type PodmanSave struct {
+	Compress          bool
+	MultiImageArchive bool
+	Quiet             bool
+	Format            string
+	Output            string
+	Images            []string
+}
+
+func (p *PodmanSave) Command() *Command {
+	saveFlags := mapToSwitchFlags(map[string]bool{
+		"compress":            p.Compress,
+		"multi-image-archive": p.MultiImageArchive,
+		"quiet":               p.Quiet,
+	})
+
+	saveFlags = append(saveFlags, mapToValFlags(map[string]string{
+		"format": p.Format,
+		"output": p.Output,
+	})...)
+
+	return NewCommand("podman", append([]Arg{
+		&Subcommand{
+			Name:  "save",
+			Flags: saveFlags,
+		},
+	}, itemsToPositionalArgs(p.Images)...))
+}
+
+// Represents a podman load command.
+type PodmanLoad struct {
+	Quiet bool
+	Input string
+}
+
+func (p *PodmanLoad) Command() *Command {
+	loadFlags := mapToSwitchFlags(map[string]bool{
+		"quiet": p.Quiet,
+	})
+
+	loadFlags = append(loadFlags, mapToValFlags(map[string]string{
+		"input": p.Input,
+	})...)
+
+	return NewCommand("podman", []Arg{
+		&Subcommand{
+			Name:  "load",
+			Flags: loadFlags,
+		},
+	})
+}
+
+// Represents a podman image rm command.
+type PodmanImageRm struct {
+	All    bool
+	Force  bool
+	Ignore bool
+	Images []string
+}
+
+func (p *PodmanImageRm) Command() *Command {
+	return NewCommand("podman", append([]Arg{
+		PositionalArg("image"),
+		&Subcommand{
+			Name: "rm",
+			Flags: mapToSwitchFlags(map[string]bool{
+				"all":    p.All,
+				"force":  p.Force,
+				"ignore": p.Ignore,
+			}),
+		},
+	}, itemsToPositionalArgs(p.Images)...))
+}
+
+// Represents a podman manifest create command.
+type PodmanManifestCreate struct {
+	All    bool
+	Amend  bool
+	Name   string
+	Images []string
+}
+
+func (p *PodmanManifestCreate) Command() *Command {
+	return manifestCreateCommand("podman", p.All, p.Amend, p.Name, p.Images)
+}
+
+// Represents a podman manifest add command.
+type PodmanManifestAdd struct {
+	All       bool
+	Arch      string
+	Authfile  string
+	OS        string
+	Variant   string
+	TLSVerify *bool
+	List      string
+	Image     string
+}
+
+func (p *PodmanManifestAdd) Command() *Command {
+	addFlags := manifestFlags(p.All, map[string]string{
+		"arch":     p.Arch,
+		"authfile": p.Authfile,
+		"os":       p.OS,
+		"variant":  p.Variant,
+	}, p.TLSVerify)
+
+	return manifestCommand("podman", "add", addFlags, p.List, p.Image)
+}
+
+// Represents a podman manifest push command. If Destination is empty, the
+// manifest list is pushed to the location given by its name.
+type PodmanManifestPush struct {
+	All         bool
+	Authfile    string
+	Digestfile  string
+	Format      string
+	TLSVerify   *bool
+	List        string
+	Destination string
+}
+
+func (p *PodmanManifestPush) Command() *Command {
+	pushFlags := manifestFlags(p.All, map[string]string{
+		"authfile":   p.Authfile,
+		"digestfile": p.Digestfile,
+		"format":     p.Format,
+	}, p.TLSVerify)
+
+	return manifestCommand("podman", "push", pushFlags, p.List, p.Destination)
+}
+
+func recognizePodmanBuild(argv []string) (Commander, bool) {
+	opts, ok := recognizeBuildOpts(argv, []string{"build"}, nil)
+	if !ok {
+		return nil, false
+	}
+
+	return &PodmanBuild{BuildOpts: *opts}, true
+}
+
+func recognizePodmanRun(argv []string) (Commander, bool) {
+	if len(argv) < 3 || argv[1] != "run" {
+		return nil, false
+	}
+
+	p := &PodmanRun{}
+	volumes := []string{}
+	env := []string{}
+
+	flags := &argvFlags{
+		switches: map[string]*bool{
+			"-i":            &p.Interactive,
+			"--interactive": &p.Interactive,
+			"-t":            &p.Tty,
+			"--tty":         &p.Tty,
+			"--rm":          &p.Remove,
+			"-d":            &p.Detach,
+			"--detach":      &p.Detach,
+		},
+		values: map[string]*string{
+			"--name":       &p.Name,
+			"-w":           &p.Workdir,
+			"--workdir":    &p.Workdir,
+			"--entrypoint": &p.Entrypoint,
+		},
+		repeated: map[string]*[]string{
+			"-v":       &volumes,
+			"--volume": &volumes,
+			"-e":       &env,
+			"--env":    &env,
+		},
+		stopAtPositional: true,
+	}
+
+	positionals, ok := flags.parse(argv[2:])
+	if !ok || len(positionals) == 0 {
+		return nil, false
+	}
+
+	p.Image = positionals[0]
+	p.ImageOpts = itemsToPositionalArgs(positionals[1:])
+
+	for _, volume := range volumes {
+		parsed, ok := parseVolume(volume)
+		if !ok {
+			return nil, false
+		}
+
+		p.Volumes = append(p.Volumes, parsed)
+	}
+
+	// An env var given without a value is passed through from the host, which
+	// PodmanEnv cannot represent.
+	parsedEnv, ok := parseLabels(env)
+	if !ok {
+		return nil, false
+	}
+
+	for _, item := range parsedEnv {
+		p.Env = append(p.Env, PodmanEnv(item))
+	}
+
+	return p, true
+}
+
+// Parses the value of a --volume flag, e.g., /host:/container:z.
+func parseVolume(s string) (Volume, bool) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) < 2 {
+		return Volume{}, false
+	}
+
+	v := Volume{HostPath: parts[0], ContainerPath: parts[1]}
+	if len(parts) == 3 {
+		v.Opts = parts[2]
+	}
+
+	return v, true
 }
@@ -0,0 +1,141 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPodmanLifecycleCommands(t *testing.T) {
+	tlsVerify := false
+
+	testCases := []struct {
+		name     string
+		input    interface{ Command() *Command }
+		expected string
+	}{
+		{
+			name: "Pull",
+			input: &PodmanPull{
+				Arch:      "arm64",
+				Authfile:  "/path/to/authfile",
+				Platform:  "linux/arm64",
+				TLSVerify: &tlsVerify,
+				Image:     "quay.io/org/image:latest",
+			},
+			expected: "podman pull --arch arm64 --authfile /path/to/authfile --platform linux/arm64 --tls-verify=false quay.io/org/image:latest",
+		},
+		{
+			name: "Exec",
+			input: &PodmanExec{
+				Interactive: true,
+				Tty:         true,
+				User:        "root",
+				Env:         []PodmanEnv{{Name: "HOME", Value: "/root"}},
+				Container:   "my-container",
+				ExecOpts:    ArgLiterals{"ls", "-la"}.Arg(),
+			},
+			expected: "podman exec --interactive --tty --user root --env HOME=/root my-container ls -la",
+		},
+		{
+			name: "Stop",
+			input: &PodmanStop{
+				Ignore:     true,
+				Time:       "5",
+				Containers: []string{"one", "two"},
+			},
+			expected: "podman stop --ignore --time 5 one two",
+		},
+		{
+			name: "Rm",
+			input: &PodmanRm{
+				Force:      true,
+				Volumes:    true,
+				Containers: []string{"my-container"},
+			},
+			expected: "podman rm --force --volumes my-container",
+		},
+		{
+			name: "Logs",
+			input: &PodmanLogs{
+				Follow:    true,
+				Since:     "10m",
+				Container: "my-container",
+			},
+			expected: "podman logs --follow --since 10m my-container",
+		},
+		{
+			name: "Inspect",
+			input: &PodmanInspect{
+				Format: "{{.Id}}",
+				Names:  []string{"my-container"},
+			},
+			expected: "podman inspect --format {{.Id}} my-container",
+		},
+		{
+			name: "Cp",
+			input: &PodmanCp{
+				Src:  "my-container:/etc/hosts",
+				Dest: "/tmp/hosts",
+			},
+			expected: "podman cp my-container:/etc/hosts /tmp/hosts",
+		},
+		{
+			name: "Save",
+			input: &PodmanSave{
+				Format: "oci-archive",
+				Output: "/tmp/image.tar",
+				Images: []string{"quay.io/org/image:latest"},
+			},
+			expected: "podman save --format oci-archive --output /tmp/image.tar quay.io/org/image:latest",
+		},
+		{
+			name: "Load",
+			input: &PodmanLoad{
+				Input: "/tmp/image.tar",
+			},
+			expected: "podman load --input /tmp/image.tar",
+		},
+		{
+			name: "Image rm",
+			input: &PodmanImageRm{
+				Force:  true,
+				Images: []string{"quay.io/org/image:latest"},
+			},
+			expected: "podman image rm --force quay.io/org/image:latest",
+		},
+		{
+			name: "Manifest create",
+			input: &PodmanManifestCreate{
+				Name: "quay.io/org/image:latest",
+			},
+			expected: "podman manifest create quay.io/org/image:latest",
+		},
+		{
+			name: "Manifest add",
+			input: &PodmanManifestAdd{
+				Arch:  "arm64",
+				OS:    "linux",
+				List:  "quay.io/org/image:latest",
+				Image: "quay.io/org/image:arm64",
+			},
+			expected: "podman manifest add --arch arm64 --os linux quay.io/org/image:latest quay.io/org/image:arm64",
+		},
+		{
+			name: "Manifest push",
+			input: &PodmanManifestPush{
+				All:         true,
+				Authfile:    "/path/to/authfile",
+				List:        "localhost/image:latest",
+				Destination: "docker://quay.io/org/image:latest",
+			},
+			expected: "podman manifest push --all --authfile /path/to/authfile localhost/image:latest docker://quay.io/org/image:latest",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, testCase.input.Command().String())
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"reflect"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Describes how a command should be executed: how long each attempt may run
+// for and whether, and how, failed attempts are retried.
+type Policy struct {
+	// The maximum duration of a single attempt. Zero means no timeout.
+	Timeout time.Duration
+	// The number of additional attempts to make after the first one fails.
+	Retries int
+	// The delay before the first retry. Defaults to one second.
+	Backoff time.Duration
+	// The factor the delay is multiplied by after each retry. Defaults to 2.
+	Multiplier float64
+	// The upper bound for the delay between attempts. Zero means no bound.
+	MaxBackoff time.Duration
+	// The fraction, between 0 and 1, by which each delay is randomly varied in
+	// either direction so that concurrent retries do not happen in lockstep.
+	Jitter float64
+	// If set, only failures with one of these exit codes are retried.
+	RetryOnExitCodes []int
+	// If set, only failures whose stderr matches one of these patterns are
+	// retried. When both this and RetryOnExitCodes are set, matching either is
+	// sufficient.
+	RetryOnStderr []*regexp.Regexp
+	// Whether attempts which exceeded the timeout are retried.
+	RetryOnTimeout bool
+}
+
+// A sensible policy for commands which talk to a registry, such as logins,
+// pushes, pulls, and image extractions.
+var NetworkPolicy = &Policy{
+	Timeout:        10 * time.Minute,
+	Retries:        3,
+	Backoff:        2 * time.Second,
+	Multiplier:     2,
+	MaxBackoff:     30 * time.Second,
+	Jitter:         0.2,
+	RetryOnTimeout: true,
+	RetryOnStderr: []*regexp.Regexp{
+		regexp.MustCompile(`(?i)connection (refused|reset)`),
+		regexp.MustCompile(`(?i)i/o timeout`),
+		regexp.MustCompile(`(?i)TLS handshake timeout`),
+		regexp.MustCompile(`(?i)too many requests`),
+		regexp.MustCompile(`(?i)(502 Bad Gateway|503 Service Unavailable|504 Gateway Timeout)`),
+		regexp.MustCompile(`(?i)unexpected EOF`),
+	},
+}
+
+// Records the outcome of a single attempt at running a command.
+type Attempt struct {
+	Number   int
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+	ExitCode int
+	TimedOut bool
+}
+
+// Returned when an attempt exceeds the policy timeout.
+type TimeoutError struct {
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s", e.Timeout)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Attaches the given execution policy to the command. Executors only ever
+// make a single attempt; the policy is applied by ExecuteWithPolicy, which
+// Runner and plan.Execute use.
+func (c *Command) WithPolicy(p *Policy) *Command {
+	c.policy = p
+	return c
+}
+
+// Returns the execution policy attached to the command, if any.
+func (c *Command) Policy() *Policy {
+	return c.policy
+}
+
+// Overridden in tests so that they need not actually wait.
+var sleep = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Executes the command with the given executor, honoring the policy attached
+// to the command. Output from every attempt is written to the given writers.
+// Each attempt is returned, even if the command ultimately fails.
+func ExecuteWithPolicy(ctx context.Context, executor Executor, cmd *Command, stdout, stderr io.Writer) ([]Attempt, error) {
+	policy := cmd.Policy()
+	if policy == nil {
+		policy = &Policy{}
+	}
+
+	attempts := []Attempt{}
+
+	for i := 0; ; i++ {
+		attempt, stderrBuf := policy.attempt(ctx, executor, cmd, stdout, stderr)
+		attempt.Number = i + 1
+		attempts = append(attempts, attempt)
+
+		if attempt.Err == nil {
+			return attempts, nil
+		}
+
+		if i >= policy.Retries || ctx.Err() != nil || !policy.retryable(attempt, stderrBuf) {
+			return attempts, attempt.Err
+		}
+
+		if err := sleep(ctx, policy.delay(i)); err != nil {
+			return attempts, errors.Join(attempt.Err, err)
+		}
+	}
+}
+
+func (p *Policy) attempt(ctx context.Context, executor Executor, cmd *Command, stdout, stderr io.Writer) (Attempt, []byte) {
+	attemptCtx := ctx
+	cancel := func() {}
+
+	if p.Timeout != 0 {
+		attemptCtx, cancel = context.WithTimeout(ctx, p.Timeout)
+	}
+
+	defer cancel()
+
+	stderrBuf := &bytes.Buffer{}
+
+	// Teeing stderr means that os/exec no longer sees a single writer for both
+	// streams and copies each of them concurrently. Since callers commonly
+	// pass the same unsynchronized writer for both, writes are serialized.
+	mu := &sync.Mutex{}
+	attemptStderr := &lockedWriter{mu: mu, w: io.MultiWriter(stderr, stderrBuf)}
+	attemptStdout := stdout
+
+	if sameWriter(stdout, stderr) {
+		attemptStdout = &lockedWriter{mu: mu, w: stdout}
+	}
+
+	attempt := Attempt{Start: time.Now()}
+	attempt.Err = executor.Execute(attemptCtx, cmd, attemptStdout, attemptStderr)
+	attempt.Duration = time.Since(attempt.Start)
+
+	// The parent context being done takes precedence over the timeout.
+	if attempt.Err != nil && ctx.Err() == nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) {
+		attempt.TimedOut = true
+		attempt.Err = &TimeoutError{Timeout: p.Timeout, Err: attempt.Err}
+	}
+
+	attempt.ExitCode = ExitCode(attempt.Err)
+
+	return attempt, stderrBuf.Bytes()
+}
+
+// Serializes writes to the underlying writer with a mutex which may be
+// shared with other writers.
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.w.Write(b)
+}
+
+// Reports whether the given writers are the same, without panicking on
+// writers whose types are not comparable.
+func sameWriter(a, b io.Writer) bool {
+	if a == nil || b == nil {
+		return false
+	}
+
+	t := reflect.TypeOf(a)
+	if t != reflect.TypeOf(b) || !t.Comparable() {
+		return false
+	}
+
+	return a == b
+}
+
+// Determines whether the given failed attempt should be retried.
+func (p *Policy) retryable(attempt Attempt, stderr []byte) bool {
+	if attempt.TimedOut {
+		return p.RetryOnTimeout
+	}
+
+	if len(p.RetryOnExitCodes) == 0 && len(p.RetryOnStderr) == 0 {
+		return true
+	}
+
+	if slices.Contains(p.RetryOnExitCodes, attempt.ExitCode) {
+		return true
+	}
+
+	for _, pattern := range p.RetryOnStderr {
+		if pattern.Match(stderr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Computes the delay before the given retry, starting at zero.
+func (p *Policy) delay(retry int) time.Duration {
+	backoff := p.Backoff
+	if backoff == 0 {
+		backoff = time.Second
+	}
+
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+
+	delay := float64(backoff) * math.Pow(multiplier, float64(retry))
+
+	if p.MaxBackoff != 0 && delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		delay *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+
+	return time.Duration(delay)
+}
@@ -0,0 +1,180 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Replaces the sleep function for the duration of the test, recording each
+// requested delay.
+func recordSleeps(t *testing.T) *[]time.Duration {
+	t.Helper()
+
+	delays := &[]time.Duration{}
+	orig := sleep
+	sleep = func(_ context.Context, d time.Duration) error {
+		*delays = append(*delays, d)
+		return nil
+	}
+
+	t.Cleanup(func() { sleep = orig })
+
+	return delays
+}
+
+// An executor which fails with the given stderr for the first n calls and
+// succeeds thereafter.
+type flakyExecutor struct {
+	failures int
+	stderr   string
+	calls    int
+}
+
+func (f *flakyExecutor) Execute(_ context.Context, _ *Command, _, stderr io.Writer) error {
+	f.calls++
+	if f.calls > f.failures {
+		return nil
+	}
+
+	io.WriteString(stderr, f.stderr)
+	return &ExitError{Code: 125}
+}
+
+func TestExecuteWithPolicy(t *testing.T) {
+	testCases := []struct {
+		name           string
+		policy         *Policy
+		executor       *flakyExecutor
+		expectedErr    string
+		expectedDelays []time.Duration
+		expectedCodes  []int
+	}{
+		{
+			name:          "No policy",
+			executor:      &flakyExecutor{failures: 1},
+			expectedErr:   "exit status 125",
+			expectedCodes: []int{125},
+		},
+		{
+			name:           "Exponential backoff with cap",
+			policy:         &Policy{Retries: 4, Backoff: time.Second, MaxBackoff: 3 * time.Second},
+			executor:       &flakyExecutor{failures: 4},
+			expectedDelays: []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second},
+			expectedCodes:  []int{125, 125, 125, 125, 0},
+		},
+		{
+			name:           "Retries exhausted",
+			policy:         &Policy{Retries: 1, Backoff: time.Second, Multiplier: 3},
+			executor:       &flakyExecutor{failures: 5},
+			expectedErr:    "exit status 125",
+			expectedDelays: []time.Duration{time.Second},
+			expectedCodes:  []int{125, 125},
+		},
+		{
+			name:          "Exit code not retryable",
+			policy:        &Policy{Retries: 3, RetryOnExitCodes: []int{1}},
+			executor:      &flakyExecutor{failures: 1},
+			expectedErr:   "exit status 125",
+			expectedCodes: []int{125},
+		},
+		{
+			name: "Stderr pattern retryable",
+			policy: &Policy{
+				Retries:       3,
+				Backoff:       time.Millisecond,
+				RetryOnStderr: []*regexp.Regexp{regexp.MustCompile("connection reset")},
+			},
+			executor:       &flakyExecutor{failures: 1, stderr: "Error: read tcp: connection reset by peer\n"},
+			expectedDelays: []time.Duration{time.Millisecond},
+			expectedCodes:  []int{125, 0},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			delays := recordSleeps(t)
+
+			cmd := CommandLiteral{"buildah", "push", "quay.io/org/image"}.Command().WithPolicy(testCase.policy)
+
+			attempts, err := ExecuteWithPolicy(context.Background(), testCase.executor, cmd, &strings.Builder{}, &strings.Builder{})
+			if testCase.expectedErr != "" {
+				assert.EqualError(t, err, testCase.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			codes := []int{}
+			for i, attempt := range attempts {
+				assert.Equal(t, i+1, attempt.Number)
+				codes = append(codes, attempt.ExitCode)
+			}
+
+			assert.Equal(t, testCase.expectedCodes, codes)
+			if testCase.expectedDelays == nil {
+				assert.Empty(t, *delays)
+			} else {
+				assert.Equal(t, testCase.expectedDelays, *delays)
+			}
+		})
+	}
+}
+
+func TestExecuteWithPolicyTimeout(t *testing.T) {
+	recordSleeps(t)
+
+	fake := &FakeExecutor{
+		Default: FakeResponse{
+			Func: func(ctx context.Context, _ *Command) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		},
+	}
+
+	cmd := CommandLiteral{"oc", "image", "extract"}.Command().WithPolicy(&Policy{
+		Timeout:        time.Millisecond,
+		Retries:        2,
+		RetryOnTimeout: true,
+	})
+
+	attempts, err := ExecuteWithPolicy(context.Background(), fake, cmd, &strings.Builder{}, &strings.Builder{})
+	assert.EqualError(t, err, "timed out after 1ms")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Len(t, attempts, 3)
+	assert.True(t, attempts[2].TimedOut)
+}
+
+func TestPolicyJitter(t *testing.T) {
+	policy := &Policy{Backoff: time.Second, Jitter: 0.5}
+
+	for i := 0; i < 100; i++ {
+		delay := policy.delay(0)
+		assert.GreaterOrEqual(t, delay, 500*time.Millisecond)
+		assert.LessOrEqual(t, delay, 1500*time.Millisecond)
+	}
+}
+
+func TestExecuteWithPolicySharedWriter(t *testing.T) {
+	// Runner and plan.Execute pass the same writer for stdout and stderr. Run
+	// with -race to ensure that it is not written to concurrently.
+	cmd := CommandLiteral{"sh", "-c", "for i in $(seq 1 200); do echo out; echo err >&2; done; exit 3"}.Command().WithPolicy(&Policy{
+		RetryOnStderr: []*regexp.Regexp{regexp.MustCompile(`never`)},
+	})
+
+	out := &bytes.Buffer{}
+
+	attempts, err := ExecuteWithPolicy(context.Background(), ExecExecutor{}, cmd, out, out)
+	assert.Error(t, err)
+	require.Len(t, attempts, 1)
+	assert.Equal(t, 3, attempts[0].ExitCode)
+	assert.Equal(t, 200, strings.Count(out.String(), "out\n"))
+	assert.Equal(t, 200, strings.Count(out.String(), "err\n"))
+}
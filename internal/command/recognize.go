@@ -0,0 +1,192 @@
+package command
+
+import (
+	"path"
+	"strings"
+	"sync"
+)
+
+// Turns argv back into a typed builder. A recognizer returns false when argv
+// is not for its builder or uses options which the builder cannot represent,
+// so that the builder renders an equivalent command.
+type Recognizer func(argv []string) (Commander, bool)
+
+var (
+	recognizersMu sync.RWMutex
+	recognizers   = map[string][]Recognizer{
+		"apk":        {recognizeApkAdd},
+		"apt-get":    {recognizeAptGetInstall, recognizeAptGetUpdate},
+		"buildah":    {recognizeBuildahBuild},
+		"chmod":      {recognizeChmod},
+		"dnf":        {recognizeDnfInstall},
+		"microdnf":   {recognizeMicrodnfInstall},
+		"podman":     {recognizePodmanBuild, recognizePodmanRun},
+		"rm":         {recognizeDelete},
+		"rpm-ostree": {recognizeRpmOstreeInstall},
+		"useradd":    {recognizeUseradd},
+		"yum":        {recognizeYumInstall},
+		"zypper":     {recognizeZypperInstall},
+	}
+)
+
+// Registers a recognizer for commands run with the given binary. Recognizers
+// are tried in the order in which they were registered.
+func RegisterRecognizer(binary string, r Recognizer) {
+	recognizersMu.Lock()
+	defer recognizersMu.Unlock()
+
+	recognizers[binary] = append(recognizers[binary], r)
+}
+
+// Returns the typed builder for the given argv, if one recognizes it. The
+// binary may be given as a path, e.g., /usr/bin/dnf.
+func Recognize(argv []string) (Commander, bool) {
+	if len(argv) == 0 {
+		return nil, false
+	}
+
+	recognizersMu.RLock()
+	candidates := recognizers[path.Base(argv[0])]
+	recognizersMu.RUnlock()
+
+	for _, recognize := range candidates {
+		if cmd, ok := recognize(argv); ok {
+			return cmd, true
+		}
+	}
+
+	return nil, false
+}
+
+// Describes the flags which a recognizer accepts. Each flag is keyed by every
+// spelling it may take, e.g., both -y and --assumeyes.
+type argvFlags struct {
+	switches map[string]*bool
+	// Flags which take a value either as the next arg or after an equal sign.
+	values map[string]*string
+	// Flags which only take a value after an equal sign, defaulting to "true"
+	// when given bare, e.g., --pull.
+	optValues map[string]*string
+	// Flags which may be given more than once.
+	repeated map[string]*[]string
+	// Stop parsing flags at the first positional arg so that the remainder can
+	// be passed to something else, e.g., podman run IMAGE ARGS...
+	stopAtPositional bool
+}
+
+// Sets the flag targets from the given args and returns the positional args.
+// Returns false if an unknown flag is encountered, a flag is missing its
+// value, or a single-valued flag is given more than once.
+func (f *argvFlags) parse(args []string) ([]string, bool) {
+	positionals := []string{}
+	seen := map[*string]bool{}
+
+	setValue := func(target *string, val string) bool {
+		if seen[target] {
+			return false
+		}
+
+		seen[target] = true
+		*target = val
+		return true
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "--" {
+			return append(positionals, args[i+1:]...), true
+		}
+
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if f.stopAtPositional {
+				return append(positionals, args[i:]...), true
+			}
+
+			positionals = append(positionals, arg)
+			continue
+		}
+
+		name, val, hasVal := strings.Cut(arg, "=")
+
+		if target, ok := f.switches[name]; ok && !hasVal {
+			*target = true
+			continue
+		}
+
+		if target, ok := f.optValues[name]; ok {
+			if !hasVal {
+				val = "true"
+			}
+
+			if !setValue(target, val) {
+				return nil, false
+			}
+
+			continue
+		}
+
+		target, isValue := f.values[name]
+		repeated, isRepeated := f.repeated[name]
+
+		if isValue || isRepeated {
+			if !hasVal {
+				i++
+				if i == len(args) {
+					return nil, false
+				}
+
+				val = args[i]
+			}
+
+			if isRepeated {
+				*repeated = append(*repeated, val)
+			} else if !setValue(target, val) {
+				return nil, false
+			}
+
+			continue
+		}
+
+		// Combined short switches such as -rf or -it.
+		if !strings.HasPrefix(arg, "--") && !hasVal && len(arg) > 2 && f.setCombinedSwitches(arg[1:]) {
+			continue
+		}
+
+		return nil, false
+	}
+
+	return positionals, true
+}
+
+func (f *argvFlags) setCombinedSwitches(chars string) bool {
+	for _, c := range chars {
+		if _, ok := f.switches["-"+string(c)]; !ok {
+			return false
+		}
+	}
+
+	for _, c := range chars {
+		*f.switches["-"+string(c)] = true
+	}
+
+	return true
+}
+
+// Parses "KEY=VAL" items into labels. Returns false if any item lacks an
+// equal sign. No items yields nil so that recognized builders compare equal
+// to those constructed by hand.
+func parseLabels(items []string) ([]Label, bool) {
+	var out []Label
+
+	for _, item := range items {
+		name, val, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, false
+		}
+
+		out = append(out, Label{Name: name, Value: val})
+	}
+
+	return out, true
+}
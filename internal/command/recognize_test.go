@@ -0,0 +1,168 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func argv(cmd *Command) []string {
+	out, _, _ := cmd.ArgvWithSecretRefs(func(name string) string { return name })
+	return out
+}
+
+func TestRecognize(t *testing.T) {
+	layers := false
+
+	testCases := []struct {
+		input    string
+		expected Commander
+	}{
+		{
+			input:    "dnf install -y git vim",
+			expected: &DnfInstall{Yes: true, Packages: []string{"git", "vim"}},
+		},
+		{
+			input:    "/usr/bin/dnf --assumeyes install git",
+			expected: &DnfInstall{Yes: true, Packages: []string{"git"}},
+		},
+		{
+			input:    "yum install golang",
+			expected: &YumInstall{Packages: []string{"golang"}},
+		},
+		{
+			input:    "apt-get install --yes curl",
+			expected: &AptGetInstall{Yes: true, Packages: []string{"curl"}},
+		},
+		{
+			input:    "apt-get update",
+			expected: &AptGetUpdate{},
+		},
+		{
+			input:    "microdnf install -y jq",
+			expected: &MicrodnfInstall{Yes: true, Packages: []string{"jq"}},
+		},
+		{
+			input:    "zypper install -y git",
+			expected: &ZypperInstall{Yes: true, Packages: []string{"git"}},
+		},
+		{
+			input:    "apk add --no-cache bash",
+			expected: &ApkAdd{NoCache: true, Packages: []string{"bash"}},
+		},
+		{
+			input:    "rpm-ostree install htop",
+			expected: &RpmOstreeInstall{Packages: []string{"htop"}},
+		},
+		{
+			input:    "rm -rf /var/cache/dnf",
+			expected: &Delete{Force: true, Recursive: true, Path: "/var/cache/dnf"},
+		},
+		{
+			input:    "chmod -R 0755 /usr/local/bin",
+			expected: &Chmod{Recursive: true, Mode: "0755", Path: "/usr/local/bin"},
+		},
+		{
+			input:    "useradd -m -u 1000 -G wheel,users --shell /bin/bash build",
+			expected: &Useradd{CreateHome: true, UID: "1000", Groups: []string{"wheel", "users"}, Shell: "/bin/bash", Name: "build"},
+		},
+		{
+			input: "podman run -it --rm -v /src:/src:z -e FOO=bar --workdir /src quay.io/fedora/fedora:latest make test",
+			expected: &PodmanRun{
+				Interactive: true,
+				Tty:         true,
+				Remove:      true,
+				Workdir:     "/src",
+				Volumes:     []Volume{{HostPath: "/src", ContainerPath: "/src", Opts: "z"}},
+				Env:         []PodmanEnv{{Name: "FOO", Value: "bar"}},
+				Image:       "quay.io/fedora/fedora:latest",
+				ImageOpts:   []Arg{PositionalArg("make"), PositionalArg("test")},
+			},
+		},
+		{
+			input: "buildah bud --storage-driver vfs -f Containerfile -t quay.io/org/image:latest --build-arg GO_VERSION=1.23 --layers=false --pull --platform linux/amd64,linux/arm64 --secret id=token,src=/run/token ./context",
+			expected: &BuildahBuild{
+				BuildOpts: BuildOpts{
+					File:         "Containerfile",
+					Tag:          "quay.io/org/image:latest",
+					BuildArgs:    []BuildArg{{Name: "GO_VERSION", Value: "1.23"}},
+					Layers:       &layers,
+					Pull:         "true",
+					Platforms:    []string{"linux/amd64", "linux/arm64"},
+					Secrets:      []BuildSecret{{ID: "token", Src: "/run/token"}},
+					BuildContext: "./context",
+				},
+				StorageDriver: "vfs",
+			},
+		},
+		{
+			input: "podman build --label org.opencontainers.image.source=https://github.com/org/repo --no-cache .",
+			expected: &PodmanBuild{
+				BuildOpts: BuildOpts{
+					Labels:  []Label{{Name: "org.opencontainers.image.source", Value: "https://github.com/org/repo"}},
+					NoCache: true,
+				},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.input, func(t *testing.T) {
+			cmd, ok := Recognize(strings.Fields(testCase.input))
+			if !assert.True(t, ok) {
+				return
+			}
+
+			assert.Equal(t, testCase.expected, cmd)
+
+			// The builder must render something which is recognized as itself.
+			roundTripped, ok := Recognize(argv(cmd.Command()))
+			assert.True(t, ok)
+			assert.Equal(t, cmd, roundTripped)
+		})
+	}
+}
+
+func TestRecognizeRejects(t *testing.T) {
+	inputs := []string{
+		"",
+		"unknown-binary --flag",
+		"dnf install",
+		"dnf remove -y git",
+		"dnf install --setopt=install_weak_deps=False git",
+		"rm -rf /a /b",
+		"chmod 0755",
+		"podman run --privileged quay.io/fedora/fedora",
+		"podman run -e HOME quay.io/fedora/fedora",
+		"buildah build -t one -t two .",
+		"buildah build --file",
+		"buildah build --secret src=/run/token .",
+		"useradd -u 1 --uid 2 build",
+	}
+
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			_, ok := Recognize(strings.Fields(input))
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestRegisterRecognizer(t *testing.T) {
+	const binary = "test-recognizer-binary"
+
+	t.Cleanup(func() {
+		recognizersMu.Lock()
+		delete(recognizers, binary)
+		recognizersMu.Unlock()
+	})
+
+	RegisterRecognizer(binary, func(argv []string) (Commander, bool) {
+		return CommandLiteral(argv), true
+	})
+
+	cmd, ok := Recognize([]string{binary, "hello"})
+	assert.True(t, ok)
+	assert.Equal(t, CommandLiteral{binary, "hello"}, cmd)
+}
@@ -18,3 +18,12 @@ func (r *RpmOstreeInstall) Command() *Command {
 
 	return NewCommand("rpm-ostree", args)
 }
+
+func recognizeRpmOstreeInstall(argv []string) (Commander, bool) {
+	positionals, ok := (&argvFlags{}).parse(argv[1:])
+	if !ok || len(positionals) < 2 || positionals[0] != "install" {
+		return nil, false
+	}
+
+	return &RpmOstreeInstall{Packages: positionals[1:]}, true
+}
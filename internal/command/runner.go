@@ -0,0 +1,361 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// A command to be run by the Runner, optionally after other jobs.
+type Job struct {
+	// A unique name for the job. It is used to prefix the job output.
+	Name    string
+	Command *Command
+	// The names of jobs which must succeed before this one may start.
+	DependsOn []string
+}
+
+// Runs independent commands concurrently with a bounded number of workers.
+// Jobs with dependencies form a DAG; a job starts as soon as all of its
+// dependencies have succeeded.
+type Runner struct {
+	// Defaults to running each command as a local process.
+	Executor Executor
+	// The maximum number of commands to run at once. Defaults to the number of
+	// CPUs.
+	Workers int
+	// If set, the output of each command is streamed here with each line
+	// prefixed by the job name.
+	Out io.Writer
+	// When set, the first failure cancels every in-flight job and skips the
+	// remaining ones. Otherwise, only the dependents of a failed job are
+	// skipped.
+	FailFast bool
+}
+
+// The outcome of a single job.
+type JobResult struct {
+	Name    string
+	Command string
+	// The combined stdout and stderr of the command.
+	Output   []byte
+	Err      error
+	ExitCode int
+	// Each attempt made according to the policy attached to the command.
+	Attempts []Attempt
+	// Set when the job did not run because a dependency failed or the run was
+	// cancelled.
+	Skipped  bool
+	Start    time.Time
+	Duration time.Duration
+}
+
+// The aggregated outcome of a run. Results are in the same order as the jobs
+// that were given.
+type RunReport struct {
+	Results  []*JobResult
+	Duration time.Duration
+}
+
+// Returns the jobs which failed.
+func (r *RunReport) Failed() []*JobResult {
+	out := []*JobResult{}
+
+	for _, result := range r.Results {
+		if result.Err != nil {
+			out = append(out, result)
+		}
+	}
+
+	return out
+}
+
+// Returns the jobs which were skipped.
+func (r *RunReport) Skipped() []*JobResult {
+	out := []*JobResult{}
+
+	for _, result := range r.Results {
+		if result.Skipped {
+			out = append(out, result)
+		}
+	}
+
+	return out
+}
+
+// Renders a summary table of the run.
+func (r *RunReport) String() string {
+	sb := &strings.Builder{}
+	tw := tabwriter.NewWriter(sb, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "JOB\tSTATUS\tDURATION")
+
+	for _, result := range r.Results {
+		status := "ok"
+		switch {
+		case result.Skipped:
+			status = "skipped"
+		case result.Err != nil:
+			status = fmt.Sprintf("failed (%s)", result.Err)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", result.Name, status, result.Duration.Round(time.Millisecond))
+	}
+
+	tw.Flush()
+
+	fmt.Fprintf(sb, "%d succeeded, %d failed, %d skipped in %s\n",
+		len(r.Results)-len(r.Failed())-len(r.Skipped()), len(r.Failed()), len(r.Skipped()), r.Duration.Round(time.Millisecond))
+
+	return sb.String()
+}
+
+// Runs each of the given commands concurrently. Each job is named after its
+// index.
+func (r *Runner) RunCommands(ctx context.Context, cmds []*Command) (*RunReport, error) {
+	jobs := []*Job{}
+
+	for i, cmd := range cmds {
+		jobs = append(jobs, &Job{Name: fmt.Sprintf("%d", i), Command: cmd})
+	}
+
+	return r.Run(ctx, jobs)
+}
+
+// Runs the given jobs, respecting their dependencies. An error is returned if
+// the jobs are invalid or if any job failed; the report is returned in the
+// latter case as well.
+func (r *Runner) Run(ctx context.Context, jobs []*Job) (*RunReport, error) {
+	if err := validateJobs(jobs); err != nil {
+		return nil, err
+	}
+
+	executor := r.Executor
+	if executor == nil {
+		executor = ExecExecutor{}
+	}
+
+	workers := r.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+
+	results := map[string]*JobResult{}
+	done := map[string]chan struct{}{}
+
+	for _, job := range jobs {
+		results[job.Name] = &JobResult{Name: job.Name, Command: job.Command.String()}
+		done[job.Name] = make(chan struct{})
+	}
+
+	sem := make(chan struct{}, workers)
+	outMu := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+
+	for _, job := range jobs {
+		wg.Add(1)
+
+		go func(job *Job) {
+			defer wg.Done()
+			defer close(done[job.Name])
+
+			result := results[job.Name]
+
+			for _, dep := range job.DependsOn {
+				<-done[dep]
+
+				if depResult := results[dep]; depResult.Err != nil || depResult.Skipped {
+					result.Skipped = true
+					return
+				}
+			}
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				result.Skipped = true
+				return
+			}
+
+			defer func() { <-sem }()
+
+			if ctx.Err() != nil {
+				result.Skipped = true
+				return
+			}
+
+			buf := &bytes.Buffer{}
+			var out io.Writer = buf
+
+			var pw *prefixWriter
+			if r.Out != nil {
+				pw = &prefixWriter{mu: outMu, out: r.Out, prefix: fmt.Sprintf("[%s] ", job.Name)}
+				out = io.MultiWriter(buf, pw)
+			}
+
+			result.Start = time.Now()
+			result.Attempts, result.Err = ExecuteWithPolicy(ctx, executor, job.Command, out, out)
+			result.Duration = time.Since(result.Start)
+			result.Output = buf.Bytes()
+			result.ExitCode = ExitCode(result.Err)
+
+			if pw != nil {
+				pw.Flush()
+			}
+
+			if result.Err != nil && r.FailFast {
+				cancel()
+			}
+		}(job)
+	}
+
+	wg.Wait()
+
+	report := &RunReport{Duration: time.Since(start)}
+
+	for _, job := range jobs {
+		report.Results = append(report.Results, results[job.Name])
+	}
+
+	if failed := report.Failed(); len(failed) != 0 {
+		errs := []error{}
+		for _, result := range failed {
+			errs = append(errs, fmt.Errorf("job %q failed: %w", result.Name, result.Err))
+		}
+
+		return report, errors.Join(errs...)
+	}
+
+	if err := ctx.Err(); err != nil && len(report.Skipped()) != 0 {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// Ensures that job names are unique, that dependencies exist, and that there
+// are no cycles.
+func validateJobs(jobs []*Job) error {
+	byName := map[string]*Job{}
+
+	for _, job := range jobs {
+		if job.Name == "" {
+			return fmt.Errorf("job name is required")
+		}
+
+		if _, ok := byName[job.Name]; ok {
+			return fmt.Errorf("duplicate job name %q", job.Name)
+		}
+
+		if job.Command == nil {
+			return fmt.Errorf("job %q has no command", job.Name)
+		}
+
+		byName[job.Name] = job
+	}
+
+	for _, job := range jobs {
+		for _, dep := range job.DependsOn {
+			if _, ok := byName[dep]; !ok {
+				return fmt.Errorf("job %q depends upon unknown job %q", job.Name, dep)
+			}
+		}
+	}
+
+	const (
+		visiting = iota + 1
+		visited
+	)
+
+	state := map[string]int{}
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+
+		state[name] = visiting
+
+		for _, dep := range byName[name].DependsOn {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+
+		state[name] = visited
+		return nil
+	}
+
+	for _, job := range jobs {
+		if err := visit(job.Name, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Prefixes each line written to it before writing it to the underlying
+// writer. Partial lines are buffered until a newline is written or Flush is
+// called so that lines from concurrent jobs do not interleave.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+
+	for {
+		idx := bytes.IndexByte(p.buf, '\n')
+		if idx == -1 {
+			break
+		}
+
+		if err := p.writeLine(p.buf[:idx+1]); err != nil {
+			return 0, err
+		}
+
+		p.buf = p.buf[idx+1:]
+	}
+
+	return len(b), nil
+}
+
+// Writes any buffered partial line.
+func (p *prefixWriter) Flush() error {
+	if len(p.buf) == 0 {
+		return nil
+	}
+
+	line := append(p.buf, '\n')
+	p.buf = nil
+
+	return p.writeLine(line)
+}
+
+func (p *prefixWriter) writeLine(line []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, err := fmt.Fprintf(p.out, "%s%s", p.prefix, line)
+	return err
+}
@@ -0,0 +1,157 @@
+package command
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunnerBoundsConcurrency(t *testing.T) {
+	var running, peak int32
+
+	fake := &FakeExecutor{
+		Default: FakeResponse{
+			Stdout: "building\ndone",
+			Func: func(context.Context, *Command) error {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					p := atomic.LoadInt32(&peak)
+					if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+						break
+					}
+				}
+
+				time.Sleep(10 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return nil
+			},
+		},
+	}
+
+	cmds := []*Command{}
+	for _, image := range []string{"a", "b", "c", "d", "e"} {
+		cmds = append(cmds, CommandLiteral{"buildah", "push", image}.Command())
+	}
+
+	out := &strings.Builder{}
+	runner := &Runner{Executor: fake, Workers: 2, Out: out}
+
+	report, err := runner.RunCommands(context.Background(), cmds)
+	require.NoError(t, err)
+
+	assert.LessOrEqual(t, atomic.LoadInt32(&peak), int32(2))
+	assert.Len(t, fake.Executed(), 5)
+	assert.Len(t, report.Results, 5)
+	assert.Equal(t, "buildah push c", report.Results[2].Command)
+	assert.Equal(t, "building\ndone", string(report.Results[2].Output))
+	assert.Contains(t, out.String(), "[2] building\n[2] done\n")
+	assert.Contains(t, report.String(), "5 succeeded, 0 failed, 0 skipped")
+}
+
+func TestRunnerDependencies(t *testing.T) {
+	fake := &FakeExecutor{
+		Responses: map[string]FakeResponse{
+			"buildah build b": {Stderr: "Error: no such file\n", ExitCode: 125},
+		},
+	}
+
+	jobs := []*Job{
+		{Name: "build-a", Command: CommandLiteral{"buildah", "build", "a"}.Command()},
+		{Name: "build-b", Command: CommandLiteral{"buildah", "build", "b"}.Command()},
+		{Name: "push-a", Command: CommandLiteral{"buildah", "push", "a"}.Command(), DependsOn: []string{"build-a"}},
+		{Name: "push-b", Command: CommandLiteral{"buildah", "push", "b"}.Command(), DependsOn: []string{"build-b"}},
+		{Name: "manifest", Command: CommandLiteral{"buildah", "manifest", "push"}.Command(), DependsOn: []string{"push-a", "push-b"}},
+	}
+
+	report, err := (&Runner{Executor: fake, Workers: 4}).Run(context.Background(), jobs)
+	assert.ErrorContains(t, err, `job "build-b" failed: exit status 125`)
+
+	failed := report.Failed()
+	require.Len(t, failed, 1)
+	assert.Equal(t, 125, failed[0].ExitCode)
+	assert.Equal(t, "Error: no such file\n", string(failed[0].Output))
+
+	skipped := []string{}
+	for _, result := range report.Skipped() {
+		skipped = append(skipped, result.Name)
+	}
+
+	assert.Equal(t, []string{"push-b", "manifest"}, skipped)
+	assert.ElementsMatch(t, []string{"buildah build a", "buildah build b", "buildah push a"}, fake.Executed())
+}
+
+func TestRunnerFailFastCancelsInFlight(t *testing.T) {
+	started := make(chan struct{})
+
+	fake := &FakeExecutor{
+		Responses: map[string]FakeResponse{
+			"fail": {
+				ExitCode: 1,
+				Func: func(context.Context, *Command) error {
+					<-started
+					return nil
+				},
+			},
+			"slow": {
+				Func: func(ctx context.Context, _ *Command) error {
+					close(started)
+					<-ctx.Done()
+					return ctx.Err()
+				},
+			},
+		},
+	}
+
+	jobs := []*Job{
+		{Name: "slow", Command: CommandLiteral{"slow"}.Command()},
+		{Name: "fail", Command: CommandLiteral{"fail"}.Command()},
+	}
+
+	report, err := (&Runner{Executor: fake, Workers: 2, FailFast: true}).Run(context.Background(), jobs)
+	assert.ErrorContains(t, err, `job "fail" failed: exit status 1`)
+	assert.ErrorIs(t, report.Results[0].Err, context.Canceled)
+}
+
+func TestRunnerValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		jobs        []*Job
+		errContains string
+	}{
+		{
+			name: "Duplicate name",
+			jobs: []*Job{
+				{Name: "a", Command: CommandLiteral{"a"}.Command()},
+				{Name: "a", Command: CommandLiteral{"a"}.Command()},
+			},
+			errContains: `duplicate job name "a"`,
+		},
+		{
+			name: "Unknown dependency",
+			jobs: []*Job{
+				{Name: "a", Command: CommandLiteral{"a"}.Command(), DependsOn: []string{"missing"}},
+			},
+			errContains: `job "a" depends upon unknown job "missing"`,
+		},
+		{
+			name: "Cycle",
+			jobs: []*Job{
+				{Name: "a", Command: CommandLiteral{"a"}.Command(), DependsOn: []string{"b"}},
+				{Name: "b", Command: CommandLiteral{"b"}.Command(), DependsOn: []string{"a"}},
+			},
+			errContains: "dependency cycle: a -> b -> a",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := (&Runner{Executor: &FakeExecutor{}}).Run(context.Background(), testCase.jobs)
+			assert.ErrorContains(t, err, testCase.errContains)
+		})
+	}
+}
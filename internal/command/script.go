@@ -0,0 +1,86 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Renders an ordered list of commands as a standalone shell script for those
+// who would rather not run Go. Commands are rendered the same way as
+// Containerfile RUN instructions except that secret values are read from
+// environment variables instead of being inlined.
+type Script struct {
+	// Where the script was generated from, e.g., the name of the program or
+	// plan. It is included in the header comment.
+	Source   string
+	Commands []Commander
+	// Echo each command, with secrets redacted, before running it.
+	Trace bool
+}
+
+// Renders the script.
+func (s *Script) String() string {
+	refs := newSecretRefs()
+
+	lines := []string{}
+
+	for _, commander := range s.Commands {
+		cmd := commander.Command()
+
+		if s.Trace {
+			lines = append(lines, fmt.Sprintf("echo %s", ShellQuote("+ "+cmd.String())))
+		}
+
+		lines = append(lines, cmd.shell(refs))
+	}
+
+	sb := &strings.Builder{}
+
+	sb.WriteString("#!/usr/bin/env bash\n")
+	sb.WriteString("#\n")
+
+	if s.Source != "" {
+		fmt.Fprintf(sb, "# Generated from %s. Do not edit.\n", s.Source)
+	} else {
+		sb.WriteString("# Generated. Do not edit.\n")
+	}
+
+	if len(refs.names) != 0 {
+		sb.WriteString("#\n")
+		sb.WriteString("# The following environment variables must be set:\n")
+
+		for _, name := range refs.names {
+			fmt.Fprintf(sb, "#   %s\n", name)
+		}
+	}
+
+	sb.WriteString("\n")
+	sb.WriteString("set -euo pipefail\n")
+	sb.WriteString("\n")
+
+	if len(refs.names) != 0 {
+		for _, name := range refs.names {
+			fmt.Fprintf(sb, ": \"${%s:?%s must be set}\"\n", name, name)
+		}
+
+		sb.WriteString("\n")
+	}
+
+	for _, line := range lines {
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// Writes the script to the given path and makes it executable.
+func (s *Script) WriteFile(path string) error {
+	if err := os.WriteFile(path, []byte(s.String()), 0o755); err != nil {
+		return err
+	}
+
+	// The mode given to WriteFile is only used when the file is created.
+	return os.Chmod(path, 0o755)
+}
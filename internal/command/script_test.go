@@ -0,0 +1,86 @@
+package command
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShellQuote(t *testing.T) {
+	testCases := map[string]string{
+		"":                      "''",
+		"quay.io/org/image:tag": "quay.io/org/image:tag",
+		"/var/cache/dnf/*":      "'/var/cache/dnf/*'",
+		"$HOME/.config":         "'$HOME/.config'",
+		"$HOME x":               "'$HOME x'",
+		"~/.config":             "'~/.config'",
+		"{a,b}":                 "'{a,b}'",
+		"--opt=a,b:c@d%e+f":     "--opt=a,b:c@d%e+f",
+		"hello world":           "'hello world'",
+		"it's":                  `'it'\''s'`,
+		"a;b":                   "'a;b'",
+	}
+
+	for input, expected := range testCases {
+		assert.Equal(t, expected, ShellQuote(input), input)
+	}
+}
+
+func TestScript(t *testing.T) {
+	script := &Script{
+		Source: "cmd/runner",
+		Trace:  true,
+		Commands: []Commander{
+			(&Login{Token: "sha256~abc", Server: "https://api.example.com:6443"}).Command("/kubeconfig"),
+			&BuildahLogin{Username: "user", Password: "hunter2", Registry: "quay.io"},
+			NewCommand("sh", []Arg{PositionalArg("-c"), PositionalArg("echo 'hello world'")}),
+			(&Login{Token: "sha256~def", Server: "https://api.example.com:6443"}).Command("/kubeconfig"),
+			NewCommandWithEnv("skopeo", []Arg{PositionalArg("inspect")}, map[string]string{"REGISTRY_AUTH": "hunter2"}).WithSecretEnv("REGISTRY_AUTH"),
+		},
+	}
+
+	expected := `#!/usr/bin/env bash
+#
+# Generated from cmd/runner. Do not edit.
+#
+# The following environment variables must be set:
+#   TOKEN
+#   PASSWORD
+#   TOKEN_2
+#   REGISTRY_AUTH
+
+set -euo pipefail
+
+: "${TOKEN:?TOKEN must be set}"
+: "${PASSWORD:?PASSWORD must be set}"
+: "${TOKEN_2:?TOKEN_2 must be set}"
+: "${REGISTRY_AUTH:?REGISTRY_AUTH must be set}"
+
+echo '+ KUBECONFIG=/kubeconfig oc login --token *** --server https://api.example.com:6443'
+KUBECONFIG=/kubeconfig oc login --token "${TOKEN}" --server https://api.example.com:6443
+echo '+ buildah login --username user --password *** quay.io'
+buildah login --username user --password "${PASSWORD}" quay.io
+echo '+ sh -c echo '\''hello world'\'''
+sh -c 'echo '\''hello world'\'''
+echo '+ KUBECONFIG=/kubeconfig oc login --token *** --server https://api.example.com:6443'
+KUBECONFIG=/kubeconfig oc login --token "${TOKEN_2}" --server https://api.example.com:6443
+echo '+ REGISTRY_AUTH=*** skopeo inspect'
+REGISTRY_AUTH="${REGISTRY_AUTH}" skopeo inspect
+`
+
+	assert.Equal(t, expected, script.String())
+
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not available")
+	}
+
+	path := filepath.Join(t.TempDir(), "script.sh")
+	require.NoError(t, script.WriteFile(path))
+
+	out, err := exec.Command(bash, "-n", path).CombinedOutput()
+	assert.NoError(t, err, string(out))
+}
@@ -0,0 +1,215 @@
+package command
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Represents an argument which is emitted verbatim when the command is
+// rendered for a shell, such as a redirection or a string which has already
+// been quoted.
+type ShellArg string
+
+func (s ShellArg) Arg() []string {
+	return []string{string(s)}
+}
+
+// Matches words made only of characters which a POSIX shell never interprets.
+// Anything else, including globs and variable references, is quoted so that
+// the shell passes the word through literally, as Cmd() does. ShellArg may be
+// used where expansion is wanted.
+var shellSafeWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// Quotes the given word for a POSIX shell unless it is only made of
+// characters which the shell would not otherwise interpret.
+func ShellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+
+	if shellSafeWord.MatchString(s) {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Implemented by args which hold secret values so that they can be rendered
+// as references to environment variables instead of being inlined.
+type secretRefArg interface {
+	secretRef(refs *secretRefs) []string
+}
+
+// Renders the command for a shell. If refs is given, secret values are
+// replaced with references to environment variables.
+func (c *Command) shell(refs *secretRefs) string {
+	out := []string{}
+
+	for _, name := range sortedKeys(c.env) {
+		val := ShellQuote(c.env[name])
+		if refs != nil && c.secretEnv[name] {
+			val = refs.ref(name, c.env[name])
+		}
+
+		out = append(out, fmt.Sprintf("%s=%s", name, val))
+	}
+
+	for _, arg := range c.args {
+		out = append(out, shellArg(arg, refs)...)
+	}
+
+	return strings.Join(out, " ")
+}
+
+func shellArg(arg Arg, refs *secretRefs) []string {
+	if secret, ok := arg.(secretRefArg); ok && refs != nil {
+		return secret.secretRef(refs)
+	}
+
+	switch a := arg.(type) {
+	case ShellArg:
+		return a.Arg()
+	case *Subcommand:
+		out := []string{ShellQuote(a.Name)}
+		for _, flag := range a.Flags {
+			out = append(out, shellArg(flag, refs)...)
+		}
+
+		return out
+	}
+
+	out := []string{}
+	for _, word := range arg.Arg() {
+		out = append(out, ShellQuote(word))
+	}
+
+	return out
+}
+
+// Assigns environment variable names to secret values.
+type secretRefs struct {
+	names  []string
+	values map[string]string
+	byKey  map[string]string
+	taken  map[string]bool
+	// Forms a reference to the named environment variable.
+	format func(name string) string
+	// Quotes the non-secret parts of an arg.
+	quote func(string) string
+}
+
+// Returns secretRefs which produce quoted shell references such as "${TOKEN}".
+func newSecretRefs() *secretRefs {
+	return &secretRefs{
+		values: map[string]string{},
+		byKey:  map[string]string{},
+		taken:  map[string]bool{},
+		format: func(name string) string { return fmt.Sprintf(`"${%s}"`, name) },
+		quote:  ShellQuote,
+	}
+}
+
+// Returns a reference to the environment variable holding the given value,
+// naming it after the hint. The same hint and value always receive the same
+// name while differing values for the same hint are suffixed.
+func (s *secretRefs) ref(hint, value string) string {
+	key := hint + "\x00" + value
+
+	name, ok := s.byKey[key]
+	if !ok {
+		name = envVarName(hint)
+		for i := 2; s.taken[name]; i++ {
+			name = fmt.Sprintf("%s_%d", envVarName(hint), i)
+		}
+
+		s.byKey[key] = name
+		s.values[name] = value
+		s.taken[name] = true
+		s.names = append(s.names, name)
+	}
+
+	return s.format(name)
+}
+
+var envVarNameInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// Converts a flag name such as registry-token into REGISTRY_TOKEN.
+func envVarName(hint string) string {
+	return strings.ToUpper(envVarNameInvalidChars.ReplaceAllString(hint, "_"))
+}
+
+func (s SecretArg) secretRef(refs *secretRefs) []string {
+	return []string{refs.ref("SECRET", string(s))}
+}
+
+func (s *SecretValueFlag) secretRef(refs *secretRefs) []string {
+	envVar := s.EnvVar
+	if envVar == "" {
+		envVar = s.Name
+	}
+
+	prefix := ""
+	if s.Prefix != "" {
+		prefix = refs.quote(s.Prefix)
+	}
+
+	return []string{fmt.Sprintf("--%s", s.Name), prefix + refs.ref(envVar, s.Value)}
+}
+
+// A secret value which was replaced by a reference to an environment
+// variable.
+type SecretRef struct {
+	EnvVar string
+	Value  string
+}
+
+// Returns the argv and environment of the command with each secret value
+// replaced by a reference to an environment variable, as formed by ref, e.g.,
+// $(TOKEN) for a Kubernetes container. Secret environment variables are
+// omitted from env. The secrets are returned so that the caller can arrange
+// for the environment variables to be set.
+func (c *Command) ArgvWithSecretRefs(ref func(envVar string) string) (argv []string, env map[string]string, secrets []SecretRef) {
+	refs := newSecretRefs()
+	refs.format = ref
+	refs.quote = func(s string) string { return s }
+
+	env = map[string]string{}
+
+	for _, name := range sortedKeys(c.env) {
+		if c.secretEnv[name] {
+			refs.ref(name, c.env[name])
+			continue
+		}
+
+		env[name] = c.env[name]
+	}
+
+	for _, arg := range c.args {
+		if secret, ok := arg.(secretRefArg); ok {
+			argv = append(argv, secret.secretRef(refs)...)
+			continue
+		}
+
+		if sub, ok := arg.(*Subcommand); ok {
+			argv = append(argv, sub.Name)
+			for _, flag := range sub.Flags {
+				if secret, ok := flag.(secretRefArg); ok {
+					argv = append(argv, secret.secretRef(refs)...)
+				} else {
+					argv = append(argv, flag.Arg()...)
+				}
+			}
+
+			continue
+		}
+
+		argv = append(argv, arg.Arg()...)
+	}
+
+	for _, name := range refs.names {
+		secrets = append(secrets, SecretRef{EnvVar: name, Value: refs.values[name]})
+	}
+
+	return argv, env, secrets
+}
@@ -0,0 +1,185 @@
+package command
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Returned when a string cannot be parsed into a command.
+type ShellParseError struct {
+	// The byte offset into the input at which the problem was found.
+	Pos int
+	Msg string
+}
+
+func (e *ShellParseError) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Msg)
+}
+
+// Describes the shell constructs which cannot be represented as a single
+// command.
+var unsupportedShellConstructs = map[byte]string{
+	'|': "pipes are not supported",
+	'&': "command lists and background jobs are not supported",
+	';': "command lists are not supported",
+	'<': "redirections are not supported",
+	'>': "redirections are not supported",
+	'(': "subshells are not supported",
+	')': "subshells are not supported",
+	'`': "command substitutions are not supported",
+}
+
+var envAssignmentName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// A word and whether it may be an environment variable assignment, which is
+// the case when everything up to its first = was unquoted.
+type shellWord struct {
+	text     string
+	assignAt int
+}
+
+// Splits a shell-like string into a command the way a POSIX shell would,
+// honoring single and double quotes and backslash escapes. Leading VAR=val
+// words become the command's environment variables. Constructs which would
+// require a shell to run, such as pipes, redirections, and command
+// substitutions, are rejected. Parameter references such as $HOME and globs
+// are kept verbatim since there is no shell to expand them.
+func ParseShellWords(s string) (*Command, error) {
+	words, err := splitShellWords(s)
+	if err != nil {
+		return nil, err
+	}
+
+	env := map[string]string{}
+
+	for len(words) != 0 && words[0].assignAt != -1 {
+		env[words[0].text[:words[0].assignAt]] = words[0].text[words[0].assignAt+1:]
+		words = words[1:]
+	}
+
+	if len(words) == 0 {
+		return nil, fmt.Errorf("no command found in %q", s)
+	}
+
+	args := []Arg{}
+	for _, word := range words[1:] {
+		args = append(args, PositionalArg(word.text))
+	}
+
+	if len(env) == 0 {
+		return NewCommand(words[0].text, args), nil
+	}
+
+	return NewCommandWithEnv(words[0].text, args, env), nil
+}
+
+func splitShellWords(s string) ([]shellWord, error) {
+	words := []shellWord{}
+
+	current := &strings.Builder{}
+	inWord := false
+	quotedPrefix := false
+	assignAt := -1
+
+	endWord := func() {
+		if inWord {
+			words = append(words, shellWord{text: current.String(), assignAt: assignAt})
+		}
+
+		current.Reset()
+		inWord = false
+		quotedPrefix = false
+		assignAt = -1
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case c == ' ' || c == '\t':
+			endWord()
+		case c == '\n':
+			return nil, &ShellParseError{Pos: i, Msg: "multiple lines are not supported"}
+		case c == '#' && !inWord:
+			// The rest of the line is a comment.
+			endWord()
+			return words, nil
+		case c == '\\':
+			if i+1 == len(s) {
+				return nil, &ShellParseError{Pos: i, Msg: "trailing backslash"}
+			}
+
+			i++
+			if s[i] == '\n' {
+				// A line continuation.
+				continue
+			}
+
+			inWord = true
+			quotedPrefix = true
+			current.WriteByte(s[i])
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end == -1 {
+				return nil, &ShellParseError{Pos: i, Msg: "unterminated single quote"}
+			}
+
+			inWord = true
+			quotedPrefix = quotedPrefix || assignAt == -1
+			current.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+		case c == '"':
+			end, err := readDoubleQuoted(s, i, current)
+			if err != nil {
+				return nil, err
+			}
+
+			inWord = true
+			quotedPrefix = quotedPrefix || assignAt == -1
+			i = end
+		case c == '$' && i+1 < len(s) && s[i+1] == '(':
+			return nil, &ShellParseError{Pos: i, Msg: "command substitutions are not supported"}
+		case unsupportedShellConstructs[c] != "":
+			return nil, &ShellParseError{Pos: i, Msg: fmt.Sprintf("unsupported %q: %s", c, unsupportedShellConstructs[c])}
+		default:
+			if c == '=' && assignAt == -1 && !quotedPrefix && envAssignmentName.MatchString(current.String()) {
+				assignAt = current.Len()
+			}
+
+			inWord = true
+			current.WriteByte(c)
+		}
+	}
+
+	endWord()
+
+	return words, nil
+}
+
+// Reads the double-quoted string starting at the given quote into the
+// builder, returning the position of the closing quote. Within double quotes,
+// a backslash only escapes $, `, ", \, and newlines.
+func readDoubleQuoted(s string, start int, sb *strings.Builder) (int, error) {
+	for i := start + 1; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case c == '"':
+			return i, nil
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\\n", s[i+1]) != -1:
+			i++
+			if s[i] != '\n' {
+				sb.WriteByte(s[i])
+			}
+		case c == '`':
+			return 0, &ShellParseError{Pos: i, Msg: "command substitutions are not supported"}
+		case c == '$' && i+1 < len(s) && s[i+1] == '(':
+			return 0, &ShellParseError{Pos: i, Msg: "command substitutions are not supported"}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return 0, &ShellParseError{Pos: start, Msg: "unterminated double quote"}
+}
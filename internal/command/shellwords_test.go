@@ -0,0 +1,139 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseShellWords(t *testing.T) {
+	testCases := []struct {
+		name         string
+		input        string
+		expectedArgv []string
+		expectedEnv  map[string]string
+		errContains  string
+	}{
+		{
+			name:         "Plain words",
+			input:        "  dnf install -y\tgolang  ",
+			expectedArgv: []string{"dnf", "install", "-y", "golang"},
+		},
+		{
+			name:         "Quotes and escapes",
+			input:        `echo 'single $HOME' "double \"quoted\" \$HOME" back\ slash "a"'b'c`,
+			expectedArgv: []string{"echo", "single $HOME", `double "quoted" $HOME`, "back slash", "abc"},
+		},
+		{
+			name:         "Backslashes within double quotes",
+			input:        `printf "a\nb\\c"`,
+			expectedArgv: []string{"printf", `a\nb\c`},
+		},
+		{
+			name:         "Empty quoted words are kept",
+			input:        `cmd "" ''`,
+			expectedArgv: []string{"cmd", "", ""},
+		},
+		{
+			name:         "Env prefixes",
+			input:        `HTTP_PROXY=http://proxy:3128 NAME="a b" EMPTY= buildah build --build-arg X=1 .`,
+			expectedArgv: []string{"buildah", "build", "--build-arg", "X=1", "."},
+			expectedEnv:  map[string]string{"HTTP_PROXY": "http://proxy:3128", "NAME": "a b", "EMPTY": ""},
+		},
+		{
+			name:         "Quoted assignment is a command",
+			input:        `"FOO=bar" baz`,
+			expectedArgv: []string{"FOO=bar", "baz"},
+		},
+		{
+			name:         "Line continuation and comment",
+			input:        "rpm-ostree install \\\n  vim # an editor",
+			expectedArgv: []string{"rpm-ostree", "install", "vim"},
+		},
+		{
+			name:         "Parameter references and globs are verbatim",
+			input:        "rm -rf ${HOME}/.cache /var/cache/dnf/*",
+			expectedArgv: []string{"rm", "-rf", "${HOME}/.cache", "/var/cache/dnf/*"},
+		},
+		{
+			name:        "Pipe",
+			input:       "curl -sL https://example.com | sh",
+			errContains: `position 29: unsupported '|': pipes are not supported`,
+		},
+		{
+			name:        "Command list",
+			input:       "dnf install -y vim && dnf clean all",
+			errContains: "command lists and background jobs are not supported",
+		},
+		{
+			name:        "Redirection",
+			input:       "echo hi > /tmp/file",
+			errContains: "redirections are not supported",
+		},
+		{
+			name:        "Command substitution",
+			input:       `echo "$(uname -m)"`,
+			errContains: "position 6: command substitutions are not supported",
+		},
+		{
+			name:        "Backticks",
+			input:       "echo `uname -m`",
+			errContains: "command substitutions are not supported",
+		},
+		{
+			name:        "Unterminated quote",
+			input:       `echo "hello`,
+			errContains: "position 5: unterminated double quote",
+		},
+		{
+			name:        "Only assignments",
+			input:       "FOO=bar",
+			errContains: `no command found in "FOO=bar"`,
+		},
+		{
+			name:        "Empty",
+			input:       "   ",
+			errContains: "no command found",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			cmd, err := ParseShellWords(testCase.input)
+			if testCase.errContains != "" {
+				assert.ErrorContains(t, err, testCase.errContains)
+				return
+			}
+
+			require.NoError(t, err)
+
+			argv, env, _ := cmd.ArgvWithSecretRefs(func(name string) string { return name })
+			assert.Equal(t, testCase.expectedArgv, argv)
+
+			if testCase.expectedEnv == nil {
+				testCase.expectedEnv = map[string]string{}
+			}
+
+			assert.Equal(t, testCase.expectedEnv, env)
+		})
+	}
+}
+
+func TestParseShellWordsRoundTrip(t *testing.T) {
+	inputs := []string{
+		`echo 'hello world' "it's" plain`,
+		`FOO='a b' sh -c 'echo "$FOO"'`,
+	}
+
+	for _, input := range inputs {
+		cmd, err := ParseShellWords(input)
+		require.NoError(t, err)
+
+		reparsed, err := ParseShellWords(cmd.Unredacted())
+		require.NoError(t, err)
+
+		assert.Equal(t, cmd.Unredacted(), reparsed.Unredacted())
+		assert.Equal(t, cmd.Cmd().Args, reparsed.Cmd().Args)
+	}
+}
@@ -0,0 +1,36 @@
+package command
+
+// Represents a skopeo inspect command. Image must include its transport,
+// e.g., docker://quay.io/org/image:latest.
+type SkopeoInspect struct {
+	Authfile string
+	// Prints the manifest as it is stored in the registry. For multi-arch
+	// images, this is the manifest list.
+	Raw       bool
+	NoTags    bool
+	TLSVerify *bool
+	Image     string
+}
+
+func (s *SkopeoInspect) Command() *Command {
+	inspectFlags := mapToValFlags(map[string]string{
+		"authfile": s.Authfile,
+	})
+
+	inspectFlags = append(inspectFlags, mapToSwitchFlags(map[string]bool{
+		"no-tags": s.NoTags,
+		"raw":     s.Raw,
+	})...)
+
+	inspectFlags = append(inspectFlags, mapToOptSwitchFlags(map[string]*bool{
+		"tls-verify": s.TLSVerify,
+	})...)
+
+	return NewCommand("skopeo", []Arg{
+		&Subcommand{
+			Name:  "inspect",
+			Flags: inspectFlags,
+		},
+		PositionalArg(s.Image),
+	})
+}
@@ -0,0 +1,40 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSkopeoInspect(t *testing.T) {
+	tlsVerify := false
+
+	testCases := []struct {
+		name     string
+		input    *SkopeoInspect
+		expected string
+	}{
+		{
+			name:     "Defaults",
+			input:    &SkopeoInspect{Image: "docker://quay.io/org/image:latest"},
+			expected: "skopeo inspect docker://quay.io/org/image:latest",
+		},
+		{
+			name: "All options",
+			input: &SkopeoInspect{
+				Authfile:  "/path/to/authfile",
+				Raw:       true,
+				NoTags:    true,
+				TLSVerify: &tlsVerify,
+				Image:     "docker://quay.io/org/image:latest",
+			},
+			expected: "skopeo inspect --authfile /path/to/authfile --no-tags --raw --tls-verify=false docker://quay.io/org/image:latest",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, testCase.input.Command().String())
+		})
+	}
+}
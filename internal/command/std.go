@@ -1,11 +1,18 @@
 package command
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
 
 // Represents an echo command.
 type Echo struct {
-	Content    string
-	Escape     bool
+	Content string
+	Escape  bool
+	// Only works when the command is run by a shell, e.g., within a RUN
+	// instruction. To write a file into an image, containerfile.InlineFileStep
+	// should be used instead.
 	RedirectTo string
 }
 
@@ -14,11 +21,11 @@ func (e *Echo) Command() *Command {
 		"e": e.Escape,
 	}))
 
-	args = append(args, PositionalArg(fmt.Sprintf("%q", e.Content)))
+	args = append(args, ShellArg(fmt.Sprintf("%q", e.Content)))
 
 	if e.RedirectTo != "" {
 		// Figure out a better way to do this because exec.Command won't run this.
-		args = append(args, itemsToPositionalArgs([]string{">", e.RedirectTo})...)
+		args = append(args, ShellArg(">"), PositionalArg(e.RedirectTo))
 	}
 
 	return NewCommand("echo", args)
@@ -27,12 +34,14 @@ func (e *Echo) Command() *Command {
 // Represents the rm command.
 type Delete struct {
 	Path      string
+	Force     bool
 	Recursive bool
 	Verbose   bool
 }
 
 func (d *Delete) Command() *Command {
 	args := flagsToArgs(mapToSingleSwitchFlag(map[string]bool{
+		"f": d.Force,
 		"r": d.Recursive,
 		"v": d.Verbose,
 	}))
@@ -62,12 +71,43 @@ func (c *Chmod) Command() *Command {
 	return NewCommand("chmod", args)
 }
 
+// Represents the useradd command.
+type Useradd struct {
+	Name       string
+	UID        string
+	GID        string
+	Groups     []string
+	Shell      string
+	Home       string
+	CreateHome bool
+	System     bool
+}
+
+func (u *Useradd) Command() *Command {
+	args := flagsToArgs(mapToSwitchFlags(map[string]bool{
+		"create-home": u.CreateHome,
+		"system":      u.System,
+	}))
+
+	args = append(args, flagsToArgs(mapToValFlags(map[string]string{
+		"uid":      u.UID,
+		"gid":      u.GID,
+		"groups":   strings.Join(u.Groups, ","),
+		"shell":    u.Shell,
+		"home-dir": u.Home,
+	}))...)
+
+	args = append(args, PositionalArg(u.Name))
+
+	return NewCommand("useradd", args)
+}
+
 // Converts a map to double-flags.
 func mapToValFlags(valOpts map[string]string) []Flag {
 	out := []Flag{}
 
-	for name, val := range valOpts {
-		if val != "" {
+	for _, name := range sortedKeys(valOpts) {
+		if val := valOpts[name]; val != "" {
 			out = append(out, &DoubleValueFlag{
 				Name:  name,
 				Value: val,
@@ -82,8 +122,8 @@ func mapToValFlags(valOpts map[string]string) []Flag {
 func mapToSwitchFlags(switchOpts map[string]bool) []Flag {
 	out := []Flag{}
 
-	for name, isSet := range switchOpts {
-		if isSet {
+	for _, name := range sortedKeys(switchOpts) {
+		if switchOpts[name] {
 			out = append(out, DoubleSwitchFlag(name))
 		}
 	}
@@ -95,8 +135,8 @@ func mapToSwitchFlags(switchOpts map[string]bool) []Flag {
 func mapToOptSwitchFlags(optSwitchOpts map[string]*bool) []Flag {
 	out := []Flag{}
 
-	for name, val := range optSwitchOpts {
-		if val != nil {
+	for _, name := range sortedKeys(optSwitchOpts) {
+		if val := optSwitchOpts[name]; val != nil {
 			out = append(out, &DoubleEqualValueFlag{
 				Name:  name,
 				Value: fmt.Sprintf("%v", *val),
@@ -111,8 +151,8 @@ func mapToOptSwitchFlags(optSwitchOpts map[string]*bool) []Flag {
 func mapToSingleSwitchFlag(switchOpts map[string]bool) []Flag {
 	out := []Flag{}
 
-	for name, isSet := range switchOpts {
-		if isSet {
+	for _, name := range sortedKeys(switchOpts) {
+		if switchOpts[name] {
 			out = append(out, SingleSwitchFlag(name))
 		}
 	}
@@ -141,3 +181,102 @@ func itemsToPositionalArgs(items []string) []Arg {
 
 	return args
 }
+
+// Returns the keys of the given map in sorted order so that flags render
+// deterministically.
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func recognizeDelete(argv []string) (Commander, bool) {
+	d := &Delete{}
+
+	flags := &argvFlags{
+		switches: map[string]*bool{
+			"-f":          &d.Force,
+			"--force":     &d.Force,
+			"-r":          &d.Recursive,
+			"-R":          &d.Recursive,
+			"--recursive": &d.Recursive,
+			"-v":          &d.Verbose,
+			"--verbose":   &d.Verbose,
+		},
+	}
+
+	positionals, ok := flags.parse(argv[1:])
+	if !ok || len(positionals) != 1 {
+		return nil, false
+	}
+
+	d.Path = positionals[0]
+
+	return d, true
+}
+
+func recognizeChmod(argv []string) (Commander, bool) {
+	c := &Chmod{}
+
+	flags := &argvFlags{
+		switches: map[string]*bool{
+			"-R":          &c.Recursive,
+			"--recursive": &c.Recursive,
+		},
+	}
+
+	positionals, ok := flags.parse(argv[1:])
+	if !ok || len(positionals) != 2 {
+		return nil, false
+	}
+
+	c.Mode = positionals[0]
+	c.Path = positionals[1]
+
+	return c, true
+}
+
+func recognizeUseradd(argv []string) (Commander, bool) {
+	u := &Useradd{}
+	groups := ""
+
+	flags := &argvFlags{
+		switches: map[string]*bool{
+			"-m":            &u.CreateHome,
+			"--create-home": &u.CreateHome,
+			"-r":            &u.System,
+			"--system":      &u.System,
+		},
+		values: map[string]*string{
+			"-u":         &u.UID,
+			"--uid":      &u.UID,
+			"-g":         &u.GID,
+			"--gid":      &u.GID,
+			"-G":         &groups,
+			"--groups":   &groups,
+			"-s":         &u.Shell,
+			"--shell":    &u.Shell,
+			"-d":         &u.Home,
+			"--home-dir": &u.Home,
+		},
+	}
+
+	positionals, ok := flags.parse(argv[1:])
+	if !ok || len(positionals) != 1 {
+		return nil, false
+	}
+
+	u.Name = positionals[0]
+
+	if groups != "" {
+		u.Groups = strings.Split(groups, ",")
+	}
+
+	return u, true
+}
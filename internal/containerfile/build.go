@@ -0,0 +1,293 @@
+package containerfile
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+)
+
+// The tool which builds the image.
+type Builder string
+
+const (
+	BuilderPodman  Builder = "podman"
+	BuilderBuildah Builder = "buildah"
+)
+
+// Options for building a Containerfile.
+type BuildOpts struct {
+	// Defaults to podman.
+	Builder Builder
+	// Passed to the builder. File is always set to the rendered Containerfile
+	// and IIDFile defaults to a temporary file. BuildContext defaults to a
+	// temporary directory containing only the Containerfile while Tag defaults
+	// to the Containerfile's Tag.
+	Build command.BuildOpts
+	// Defaults to running the builder as a local process.
+	Executor command.Executor
+	// Called with each event as the build output is read.
+	OnEvent func(BuildEvent)
+	// If set, the builder's output is streamed here as it runs.
+	Out io.Writer
+}
+
+// The kinds of progress events parsed from the build output.
+type BuildEventKind string
+
+const (
+	// A STEP n/m line, which begins each instruction.
+	EventStep BuildEventKind = "step"
+	// The instruction's layer was found in the cache.
+	EventCacheHit BuildEventKind = "cache-hit"
+	// The instruction's layer was committed.
+	EventLayer BuildEventKind = "layer"
+	// The final layer of a stage is being committed as an image.
+	EventCommit BuildEventKind = "commit"
+	EventTagged BuildEventKind = "tagged"
+	// The ID of the built image, which is written once the build succeeds.
+	EventImageID BuildEventKind = "image-id"
+	// Any other output, e.g., from a RUN instruction.
+	EventOutput BuildEventKind = "output"
+)
+
+// A progress event parsed from a single line of the build output.
+type BuildEvent struct {
+	Kind BuildEventKind `json:"kind"`
+	// The position of the stage being built, counting from 1, and the number
+	// of stages. Only set for multi-stage builds.
+	Stage  int `json:"stage,omitempty"`
+	Stages int `json:"stages,omitempty"`
+	// The position of the step within its stage, counting from 1, and the
+	// number of steps. Only set for step events.
+	Step  int `json:"step,omitempty"`
+	Steps int `json:"steps,omitempty"`
+	// The instruction being run for step events.
+	Instruction string `json:"instruction,omitempty"`
+	// The layer or image ID for cache hit, layer, and image ID events.
+	ID string `json:"id,omitempty"`
+	// The image name for commit and tagged events.
+	Image string `json:"image,omitempty"`
+	Line  string `json:"line"`
+}
+
+// The outcome of a build.
+type BuildResult struct {
+	// The full hex-encoded image ID.
+	ImageID string
+	// The digest of the image's config, i.e., sha256:<ImageID>. This differs
+	// from the manifest digest, which is only known once the image is pushed.
+	Digest string
+	Tag    string
+	Events []BuildEvent
+}
+
+// Renders the Containerfile and builds it with podman or buildah. Progress is
+// reported as the build output is read. Returns the ID of the built image,
+// which is read from the iidfile, or from the final line of output when the
+// iidfile was not written, e.g., by a FakeExecutor.
+func Build(ctx context.Context, cf *Containerfile, opts BuildOpts) (*BuildResult, error) {
+	if err := cf.Validate(); err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "containerfile-build-")
+	if err != nil {
+		return nil, err
+	}
+
+	defer os.RemoveAll(dir)
+
+	build := opts.Build
+	build.File = filepath.Join(dir, "Containerfile")
+
+	if err := os.WriteFile(build.File, []byte(cf.String()), 0o644); err != nil {
+		return nil, err
+	}
+
+	if build.BuildContext == "" {
+		build.BuildContext = dir
+	}
+
+	if build.Tag == "" {
+		build.Tag = cf.Tag
+	}
+
+	if build.IIDFile == "" {
+		build.IIDFile = filepath.Join(dir, "iidfile")
+	}
+
+	if err := cf.ValidateBuildOpts(&build); err != nil {
+		return nil, err
+	}
+
+	var builder command.Commander
+
+	switch opts.Builder {
+	case "", BuilderPodman:
+		builder = &command.PodmanBuild{BuildOpts: build}
+	case BuilderBuildah:
+		builder = &command.BuildahBuild{BuildOpts: build}
+	default:
+		return nil, fmt.Errorf("unknown builder %q", opts.Builder)
+	}
+
+	executor := opts.Executor
+	if executor == nil {
+		executor = command.ExecExecutor{}
+	}
+
+	w := &eventWriter{out: opts.Out, onEvent: opts.OnEvent}
+
+	err = executor.Execute(ctx, builder.Command(), w, w)
+	w.Flush()
+
+	result := &BuildResult{Tag: build.Tag, Events: w.events}
+
+	if err != nil {
+		return result, fmt.Errorf("build failed: %w", err)
+	}
+
+	result.ImageID = w.imageID
+
+	if iid, err := os.ReadFile(build.IIDFile); err == nil {
+		result.ImageID = strings.TrimPrefix(strings.TrimSpace(string(iid)), "sha256:")
+	}
+
+	if result.ImageID == "" {
+		return result, fmt.Errorf("could not determine the ID of the built image")
+	}
+
+	result.Digest = "sha256:" + result.ImageID
+
+	return result, nil
+}
+
+var (
+	stepPattern     = regexp.MustCompile(`^STEP (\d+)/(\d+): (.*)$`)
+	stagePattern    = regexp.MustCompile(`^\[(\d+)/(\d+)\] `)
+	cacheHitPattern = regexp.MustCompile(`^--> Using cache ([0-9a-f]{12,64})$`)
+	layerPattern    = regexp.MustCompile(`^--> ([0-9a-f]{12,64})$`)
+	imageIDPattern  = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// Parses a single line of podman or buildah build output.
+func ParseBuildEvent(line string) BuildEvent {
+	event := BuildEvent{Kind: EventOutput, Line: line}
+
+	rest := line
+	if m := stagePattern.FindStringSubmatch(line); m != nil {
+		event.Stage, _ = strconv.Atoi(m[1])
+		event.Stages, _ = strconv.Atoi(m[2])
+		rest = line[len(m[0]):]
+	}
+
+	if m := stepPattern.FindStringSubmatch(rest); m != nil {
+		event.Kind = EventStep
+		event.Step, _ = strconv.Atoi(m[1])
+		event.Steps, _ = strconv.Atoi(m[2])
+		event.Instruction = m[3]
+		return event
+	}
+
+	if rest == "COMMIT" || strings.HasPrefix(rest, "COMMIT ") {
+		image := strings.TrimPrefix(rest, "COMMIT")
+		event.Kind = EventCommit
+		event.Image = strings.TrimSpace(image)
+		return event
+	}
+
+	if image, ok := strings.CutPrefix(rest, "Successfully tagged "); ok {
+		event.Kind = EventTagged
+		event.Image = strings.TrimSpace(image)
+		return event
+	}
+
+	if m := cacheHitPattern.FindStringSubmatch(rest); m != nil {
+		event.Kind = EventCacheHit
+		event.ID = m[1]
+		return event
+	}
+
+	if m := layerPattern.FindStringSubmatch(rest); m != nil {
+		event.Kind = EventLayer
+		event.ID = m[1]
+		return event
+	}
+
+	if imageIDPattern.MatchString(rest) {
+		event.Kind = EventImageID
+		event.ID = rest
+	}
+
+	return event
+}
+
+// Parses each line written to it into a BuildEvent. Since the builder's
+// stdout and stderr are both written here, writes are serialized.
+type eventWriter struct {
+	mu      sync.Mutex
+	out     io.Writer
+	onEvent func(BuildEvent)
+	buf     []byte
+	events  []BuildEvent
+	imageID string
+}
+
+func (e *eventWriter) Write(b []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.out != nil {
+		if _, err := e.out.Write(b); err != nil {
+			return 0, err
+		}
+	}
+
+	e.buf = append(e.buf, b...)
+
+	for {
+		idx := bytes.IndexByte(e.buf, '\n')
+		if idx == -1 {
+			break
+		}
+
+		e.handle(string(e.buf[:idx]))
+		e.buf = e.buf[idx+1:]
+	}
+
+	return len(b), nil
+}
+
+// Parses any buffered partial line.
+func (e *eventWriter) Flush() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.buf) != 0 {
+		e.handle(string(e.buf))
+		e.buf = nil
+	}
+}
+
+func (e *eventWriter) handle(line string) {
+	event := ParseBuildEvent(strings.TrimRight(line, "\r"))
+
+	if event.Kind == EventImageID {
+		e.imageID = event.ID
+	}
+
+	e.events = append(e.events, event)
+
+	if e.onEvent != nil {
+		e.onEvent(event)
+	}
+}
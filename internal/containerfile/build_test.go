@@ -0,0 +1,170 @@
+package containerfile
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const imageID = "3e9d7c1b2a4f6e8d0c2b4a6f8e0d2c4b6a8f0e2d4c6b8a0f2e4d6c8b0a2f4e6d"
+
+func newBuildContainerfile() *Containerfile {
+	return &Containerfile{
+		Tag: "quay.io/zack/app:latest",
+		Stages: []*Stage{
+			{
+				Name:  "builder",
+				Image: "quay.io/centos/stream:9",
+				Steps: []ContainerfileStep{
+					&RunStep{Command: "dnf install -y golang"},
+					&RunStep{Command: "go build -o /app ./cmd/app"},
+				},
+			},
+			{
+				Image: "quay.io/centos/stream:9",
+				Steps: []ContainerfileStep{
+					&CopyStep{From: "builder", Src: "/app", Dest: "/usr/bin/app"},
+				},
+			},
+		},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	recorded, err := os.ReadFile("testdata/buildah-build.txt")
+	require.NoError(t, err)
+
+	executor := &command.FakeExecutor{
+		Default: command.FakeResponse{
+			Stdout: string(recorded),
+			// Renders the Containerfile before it is cleaned up.
+			Func: func(_ context.Context, cmd *command.Command) error {
+				rendered, err := os.ReadFile(flagValue(cmd.String(), "--file"))
+				require.NoError(t, err)
+				assert.Equal(t, newBuildContainerfile().String(), string(rendered))
+				return nil
+			},
+		},
+	}
+
+	events := []BuildEvent{}
+	out := &bytes.Buffer{}
+
+	result, err := Build(context.Background(), newBuildContainerfile(), BuildOpts{
+		Builder:  BuilderBuildah,
+		Executor: executor,
+		OnEvent:  func(event BuildEvent) { events = append(events, event) },
+		Out:      out,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, imageID, result.ImageID)
+	assert.Equal(t, "sha256:"+imageID, result.Digest)
+	assert.Equal(t, "quay.io/zack/app:latest", result.Tag)
+	assert.Equal(t, events, result.Events)
+	assert.Equal(t, string(recorded), out.String())
+
+	executed := executor.Executed()
+	require.Len(t, executed, 1)
+	assert.True(t, strings.HasPrefix(executed[0], "buildah build"))
+	assert.Contains(t, executed[0], "--tag quay.io/zack/app:latest")
+	assert.Contains(t, executed[0], "--iidfile ")
+
+	assert.Equal(t, []BuildEvent{
+		{Kind: EventStep, Stage: 1, Stages: 2, Step: 1, Steps: 3, Instruction: "FROM quay.io/centos/stream:9 AS builder", Line: "[1/2] STEP 1/3: FROM quay.io/centos/stream:9 AS builder"},
+		{Kind: EventStep, Stage: 1, Stages: 2, Step: 2, Steps: 3, Instruction: "RUN dnf install -y golang", Line: "[1/2] STEP 2/3: RUN dnf install -y golang"},
+		{Kind: EventCacheHit, ID: "5c0b2d1f2e7a4b6c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c", Line: "--> Using cache 5c0b2d1f2e7a4b6c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c"},
+		{Kind: EventLayer, ID: "5c0b2d1f2e7a", Line: "--> 5c0b2d1f2e7a"},
+	}, events[:4])
+
+	assert.Equal(t, BuildEvent{Kind: EventOutput, Line: "go: downloading github.com/stretchr/testify v1.9.0"}, events[5])
+	assert.Equal(t, BuildEvent{Kind: EventCommit, Stage: 2, Stages: 2, Image: "quay.io/zack/app:latest", Line: "[2/2] COMMIT quay.io/zack/app:latest"}, events[9])
+	assert.Equal(t, BuildEvent{Kind: EventTagged, Image: "quay.io/zack/app:latest", Line: "Successfully tagged quay.io/zack/app:latest"}, events[11])
+	assert.Equal(t, BuildEvent{Kind: EventImageID, ID: imageID, Line: imageID}, events[12])
+}
+
+func TestBuildReadsIIDFile(t *testing.T) {
+	executor := &command.FakeExecutor{
+		Default: command.FakeResponse{
+			Stdout: "STEP 1/1: FROM fedora\nCOMMIT\n--> 1a2b3c4d5e6f\n",
+			Func: func(_ context.Context, cmd *command.Command) error {
+				return os.WriteFile(flagValue(cmd.String(), "--iidfile"), []byte("sha256:"+imageID), 0o644)
+			},
+		},
+	}
+
+	result, err := Build(context.Background(), &Containerfile{Stages: []*Stage{{Image: "fedora"}}}, BuildOpts{
+		Build:    command.BuildOpts{BuildContext: "/src"},
+		Executor: executor,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, imageID, result.ImageID)
+	assert.True(t, strings.HasPrefix(executor.Executed()[0], "podman build"))
+	assert.True(t, strings.HasSuffix(executor.Executed()[0], " /src"))
+}
+
+func TestBuildErrors(t *testing.T) {
+	t.Run("Build fails", func(t *testing.T) {
+		executor := &command.FakeExecutor{
+			Default: command.FakeResponse{
+				Stdout:   "STEP 1/2: FROM fedora\nSTEP 2/2: RUN false\n",
+				Stderr:   "Error: building at STEP \"RUN false\": exit status 1",
+				ExitCode: 1,
+			},
+		}
+
+		result, err := Build(context.Background(), newBuildContainerfile(), BuildOpts{Executor: executor})
+		assert.EqualError(t, err, "build failed: exit status 1")
+		require.Len(t, result.Events, 3)
+		assert.Equal(t, EventOutput, result.Events[2].Kind)
+	})
+
+	t.Run("No image ID", func(t *testing.T) {
+		_, err := Build(context.Background(), newBuildContainerfile(), BuildOpts{Executor: &command.FakeExecutor{}})
+		assert.EqualError(t, err, "could not determine the ID of the built image")
+	})
+
+	t.Run("Missing secret", func(t *testing.T) {
+		cf := &Containerfile{
+			Stages: []*Stage{
+				{
+					Image: "fedora",
+					Steps: []ContainerfileStep{
+						&RunStep{Mounts: []*Mount{{Type: "secret", ID: "token"}}, Command: "make"},
+					},
+				},
+			},
+		}
+
+		executor := &command.FakeExecutor{}
+
+		_, err := Build(context.Background(), cf, BuildOpts{Executor: executor})
+		assert.ErrorContains(t, err, "missing secret(s): token")
+		assert.Empty(t, executor.Executed())
+	})
+
+	t.Run("Unknown builder", func(t *testing.T) {
+		_, err := Build(context.Background(), newBuildContainerfile(), BuildOpts{Builder: "docker"})
+		assert.EqualError(t, err, `unknown builder "docker"`)
+	})
+}
+
+// Returns the value following the given flag in a rendered command.
+func flagValue(rendered, flag string) string {
+	fields := strings.Fields(rendered)
+
+	for i, field := range fields {
+		if field == flag && i+1 < len(fields) {
+			return fields[i+1]
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,376 @@
+package containerfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// The kinds of structural changes which Compare reports.
+type ChangeKind string
+
+const (
+	StageAdded       ChangeKind = "stage-added"
+	StageRemoved     ChangeKind = "stage-removed"
+	StageRenamed     ChangeKind = "stage-renamed"
+	BaseImageChanged ChangeKind = "base-image-changed"
+	PlatformChanged  ChangeKind = "platform-changed"
+	PackageAdded     ChangeKind = "package-added"
+	PackageRemoved   ChangeKind = "package-removed"
+	CopyChanged      ChangeKind = "copy-changed"
+	StepAdded        ChangeKind = "step-added"
+	StepRemoved      ChangeKind = "step-removed"
+	StepMoved        ChangeKind = "step-moved"
+)
+
+// A single structural change between two Containerfiles.
+type Change struct {
+	Kind ChangeKind `json:"kind"`
+	// The stage's name, or "stage N" for unnamed stages, counting from 1. Empty
+	// for changes to the directives and instructions before the first FROM.
+	Stage string `json:"stage,omitempty"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+func (c Change) String() string {
+	stage := c.Stage
+	if stage == "" {
+		stage = "header"
+	}
+
+	switch c.Kind {
+	case StageAdded:
+		return fmt.Sprintf("%s: stage added, based on %s", stage, c.New)
+	case StageRemoved:
+		return fmt.Sprintf("%s: stage removed", stage)
+	case StageRenamed:
+		return fmt.Sprintf("%s: stage renamed from %s", stage, c.Old)
+	case BaseImageChanged:
+		return fmt.Sprintf("%s: base image changed from %s to %s", stage, c.Old, c.New)
+	case PlatformChanged:
+		return fmt.Sprintf("%s: platform changed from %q to %q", stage, c.Old, c.New)
+	case PackageAdded:
+		return fmt.Sprintf("%s: package added: %s", stage, c.New)
+	case PackageRemoved:
+		return fmt.Sprintf("%s: package removed: %s", stage, c.Old)
+	case CopyChanged:
+		return fmt.Sprintf("%s: COPY changed: %s -> %s", stage, c.Old, c.New)
+	case StepAdded:
+		return fmt.Sprintf("%s: step added: %s", stage, c.New)
+	case StepRemoved:
+		return fmt.Sprintf("%s: step removed: %s", stage, c.Old)
+	case StepMoved:
+		return fmt.Sprintf("%s: step moved: %s", stage, c.New)
+	}
+
+	return fmt.Sprintf("%s: %s", stage, c.Kind)
+}
+
+// The structural changes between two Containerfiles.
+type Diff struct {
+	Changes []Change `json:"changes"`
+}
+
+func (d *Diff) Empty() bool {
+	return len(d.Changes) == 0
+}
+
+// Renders each change on its own line.
+func (d *Diff) String() string {
+	sb := &strings.Builder{}
+
+	for _, change := range d.Changes {
+		fmt.Fprintln(sb, change.String())
+	}
+
+	return sb.String()
+}
+
+func (d *Diff) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// Reports the structural changes between two Containerfiles rather than the
+// textual ones. Stages are matched by name and then by position so that renames
+// are detected, even among reordered stages. Within each stage, packages added
+// to or removed from install commands, changed COPY sources, and added,
+// removed, or reordered steps are reported. Differences in formatting and
+// comments are ignored.
+func Compare(before, after *Containerfile) *Diff {
+	d := &Diff{Changes: []Change{}}
+
+	d.compareSteps("", before.headerSteps(), after.headerSteps())
+
+	pairs, removed := matchStages(before.Stages, after.Stages)
+
+	for _, i := range removed {
+		d.add(Change{Kind: StageRemoved, Stage: stageLabel(before.Stages, i), Old: before.Stages[i].Image})
+	}
+
+	for j, stage := range after.Stages {
+		i, ok := pairs[j]
+		if !ok {
+			d.add(Change{Kind: StageAdded, Stage: stageLabel(after.Stages, j), New: stage.Image})
+			continue
+		}
+
+		d.compareStages(stageLabel(before.Stages, i), before.Stages[i], stageLabel(after.Stages, j), stage)
+	}
+
+	return d
+}
+
+func (d *Diff) add(change Change) {
+	d.Changes = append(d.Changes, change)
+}
+
+// Treats the directives as steps so that they are compared alongside the
+// header.
+func (c *Containerfile) headerSteps() []ContainerfileStep {
+	steps := []ContainerfileStep{}
+
+	for _, directive := range c.Directives {
+		steps = append(steps, NewRawStep(directive.Line()))
+	}
+
+	return append(steps, c.Header...)
+}
+
+// Returns the name of the given stage or its position if it is unnamed.
+func stageLabel(stages []*Stage, i int) string {
+	if stages[i].Name != "" {
+		return stages[i].Name
+	}
+
+	return fmt.Sprintf("stage %d", i+1)
+}
+
+// Matches each stage after the change with one before it: first by name, then
+// unnamed stages in order, and then whatever remains by position, which is
+// treated as a rename. Returns the index of the matching stage before the
+// change keyed by the index of the stage after the change, along with the
+// indexes of the stages which were removed.
+func matchStages(before, after []*Stage) (map[int]int, []int) {
+	pairs := map[int]int{}
+	matched := map[int]bool{}
+
+	for j, stage := range after {
+		if stage.Name == "" {
+			continue
+		}
+
+		i := slices.IndexFunc(before, func(s *Stage) bool { return s.Name == stage.Name })
+		if i != -1 && !matched[i] {
+			pairs[j] = i
+			matched[i] = true
+		}
+	}
+
+	unnamed := 0
+	for j, stage := range after {
+		if stage.Name != "" {
+			continue
+		}
+
+		for unnamed < len(before) && (before[unnamed].Name != "" || matched[unnamed]) {
+			unnamed++
+		}
+
+		if unnamed < len(before) {
+			pairs[j] = unnamed
+			matched[unnamed] = true
+		}
+	}
+
+	for j := range after {
+		if _, ok := pairs[j]; !ok && j < len(before) && !matched[j] {
+			pairs[j] = j
+			matched[j] = true
+		}
+	}
+
+	removed := []int{}
+	for i := range before {
+		if !matched[i] {
+			removed = append(removed, i)
+		}
+	}
+
+	return pairs, removed
+}
+
+func (d *Diff) compareStages(beforeLabel string, before *Stage, label string, after *Stage) {
+	if before.Name != after.Name {
+		d.add(Change{Kind: StageRenamed, Stage: label, Old: beforeLabel, New: label})
+	}
+
+	if before.Image != after.Image {
+		d.add(Change{Kind: BaseImageChanged, Stage: label, Old: before.Image, New: after.Image})
+	}
+
+	if before.Platform != after.Platform {
+		d.add(Change{Kind: PlatformChanged, Stage: label, Old: before.Platform, New: after.Platform})
+	}
+
+	beforePackages := stagePackages(before.Steps)
+	afterPackages := stagePackages(after.Steps)
+
+	for _, pkg := range afterPackages {
+		if !slices.Contains(beforePackages, pkg) {
+			d.add(Change{Kind: PackageAdded, Stage: label, New: pkg})
+		}
+	}
+
+	for _, pkg := range beforePackages {
+		if !slices.Contains(afterPackages, pkg) {
+			d.add(Change{Kind: PackageRemoved, Stage: label, Old: pkg})
+		}
+	}
+
+	d.compareSteps(label, before.Steps, after.Steps)
+}
+
+// Returns the sorted, unique packages installed by the given steps.
+func stagePackages(steps []ContainerfileStep) []string {
+	out := []string{}
+
+	for _, step := range steps {
+		_, commands, _ := runStepCommands(UnwrapStep(step))
+
+		for _, cmd := range commands {
+			out = append(out, cmd.packages...)
+		}
+	}
+
+	slices.Sort(out)
+
+	return slices.Compact(out)
+}
+
+// A step along with what identifies it across versions of a Containerfile.
+// RUN steps are identified without their packages, which are compared
+// separately, and COPY steps are identified by their destination.
+type stepEntry struct {
+	key  string
+	line string
+}
+
+func newStepEntry(step ContainerfileStep) stepEntry {
+	step = UnwrapStep(step)
+	line := step.Line()
+
+	if flags, commands, ok := runStepCommands(step); ok {
+		prefixes := []string{}
+		for _, cmd := range commands {
+			prefixes = append(prefixes, cmd.prefix)
+		}
+
+		key := strings.Join(append(append([]string{"RUN"}, flags...), strings.Join(prefixes, " && ")), " ")
+		return stepEntry{key: key, line: line}
+	}
+
+	switch s := step.(type) {
+	case *CopyStep:
+		return stepEntry{key: "COPY " + s.Dest, line: line}
+	case *InlineFileStep:
+		return stepEntry{key: "COPY " + s.Dest, line: line}
+	}
+
+	return stepEntry{key: line, line: line}
+}
+
+// Compares steps by finding the longest common subsequence of their keys.
+// Steps outside of it which appear on both sides were moved.
+func (d *Diff) compareSteps(label string, before, after []ContainerfileStep) {
+	beforeEntries := []stepEntry{}
+	for _, step := range before {
+		beforeEntries = append(beforeEntries, newStepEntry(step))
+	}
+
+	afterEntries := []stepEntry{}
+	for _, step := range after {
+		afterEntries = append(afterEntries, newStepEntry(step))
+	}
+
+	common := longestCommonSubsequence(beforeEntries, afterEntries)
+
+	beforeMatched := map[int]bool{}
+	afterMatched := map[int]bool{}
+
+	for _, pair := range common {
+		beforeMatched[pair[0]] = true
+		afterMatched[pair[1]] = true
+
+		b, a := beforeEntries[pair[0]], afterEntries[pair[1]]
+		if b.line != a.line && strings.HasPrefix(b.key, "COPY ") {
+			d.add(Change{Kind: CopyChanged, Stage: label, Old: b.line, New: a.line})
+		}
+	}
+
+	for i, b := range beforeEntries {
+		if beforeMatched[i] {
+			continue
+		}
+
+		j := slices.IndexFunc(afterEntries, func(a stepEntry) bool { return a.key == b.key })
+		for j != -1 && afterMatched[j] {
+			next := slices.IndexFunc(afterEntries[j+1:], func(a stepEntry) bool { return a.key == b.key })
+			if next == -1 {
+				j = -1
+			} else {
+				j += next + 1
+			}
+		}
+
+		if j == -1 {
+			d.add(Change{Kind: StepRemoved, Stage: label, Old: b.line})
+			continue
+		}
+
+		afterMatched[j] = true
+		d.add(Change{Kind: StepMoved, Stage: label, Old: b.line, New: afterEntries[j].line})
+	}
+
+	for j, a := range afterEntries {
+		if !afterMatched[j] {
+			d.add(Change{Kind: StepAdded, Stage: label, New: a.line})
+		}
+	}
+}
+
+// Returns the index pairs of the longest common subsequence of keys.
+func longestCommonSubsequence(before, after []stepEntry) [][2]int {
+	lengths := make([][]int, len(before)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(after)+1)
+	}
+
+	for i := len(before) - 1; i >= 0; i-- {
+		for j := len(after) - 1; j >= 0; j-- {
+			if before[i].key == after[j].key {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	out := [][2]int{}
+
+	for i, j := 0, 0; i < len(before) && j < len(after); {
+		switch {
+		case before[i].key == after[j].key:
+			out = append(out, [2]int{i, j})
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+
+	return out
+}
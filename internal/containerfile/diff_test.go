@@ -0,0 +1,150 @@
+package containerfile
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompare(t *testing.T) {
+	before := `ARG VERSION=1.0
+FROM quay.io/centos/stream:9 AS builder
+RUN dnf install -y git golang
+COPY . /src
+RUN make
+FROM quay.io/centos/stream:9 AS base
+RUN dnf install -y python3
+FROM fedora:40
+WORKDIR /app
+USER zack
+COPY --from=builder /src/app /usr/bin/app
+`
+
+	after := `ARG VERSION=1.1
+FROM quay.io/centos/stream:10 AS build
+# Comments do not count as changes.
+RUN dnf install -y \
+    golang \
+    make
+COPY ./cmd /src
+RUN make
+FROM fedora:40
+USER zack
+WORKDIR /app
+COPY --from=build /src/app /usr/bin/app
+RUN echo done
+`
+
+	d := Compare(mustParse(t, before), mustParse(t, after))
+
+	assert.Equal(t, []Change{
+		{Kind: StepRemoved, Old: "ARG VERSION=1.0"},
+		{Kind: StepAdded, New: "ARG VERSION=1.1"},
+		{Kind: StageRemoved, Stage: "base", Old: "quay.io/centos/stream:9"},
+		{Kind: StageRenamed, Stage: "build", Old: "builder", New: "build"},
+		{Kind: BaseImageChanged, Stage: "build", Old: "quay.io/centos/stream:9", New: "quay.io/centos/stream:10"},
+		{Kind: PackageAdded, Stage: "build", New: "make"},
+		{Kind: PackageRemoved, Stage: "build", Old: "git"},
+		{Kind: CopyChanged, Stage: "build", Old: "COPY . /src", New: "COPY ./cmd /src"},
+		{Kind: CopyChanged, Stage: "stage 2", Old: "COPY --from=builder /src/app /usr/bin/app", New: "COPY --from=build /src/app /usr/bin/app"},
+		{Kind: StepMoved, Stage: "stage 2", Old: "WORKDIR /app", New: "WORKDIR /app"},
+		{Kind: StepAdded, Stage: "stage 2", New: "RUN echo done"},
+	}, d.Changes)
+
+	assert.Equal(t, `header: step removed: ARG VERSION=1.0
+header: step added: ARG VERSION=1.1
+base: stage removed
+build: stage renamed from builder
+build: base image changed from quay.io/centos/stream:9 to quay.io/centos/stream:10
+build: package added: make
+build: package removed: git
+build: COPY changed: COPY . /src -> COPY ./cmd /src
+stage 2: COPY changed: COPY --from=builder /src/app /usr/bin/app -> COPY --from=build /src/app /usr/bin/app
+stage 2: step moved: WORKDIR /app
+stage 2: step added: RUN echo done
+`, d.String())
+
+	out, err := d.JSON()
+	require.NoError(t, err)
+
+	decoded := &Diff{}
+	require.NoError(t, json.Unmarshal(out, decoded))
+	assert.Equal(t, d, decoded)
+}
+
+func TestCompareIgnoresFormatting(t *testing.T) {
+	before := mustParse(t, "FROM fedora AS base\nRUN dnf install -y jq git && dnf clean all\n")
+	after := mustParse(t, before.Format(FormatOpts{Comments: true}))
+
+	d := Compare(before, after)
+	assert.True(t, d.Empty())
+	assert.Equal(t, "", d.String())
+}
+
+func TestCompareStages(t *testing.T) {
+	testCases := []struct {
+		name     string
+		before   string
+		after    string
+		expected []Change
+	}{
+		{
+			name:   "Stage added",
+			before: "FROM fedora AS base\n",
+			after:  "FROM fedora AS base\nFROM base AS final\n",
+			expected: []Change{
+				{Kind: StageAdded, Stage: "final", New: "base"},
+			},
+		},
+		{
+			name:   "Stages reordered",
+			before: "FROM fedora AS a\nFROM ubuntu AS b\n",
+			after:  "FROM ubuntu AS b\nFROM fedora AS a\n",
+			// Stages are matched by name rather than by position.
+			expected: []Change{},
+		},
+		{
+			name:   "Platform changed",
+			before: "FROM fedora\n",
+			after:  "FROM --platform=linux/arm64 fedora\n",
+			expected: []Change{
+				{Kind: PlatformChanged, Stage: "stage 1", New: "linux/arm64"},
+			},
+		},
+		{
+			name:   "Directive added",
+			before: "FROM fedora\n",
+			after:  "# syntax=docker/dockerfile:1\nFROM fedora\n",
+			expected: []Change{
+				{Kind: StepAdded, New: "# syntax=docker/dockerfile:1"},
+			},
+		},
+		{
+			name:   "Package moved between steps",
+			before: "FROM fedora\nRUN dnf install -y git\nRUN dnf install -y jq\n",
+			after:  "FROM fedora\nRUN dnf install -y git jq\n",
+			expected: []Change{
+				{Kind: StepRemoved, Stage: "stage 1", Old: "RUN dnf install -y jq"},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			d := Compare(mustParse(t, testCase.before), mustParse(t, testCase.after))
+			assert.Equal(t, testCase.expected, d.Changes)
+		})
+	}
+}
+
+func mustParse(t *testing.T, in string) *Containerfile {
+	t.Helper()
+
+	cf, err := Parse(strings.NewReader(in))
+	require.NoError(t, err)
+
+	return cf
+}
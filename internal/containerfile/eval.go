@@ -0,0 +1,533 @@
+package containerfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+)
+
+// Build args which are defined in the global scope without being declared.
+// Their values are only known at build time unless they are given.
+var platformArgs = []string{
+	"BUILDPLATFORM", "BUILDOS", "BUILDARCH", "BUILDVARIANT",
+	"TARGETPLATFORM", "TARGETOS", "TARGETARCH", "TARGETVARIANT",
+}
+
+// Build args which are available to every stage when given, without being
+// declared by an ARG instruction.
+var proxyArgs = []string{
+	"HTTP_PROXY", "http_proxy", "HTTPS_PROXY", "https_proxy",
+	"FTP_PROXY", "ftp_proxy", "NO_PROXY", "no_proxy", "ALL_PROXY", "all_proxy",
+}
+
+// Instructions whose variables buildah substitutes itself.
+var expandedInstructions = []string{
+	"ADD", "COPY", "ENV", "EXPOSE", "LABEL", "STOPSIGNAL", "USER", "VOLUME", "WORKDIR",
+}
+
+// Instructions run by a shell within the container at runtime, which only
+// sees the environment and not the build args.
+var runtimeInstructions = []string{"CMD", "ENTRYPOINT", "HEALTHCHECK"}
+
+type EvalOpts struct {
+	// Given with --build-arg, these override the defaults of ARG instructions.
+	BuildArgs []command.BuildArg
+	// Environment variables set by external base images, e.g., PATH.
+	Env map[string]string
+}
+
+// A reference to a variable which is neither declared by an ARG or ENV
+// instruction in scope nor set by the base image.
+type UndefinedVar struct {
+	Name string `json:"name"`
+	// The stage's name, or "stage N" for unnamed stages. Empty for the header.
+	Stage string `json:"stage,omitempty"`
+	// The instruction containing the reference.
+	Line string `json:"line"`
+}
+
+func (u UndefinedVar) String() string {
+	stage := u.Stage
+	if stage == "" {
+		stage = "header"
+	}
+
+	return fmt.Sprintf("%s: undefined variable %s in %s", stage, u.Name, u.Line)
+}
+
+// The result of evaluating the variables within a Containerfile.
+type Evaluation struct {
+	// A copy of the Containerfile with the values of the variables in scope
+	// substituted into each instruction.
+	Containerfile *Containerfile
+	Undefined     []UndefinedVar
+}
+
+// Tracks the ARG and ENV declarations within the Containerfile and
+// substitutes their values into each instruction, as buildah would given the
+// same build args. Global ARGs are only in scope for FROM instructions unless
+// a stage declares them again, and each stage inherits the environment of the
+// stage it is based on.
+//
+// References to undefined variables are left as they are and reported, except
+// within RUN, CMD, and similar instructions, since a shell may define those.
+// References to TARGETARCH and the like are also left as they are unless given
+// since they are only known at build time.
+func (c *Containerfile) Evaluate(opts EvalOpts) (*Evaluation, error) {
+	e := &evaluator{
+		buildArgs: map[string]string{},
+		baseEnv:   opts.Env,
+		stageEnv:  map[string]map[string]string{},
+		escape:    c.escapeChar(),
+		reported:  map[UndefinedVar]bool{},
+		out: &Evaluation{
+			Containerfile: &Containerfile{Directives: c.Directives, Tag: c.Tag},
+			Undefined:     []UndefinedVar{},
+		},
+	}
+
+	for _, arg := range opts.BuildArgs {
+		e.buildArgs[arg.Name] = arg.Value
+	}
+
+	global := &scope{args: map[string]*string{}, env: map[string]string{}}
+	for _, name := range platformArgs {
+		if val, ok := e.buildArgs[name]; ok {
+			global.args[name] = &val
+		} else {
+			global.args[name] = nil
+		}
+	}
+
+	for _, step := range c.Header {
+		out, err := e.step("", global, step)
+		if err != nil {
+			return nil, err
+		}
+
+		e.out.Containerfile.Header = append(e.out.Containerfile.Header, out)
+	}
+
+	for i, stage := range c.Stages {
+		out, err := e.stage(stageLabel(c.Stages, i), global, stage)
+		if err != nil {
+			return nil, err
+		}
+
+		e.out.Containerfile.Stages = append(e.out.Containerfile.Stages, out)
+	}
+
+	return e.out, nil
+}
+
+// Returns the escape character set by the escape directive, if any.
+func (c *Containerfile) escapeChar() byte {
+	for _, directive := range c.Directives {
+		if directive.Name == "escape" && len(directive.Value) == 1 {
+			return directive.Value[0]
+		}
+	}
+
+	return '\\'
+}
+
+// The variables declared within the global scope or a stage.
+type scope struct {
+	// A nil value means that it is only known at build time.
+	args map[string]*string
+	env  map[string]string
+	// ARG instructions without a default take the value from the global scope.
+	global *scope
+}
+
+// Environment variables take precedence over build args of the same name.
+func (s *scope) lookup(name string) (*string, bool) {
+	if val, ok := s.env[name]; ok {
+		return &val, true
+	}
+
+	val, ok := s.args[name]
+	return val, ok
+}
+
+type evaluator struct {
+	buildArgs map[string]string
+	baseEnv   map[string]string
+	// The final environment of each named stage, which stages based on it
+	// inherit.
+	stageEnv map[string]map[string]string
+	escape   byte
+	reported map[UndefinedVar]bool
+	out      *Evaluation
+}
+
+func (e *evaluator) stage(label string, global *scope, stage *Stage) (*Stage, error) {
+	from := (&FromStep{Image: stage.Image, As: stage.Name, Platform: stage.Platform}).Line()
+
+	image, err := e.expand(label, from, stage.Image, global, false)
+	if err != nil {
+		return nil, err
+	}
+
+	platform, err := e.expand(label, from, stage.Platform, global, false)
+	if err != nil {
+		return nil, err
+	}
+
+	env, ok := e.stageEnv[image]
+	if !ok {
+		env = e.baseEnv
+	}
+
+	sc := &scope{args: map[string]*string{}, env: map[string]string{}, global: global}
+
+	for name, val := range env {
+		sc.env[name] = val
+	}
+
+	for _, name := range proxyArgs {
+		if val, ok := e.buildArgs[name]; ok {
+			sc.args[name] = &val
+		}
+	}
+
+	out := &Stage{Name: stage.Name, Image: image, Platform: platform, Comment: stage.Comment}
+
+	for _, step := range stage.Steps {
+		evaluated, err := e.step(label, sc, step)
+		if err != nil {
+			return nil, err
+		}
+
+		out.Steps = append(out.Steps, evaluated)
+	}
+
+	if stage.Name != "" {
+		e.stageEnv[stage.Name] = sc.env
+	}
+
+	return out, nil
+}
+
+// Returns the given step with the variables in scope substituted. Steps
+// which are unchanged are returned as they are; the rest become RawSteps.
+func (e *evaluator) step(label string, sc *scope, step ContainerfileStep) (ContainerfileStep, error) {
+	switch s := step.(type) {
+	case *CommentedStep:
+		out, err := e.step(label, sc, s.Step)
+		if err != nil {
+			return nil, err
+		}
+
+		return WithComment(s.Comment, out), nil
+	case *CommentStep:
+		return s, nil
+	case *HeredocRunStep:
+		return e.heredocRun(label, sc, s)
+	case *InlineFileStep:
+		return e.inlineFile(label, sc, s)
+	}
+
+	line := step.Line()
+
+	// The bodies of heredocs which were not parsed into a typed step are kept
+	// verbatim since it is unknown whether their delimiters were quoted.
+	head, body, hasBody := strings.Cut(line, "\n")
+	if !hasBody || !heredocPattern.MatchString(head) {
+		head, body, hasBody = line, "", false
+	}
+
+	instruction, rest := cutWord(head)
+	instruction = strings.ToUpper(instruction)
+
+	var expanded string
+	var err error
+
+	switch {
+	case isExecForm(instruction, rest):
+		// No shell runs the exec form, so its variables are never substituted.
+		return step, nil
+	case instruction == "RUN":
+		expanded, err = e.expand(label, head, head, sc, true)
+	case slices.Contains(runtimeInstructions, instruction):
+		expanded, err = e.expand(label, head, head, &scope{env: sc.env}, true)
+	case slices.Contains(expandedInstructions, instruction):
+		expanded, err = e.expand(label, head, head, sc, false)
+	case instruction == "ARG":
+		// ARG defaults are substituted but the names are not.
+		expanded, err = e.expand(label, head, head, sc, false)
+	default:
+		return step, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if instruction == "ARG" || instruction == "ENV" {
+		if err := e.declare(label, head, instruction, rest, sc); err != nil {
+			return nil, err
+		}
+	}
+
+	if hasBody {
+		expanded += "\n" + body
+	}
+
+	if expanded == line {
+		return step, nil
+	}
+
+	return NewRawStep(expanded), nil
+}
+
+// Reports whether the given RUN, CMD, ENTRYPOINT, or HEALTHCHECK instruction
+// uses the exec form, e.g., CMD ["echo", "$HOME"].
+func isExecForm(instruction, rest string) bool {
+	switch instruction {
+	case "RUN":
+		_, rest = cutFlags(rest)
+	case "HEALTHCHECK":
+		_, rest = cutFlags(rest)
+
+		word, cmd := cutWord(rest)
+		if !strings.EqualFold(word, "CMD") {
+			return false
+		}
+
+		rest = cmd
+	case "CMD", "ENTRYPOINT":
+	default:
+		return false
+	}
+
+	rest = strings.TrimSpace(rest)
+
+	return strings.HasPrefix(rest, "[") && json.Valid([]byte(rest))
+}
+
+func (e *evaluator) heredocRun(label string, sc *scope, step *HeredocRunStep) (ContainerfileStep, error) {
+	out := *step
+	line, _, _ := strings.Cut(step.Line(), "\n")
+
+	cmd, err := e.expand(label, line, step.Command, sc, true)
+	if err != nil {
+		return nil, err
+	}
+
+	out.Command = cmd
+
+	if step.Expand {
+		out.Script, err = e.expandBody(label, line, step.Script, sc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &out, nil
+}
+
+func (e *evaluator) inlineFile(label string, sc *scope, step *InlineFileStep) (ContainerfileStep, error) {
+	out := *step
+	line, _, _ := strings.Cut(step.Line(), "\n")
+
+	dest, err := e.expand(label, line, step.Dest, sc, false)
+	if err != nil {
+		return nil, err
+	}
+
+	out.Dest = dest
+
+	if step.Expand {
+		out.Content, err = e.expandBody(label, line, step.Content, sc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &out, nil
+}
+
+// Substitutes the variables in scope into s, reporting any undefined ones as
+// being within the given line unless s is run by a shell.
+func (e *evaluator) expand(label, line, s string, sc *scope, shell bool) (string, error) {
+	ex := &expander{lookup: sc.lookup, escape: e.escape, quotes: true, shell: shell}
+
+	out, undefined, err := ex.expand(s)
+	if err != nil {
+		return "", e.errorf(label, line, err)
+	}
+
+	if !shell {
+		e.report(label, line, undefined)
+	}
+
+	return out, nil
+}
+
+// Substitutes the variables in scope into the body of an unquoted heredoc,
+// within which quotes are not significant.
+func (e *evaluator) expandBody(label, line, body string, sc *scope) (string, error) {
+	ex := &expander{lookup: sc.lookup, escape: e.escape, shell: true}
+
+	out, _, err := ex.expand(body)
+	if err != nil {
+		return "", e.errorf(label, line, err)
+	}
+
+	return out, nil
+}
+
+func (e *evaluator) errorf(label, line string, err error) error {
+	if label == "" {
+		label = "header"
+	}
+
+	return fmt.Errorf("%s: %s: %w", label, line, err)
+}
+
+func (e *evaluator) report(label, line string, names []string) {
+	for _, name := range names {
+		undefined := UndefinedVar{Name: name, Stage: label, Line: line}
+		if e.reported[undefined] {
+			continue
+		}
+
+		e.reported[undefined] = true
+		e.out.Undefined = append(e.out.Undefined, undefined)
+	}
+}
+
+// A variable declared by an ARG or ENV instruction.
+type declaration struct {
+	name     string
+	val      string
+	hasValue bool
+}
+
+// Declares the variables of an ARG or ENV instruction. Their values are
+// substituted before any of the instruction's own declarations take effect.
+func (e *evaluator) declare(label, line, instruction, rest string, sc *scope) error {
+	words := splitWords(rest, e.escape)
+	decls := []declaration{}
+
+	if instruction == "ENV" && len(words) != 0 && !strings.Contains(words[0], "=") {
+		// The legacy ENV NAME VALUE form.
+		name, raw := cutWord(rest)
+
+		val, err := e.value(label, line, raw, sc)
+		if err != nil {
+			return err
+		}
+
+		decls = append(decls, declaration{name: name, val: val, hasValue: true})
+	} else {
+		for _, word := range words {
+			name, raw, hasValue := strings.Cut(word, "=")
+
+			val, err := e.value(label, line, raw, sc)
+			if err != nil {
+				return err
+			}
+
+			decls = append(decls, declaration{name: name, val: val, hasValue: hasValue})
+		}
+	}
+
+	for _, decl := range decls {
+		if instruction == "ENV" {
+			sc.env[decl.name] = decl.val
+			continue
+		}
+
+		sc.args[decl.name] = e.argValue(sc, decl)
+	}
+
+	return nil
+}
+
+// Build args take precedence over defaults. ARGs without either take their
+// value from the global scope, if declared there, and are empty otherwise.
+func (e *evaluator) argValue(sc *scope, decl declaration) *string {
+	if val, ok := e.buildArgs[decl.name]; ok {
+		return &val
+	}
+
+	if decl.hasValue {
+		return &decl.val
+	}
+
+	if sc.global != nil {
+		if val, ok := sc.global.args[decl.name]; ok {
+			return val
+		}
+	}
+
+	empty := ""
+	return &empty
+}
+
+// Returns the value of an ARG or ENV declaration with its variables
+// substituted and its quotes removed. Undefined variables are reported when
+// the instruction as a whole is expanded.
+func (e *evaluator) value(label, line, raw string, sc *scope) (string, error) {
+	ex := &expander{lookup: sc.lookup, escape: e.escape, quotes: true}
+
+	out, _, err := ex.expand(raw)
+	if err != nil {
+		return "", e.errorf(label, line, err)
+	}
+
+	words, ok := splitLabelWords(out)
+	if !ok || len(words) != 1 {
+		return out, nil
+	}
+
+	return words[0].text, nil
+}
+
+// Splits on unquoted whitespace, keeping quotes and escapes.
+func splitWords(s string, escape byte) []string {
+	out := []string{}
+	current := &strings.Builder{}
+	var quote byte
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == escape && quote == '"' && i+1 < len(s) {
+				current.WriteByte(c)
+				i++
+				c = s[i]
+			}
+		case c == escape && i+1 < len(s):
+			current.WriteByte(c)
+			i++
+			c = s[i]
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ' ' || c == '\t':
+			if current.Len() != 0 {
+				out = append(out, current.String())
+				current.Reset()
+			}
+
+			continue
+		}
+
+		current.WriteByte(c)
+	}
+
+	if current.Len() != 0 {
+		out = append(out, current.String())
+	}
+
+	return out
+}
@@ -0,0 +1,185 @@
+package containerfile
+
+import (
+	"testing"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluate(t *testing.T) {
+	in := `ARG BASE=fedora
+ARG VERSION
+FROM --platform=$BUILDPLATFORM ${BASE}:${VERSION:-40} AS builder
+ARG VERSION
+ENV APP_HOME=/opt/app GOFLAGS="-mod=vendor $EXTRA"
+WORKDIR $APP_HOME/src
+RUN echo "$APP_HOME $VERSION $HOME" '$APP_HOME'
+COPY . ${MISSING}/src
+FROM builder
+RUN echo $APP_HOME $BASE
+CMD echo $VERSION $APP_HOME
+`
+
+	ev, err := mustParse(t, in).Evaluate(EvalOpts{
+		BuildArgs: []command.BuildArg{{Name: "VERSION", Value: "41"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, `ARG BASE=fedora
+ARG VERSION
+
+FROM --platform=$BUILDPLATFORM fedora:41 AS builder
+ARG VERSION
+ENV APP_HOME=/opt/app GOFLAGS="-mod=vendor $EXTRA"
+WORKDIR /opt/app/src
+RUN echo "/opt/app 41 $HOME" '$APP_HOME'
+COPY . ${MISSING}/src
+
+FROM builder
+RUN echo /opt/app $BASE
+CMD echo $VERSION /opt/app
+
+`, ev.Containerfile.String())
+
+	assert.Equal(t, []UndefinedVar{
+		{Name: "EXTRA", Stage: "builder", Line: `ENV APP_HOME=/opt/app GOFLAGS="-mod=vendor $EXTRA"`},
+		{Name: "MISSING", Stage: "builder", Line: "COPY . ${MISSING}/src"},
+	}, ev.Undefined)
+
+	assert.Equal(t, "builder: undefined variable MISSING in COPY . ${MISSING}/src", ev.Undefined[1].String())
+}
+
+func TestEvaluateScopes(t *testing.T) {
+	testCases := []struct {
+		name      string
+		in        string
+		opts      EvalOpts
+		expected  string
+		undefined []string
+	}{
+		{
+			name: "Global ARGs are not in scope within stages",
+			in:   "ARG DIR=/src\nFROM fedora\nWORKDIR $DIR\n",
+			// Since references in WORKDIR are expanded by buildah.
+			expected:  "WORKDIR $DIR",
+			undefined: []string{"DIR"},
+		},
+		{
+			name:     "Redeclared global ARGs take the global value",
+			in:       "ARG DIR=/src\nFROM fedora\nARG DIR\nWORKDIR $DIR\n",
+			expected: "WORKDIR /src",
+		},
+		{
+			name:     "Build args override defaults",
+			in:       "FROM fedora\nARG DIR=/src\nWORKDIR $DIR\n",
+			opts:     EvalOpts{BuildArgs: []command.BuildArg{{Name: "DIR", Value: "/app"}}},
+			expected: "WORKDIR /app",
+		},
+		{
+			name:      "ARGs are not in scope before they are declared",
+			in:        "FROM fedora\nWORKDIR $DIR\nARG DIR=/src\nUSER $DIR\n",
+			expected:  "USER /src",
+			undefined: []string{"DIR"},
+		},
+		{
+			name:     "ENV takes precedence over ARG",
+			in:       "FROM fedora\nARG DIR=/src\nENV DIR=/app\nWORKDIR $DIR\n",
+			expected: "WORKDIR /app",
+		},
+		{
+			name:     "ENV values are substituted before being declared",
+			in:       "FROM fedora\nENV A=1\nENV A=2 B=$A\nUSER $B\n",
+			expected: "USER 1",
+		},
+		{
+			name:     "Legacy ENV form",
+			in:       "FROM fedora\nENV GREETING hello world\nLABEL greeting=\"$GREETING\"\n",
+			expected: `LABEL greeting="hello world"`,
+		},
+		{
+			name:     "Base image environment",
+			in:       "FROM fedora\nENV PATH=$PATH:/opt/bin\nUSER $PATH\n",
+			opts:     EvalOpts{Env: map[string]string{"PATH": "/usr/bin"}},
+			expected: "USER /usr/bin:/opt/bin",
+		},
+		{
+			name:     "Stages inherit the environment of the stage they are based on",
+			in:       "FROM fedora AS base\nENV DIR=/src\nFROM base\nWORKDIR $DIR\n",
+			expected: "WORKDIR /src",
+		},
+		{
+			name:     "Platform args are only known at build time",
+			in:       "FROM fedora\nARG TARGETARCH\nWORKDIR /opt/${TARGETARCH:-amd64}\n",
+			expected: "WORKDIR /opt/${TARGETARCH:-amd64}",
+		},
+		{
+			name:     "Platform args given as build args",
+			in:       "FROM fedora\nARG TARGETARCH\nWORKDIR /opt/$TARGETARCH\n",
+			opts:     EvalOpts{BuildArgs: []command.BuildArg{{Name: "TARGETARCH", Value: "arm64"}}},
+			expected: "WORKDIR /opt/arm64",
+		},
+		{
+			name:     "Exec form CMD is not substituted",
+			in:       "FROM fedora\nENV GREETING=hello\nCMD [\"echo\", \"$GREETING\"]\n",
+			expected: `CMD ["echo", "$GREETING"]`,
+		},
+		{
+			name:     "Exec form RUN is not substituted",
+			in:       "FROM fedora\nARG NAME=app\nRUN --network=none [\"echo\", \"$NAME\", \"$UNDEFINED\"]\n",
+			expected: `RUN --network=none ["echo", "$NAME", "$UNDEFINED"]`,
+		},
+		{
+			name:     "Exec form ENTRYPOINT and HEALTHCHECK are not substituted",
+			in:       "FROM fedora\nENV PORT=8080\nENTRYPOINT [\"serve\", \"$PORT\"]\nHEALTHCHECK --interval=5s CMD [\"curl\", \"localhost:$PORT\"]\n",
+			expected: `HEALTHCHECK --interval=5s CMD ["curl", "localhost:$PORT"]`,
+		},
+		{
+			name:     "Shell form CMD is substituted",
+			in:       "FROM fedora\nENV GREETING=hello\nCMD echo \"$GREETING\" [not json]\n",
+			expected: `CMD echo "hello" [not json]`,
+		},
+		{
+			name:     "Shell variables with modifiers are left to the shell",
+			in:       "FROM fedora\nARG NAME=app\nRUN for f in a b; do echo ${f:-none} ${f:+set} ${f-x} ${f+y} ${NAME:-none}; done\n",
+			expected: "RUN for f in a b; do echo ${f:-none} ${f:+set} ${f-x} ${f+y} app; done",
+		},
+		{
+			name:     "Heredoc bodies are only substituted when unquoted",
+			in:       "FROM fedora\nARG NAME=app\nCOPY <<EOF /etc/$NAME.conf\nname=$NAME\nEOF\n",
+			expected: "COPY <<EOF /etc/app.conf\nname=app\nEOF",
+		},
+		{
+			name:     "Quoted heredoc bodies are kept verbatim",
+			in:       "FROM fedora\nARG NAME=app\nRUN <<'EOF'\necho $NAME\nEOF\n",
+			expected: "RUN <<'EOF'\necho $NAME\nEOF",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ev, err := mustParse(t, testCase.in).Evaluate(testCase.opts)
+			require.NoError(t, err)
+
+			steps := ev.Containerfile.Stages[len(ev.Containerfile.Stages)-1].Steps
+			assert.Equal(t, testCase.expected, steps[len(steps)-1].Line())
+
+			undefined := []string{}
+			for _, u := range ev.Undefined {
+				undefined = append(undefined, u.Name)
+			}
+
+			if testCase.undefined == nil {
+				testCase.undefined = []string{}
+			}
+
+			assert.Equal(t, testCase.undefined, undefined)
+		})
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	_, err := mustParse(t, "FROM fedora\nWORKDIR ${DIR%/*}\n").Evaluate(EvalOpts{})
+	assert.EqualError(t, err, `stage 1: WORKDIR ${DIR%/*}: unsupported variable substitution "${DIR%/*}"`)
+}
@@ -0,0 +1,241 @@
+package containerfile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Substitutes the given variables into s the way buildah does for
+// instructions such as COPY, ENV, and WORKDIR. $VAR, ${VAR}, ${VAR:-default},
+// and ${VAR:+alternative} are supported, as are the forms without the colon,
+// which only test whether VAR is set rather than whether it is empty. Nothing
+// within single quotes or after a backslash is substituted.
+//
+// References to variables which are not given are left as they are, and
+// their names are returned so that they may be reported.
+func Expand(s string, vars map[string]string) (string, []string, error) {
+	e := &expander{
+		lookup: func(name string) (*string, bool) {
+			val, ok := vars[name]
+			return &val, ok
+		},
+		escape: '\\',
+		quotes: true,
+	}
+
+	return e.expand(s)
+}
+
+// Returns the value of the named variable and whether it is defined. A nil
+// value for a defined variable means that its value is only known at build
+// time, e.g., TARGETARCH, in which case references to it are left as they
+// are.
+type lookupFunc func(name string) (*string, bool)
+
+type expander struct {
+	lookup lookupFunc
+	escape byte
+	// Whether quotes are significant. They are not within heredoc bodies.
+	quotes bool
+	// Whether s is run by a shell, as RUN commands are, in which case
+	// substitutions the shell supports but buildah does not, e.g., ${VAR%.*},
+	// are left as they are instead of being an error.
+	shell bool
+}
+
+func (e *expander) expand(s string) (string, []string, error) {
+	sb := &strings.Builder{}
+	undefined := []string{}
+	var quote byte
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			}
+
+			sb.WriteByte(c)
+		case c == e.escape && i+1 < len(s):
+			sb.WriteByte(c)
+			sb.WriteByte(s[i+1])
+			i++
+		case e.quotes && c == '\'' && quote == 0:
+			quote = c
+			sb.WriteByte(c)
+		case e.quotes && c == '"':
+			if quote == 0 {
+				quote = c
+			} else {
+				quote = 0
+			}
+
+			sb.WriteByte(c)
+		case c == '$':
+			ref, ok := parseReference(s[i:])
+			if !ok {
+				sb.WriteByte(c)
+				continue
+			}
+
+			val, names, err := e.substitute(ref)
+			if err != nil {
+				return "", nil, err
+			}
+
+			sb.WriteString(val)
+			undefined = append(undefined, names...)
+			i += len(ref.text) - 1
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	if quote != 0 {
+		return "", nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+
+	return sb.String(), undefined, nil
+}
+
+// A variable reference, e.g., ${VAR:-default}.
+type reference struct {
+	// The reference as it appears, which is kept when it cannot be substituted.
+	text     string
+	name     string
+	modifier string
+	word     string
+}
+
+// Parses the variable reference at the start of s, which begins with $.
+// Returns false if the $ does not begin a reference, e.g., $1 or $(cmd).
+func parseReference(s string) (reference, bool) {
+	if len(s) < 2 {
+		return reference{}, false
+	}
+
+	if isNameStart(s[1]) {
+		end := 2
+		for end < len(s) && isNameChar(s[end]) {
+			end++
+		}
+
+		return reference{text: s[:end], name: s[1:end]}, true
+	}
+
+	if s[1] != '{' {
+		return reference{}, false
+	}
+
+	depth := 0
+	for end := 1; end < len(s); end++ {
+		switch s[end] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		}
+
+		if depth != 0 {
+			continue
+		}
+
+		ref := reference{text: s[:end+1]}
+		body := s[2:end]
+
+		nameEnd := 0
+		if len(body) != 0 && isNameStart(body[0]) {
+			for nameEnd < len(body) && isNameChar(body[nameEnd]) {
+				nameEnd++
+			}
+		}
+
+		ref.name = body[:nameEnd]
+		ref.modifier, ref.word = cutModifier(body[nameEnd:])
+
+		return ref, true
+	}
+
+	return reference{}, false
+}
+
+// Splits the modifier, e.g., :-, from the word which follows it. Unsupported
+// modifiers are returned whole with an empty word.
+func cutModifier(s string) (string, string) {
+	for _, modifier := range []string{":-", ":+", "-", "+"} {
+		if word, ok := strings.CutPrefix(s, modifier); ok {
+			return modifier, word
+		}
+	}
+
+	return s, ""
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
+
+// Returns the value of the given reference along with the names of any
+// undefined variables it refers to.
+func (e *expander) substitute(ref reference) (string, []string, error) {
+	switch ref.modifier {
+	case "", ":-", "-", ":+", "+":
+	default:
+		if e.shell {
+			return ref.text, nil, nil
+		}
+
+		return "", nil, fmt.Errorf("unsupported variable substitution %q", ref.text)
+	}
+
+	if ref.name == "" {
+		if e.shell {
+			return ref.text, nil, nil
+		}
+
+		return "", nil, fmt.Errorf("invalid variable substitution %q", ref.text)
+	}
+
+	val, defined := e.lookup(ref.name)
+	if defined && val == nil {
+		return ref.text, nil, nil
+	}
+
+	if !defined && (ref.modifier == "" || e.shell) {
+		// Within a shell, the variable may be defined by the shell itself,
+		// e.g., as a loop variable, so it is left for the shell to substitute.
+		return ref.text, []string{ref.name}, nil
+	}
+
+	switch ref.modifier {
+	case "":
+		return *val, nil, nil
+	case ":-", "-":
+		if defined && (ref.modifier == "-" || *val != "") {
+			return *val, nil, nil
+		}
+
+		return e.expandWord(ref.word)
+	default:
+		if defined && (ref.modifier == "+" || *val != "") {
+			return e.expandWord(ref.word)
+		}
+
+		return "", nil, nil
+	}
+}
+
+// Expands the word following a modifier, which may itself refer to
+// variables.
+func (e *expander) expandWord(word string) (string, []string, error) {
+	inner := *e
+	inner.quotes = false
+
+	return inner.expand(word)
+}
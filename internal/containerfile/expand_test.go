@@ -0,0 +1,87 @@
+package containerfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpand(t *testing.T) {
+	vars := map[string]string{
+		"HOME":  "/root",
+		"EMPTY": "",
+		"NAME":  "app",
+	}
+
+	testCases := []struct {
+		in        string
+		expected  string
+		undefined []string
+	}{
+		{in: "$HOME/bin", expected: "/root/bin"},
+		{in: "${HOME}bin", expected: "/rootbin"},
+		{in: "${MISSING:-/opt}", expected: "/opt"},
+		{in: "${EMPTY:-default}", expected: "default"},
+		{in: "${EMPTY-default}", expected: ""},
+		{in: "${NAME:+--name=$NAME}", expected: "--name=app"},
+		{in: "${EMPTY:+set}", expected: ""},
+		{in: "${EMPTY+set}", expected: "set"},
+		{in: "${MISSING:-${NAME}}", expected: "app"},
+		{in: `'$HOME' "$HOME"`, expected: `'$HOME' "/root"`},
+		{in: `\$HOME`, expected: `\$HOME`},
+		{in: "$1 $(pwd) $", expected: "$1 $(pwd) $"},
+		{in: "$MISSING/${OTHER}", expected: "$MISSING/${OTHER}", undefined: []string{"MISSING", "OTHER"}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.in, func(t *testing.T) {
+			out, undefined, err := Expand(testCase.in, vars)
+			require.NoError(t, err)
+
+			assert.Equal(t, testCase.expected, out)
+
+			if testCase.undefined == nil {
+				testCase.undefined = []string{}
+			}
+
+			assert.Equal(t, testCase.undefined, undefined)
+		})
+	}
+}
+
+func TestExpandErrors(t *testing.T) {
+	testCases := []struct {
+		in       string
+		expected string
+	}{
+		{in: "${HOME%/*}", expected: `unsupported variable substitution "${HOME%/*}"`},
+		{in: "${}", expected: `invalid variable substitution "${}"`},
+		{in: `"$HOME`, expected: `unterminated quote in "\"$HOME"`},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.in, func(t *testing.T) {
+			_, _, err := Expand(testCase.in, map[string]string{"HOME": "/root"})
+			assert.EqualError(t, err, testCase.expected)
+		})
+	}
+}
+
+func TestExpandShell(t *testing.T) {
+	e := &expander{
+		lookup: func(name string) (*string, bool) {
+			val := "file.tar.gz"
+			return &val, name == "FILE"
+		},
+		escape: '\\',
+		quotes: true,
+		shell:  true,
+	}
+
+	// Substitutions which only the shell supports are left to it.
+	out, undefined, err := e.expand(`tar xf $FILE && echo "${FILE%%.*}" $f`)
+	require.NoError(t, err)
+	assert.Equal(t, `tar xf file.tar.gz && echo "${FILE%%.*}" $f`, out)
+	assert.Equal(t, []string{"f"}, undefined)
+}
@@ -0,0 +1,313 @@
+package containerfile
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+)
+
+// The indentation used for continuation lines.
+const formatIndent = "    "
+
+// Options for pretty-printing a Containerfile.
+type FormatOpts struct {
+	// The column past which lines are wrapped, where possible. Defaults to 80.
+	Width int
+	// Precede each FROM with a comment describing the stage.
+	Comments bool
+}
+
+// Renders the Containerfile in a canonical style. Chained RUN commands are
+// put on their own lines, RUN flags are aligned beneath one another, package
+// lists are sorted and, when too long, wrapped one package per line, and
+// stages are separated by a single blank line.
+func (c *Containerfile) Format(opts FormatOpts) string {
+	f := &formatter{width: opts.Width, continuation: " \\\n"}
+	if f.width <= 0 {
+		f.width = 80
+	}
+
+	lines := []string{}
+
+	for _, directive := range c.Directives {
+		lines = append(lines, directive.Line())
+
+		if directive.Name == "escape" {
+			f.continuation = " " + directive.Value + "\n"
+		}
+	}
+
+	for _, step := range c.Header {
+		lines = append(lines, f.step(step))
+	}
+
+	for i, stage := range c.Stages {
+		if len(lines) != 0 {
+			lines = append(lines, "")
+		}
+
+		comment := stage.Comment
+		if comment == "" && opts.Comments {
+			comment = c.stageComment(i)
+		}
+
+		if comment != "" {
+			lines = append(lines, commentLines(comment))
+		}
+
+		from := &FromStep{Image: stage.Image, As: stage.Name, Platform: stage.Platform}
+		lines = append(lines, from.Line())
+
+		for _, step := range stage.Steps {
+			if line := f.step(step); line != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func (c *Containerfile) stageComment(i int) string {
+	comment := fmt.Sprintf("Stage %d of %d", i+1, len(c.Stages))
+
+	if name := c.Stages[i].Name; name != "" {
+		comment = fmt.Sprintf("%s: %s", comment, name)
+	}
+
+	if i == len(c.Stages)-1 && c.Tag != "" {
+		comment = fmt.Sprintf("%s, tagged %s", comment, c.Tag)
+	}
+
+	return comment
+}
+
+type formatter struct {
+	width int
+	// Ends each wrapped line with the escape character and a newline.
+	continuation string
+}
+
+func (f *formatter) step(step ContainerfileStep) string {
+	if flags, commands, ok := runStepCommands(step); ok {
+		return f.run(flags, commands)
+	}
+
+	switch s := step.(type) {
+	case *CommentedStep:
+		return commentLines(s.Comment) + "\n" + f.step(s.Step)
+	case *LabelStep:
+		// Align each label beneath the first.
+		if len(s.Line()) <= f.width {
+			return s.Line()
+		}
+
+		return "LABEL " + strings.Join(s.pairs(), f.continuation+strings.Repeat(" ", len("LABEL ")))
+	}
+
+	return step.Line()
+}
+
+// Breaks any of the RUN step types, other than heredocs, into its flags and
+// commands. Returns false if the step is not one of them.
+func runStepCommands(step ContainerfileStep) ([]string, []formattedCommand, bool) {
+	commands := []formattedCommand{}
+
+	switch s := step.(type) {
+	case *RunStep:
+		if keepShellVerbatim(s.Command) {
+			return runFlags(s.Mounts, s.Flags), []formattedCommand{{prefix: s.Command}}, true
+		}
+
+		for _, cmd := range SplitAndAnd(s.Command) {
+			commands = append(commands, formatShellCommand(cmd))
+		}
+
+		return runFlags(s.Mounts, s.Flags), commands, true
+	case *MultiRunStep:
+		if joined := strings.Join(s.Commands, " && "); keepShellVerbatim(joined) {
+			return runFlags(s.Mounts, s.Flags), []formattedCommand{{prefix: joined}}, true
+		}
+
+		for _, cmd := range s.Commands {
+			commands = append(commands, formatShellCommand(cmd))
+		}
+
+		return runFlags(s.Mounts, s.Flags), commands, true
+	case *CommandRunStep:
+		return runFlags(s.Mounts, s.Flags), []formattedCommand{formatCommand(s.Command)}, true
+	case *MultiCommandRunStep:
+		for _, cmd := range s.Commands {
+			commands = append(commands, formatCommand(cmd))
+		}
+
+		return runFlags(s.Mounts, s.Flags), commands, true
+	}
+
+	return nil, nil, false
+}
+
+// Reports whether the given shell command contains an unquoted comment,
+// subshell, or operator other than &&, such as ||, ;, or |. Splitting such
+// commands on && could break them apart within a comment or subshell, or
+// obscure how the operators group, so they are kept as they are.
+func keepShellVerbatim(s string) bool {
+	var quote byte
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case c == '\\' && quote != '\'':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case strings.IndexByte("#();|", c) != -1:
+			return true
+		}
+	}
+
+	return false
+}
+
+func runFlags(mounts []*Mount, flags []string) []string {
+	out := []string{}
+	for _, mount := range mounts {
+		out = append(out, mount.flag())
+	}
+
+	return append(out, flags...)
+}
+
+// Lays out a RUN instruction. It is kept on one line if it has a single
+// command and fits. Otherwise, each flag and chained command goes on its own
+// line and long package lists are wrapped.
+func (f *formatter) run(flags []string, commands []formattedCommand) string {
+	inline := []string{}
+	for _, cmd := range commands {
+		inline = append(inline, cmd.inline())
+	}
+
+	single := strings.Join(append(append([]string{"RUN"}, flags...), strings.Join(inline, " && ")), " ")
+	if len(commands) == 1 && len(single) <= f.width {
+		return single
+	}
+
+	lines := []string{}
+	lead := "RUN "
+
+	for _, flag := range flags {
+		lines = append(lines, lead+flag)
+		lead = formatIndent
+	}
+
+	for i, cmd := range commands {
+		if i != 0 {
+			lead = formatIndent + "&& "
+		}
+
+		lines = append(lines, cmd.lines(lead, f.width)...)
+		lead = formatIndent
+	}
+
+	return strings.Join(lines, f.continuation)
+}
+
+// A command which may have its packages wrapped onto their own lines.
+type formattedCommand struct {
+	// The command, not including any packages.
+	prefix   string
+	packages []string
+}
+
+func (f formattedCommand) inline() string {
+	return strings.Join(append([]string{f.prefix}, f.packages...), " ")
+}
+
+func (f formattedCommand) lines(lead string, width int) []string {
+	// Leave room for a trailing line continuation.
+	if len(f.packages) == 0 || len(lead+f.inline()+" \\") <= width {
+		return []string{lead + f.inline()}
+	}
+
+	out := []string{lead + f.prefix}
+	for _, pkg := range f.packages {
+		out = append(out, formatIndent+formatIndent+pkg)
+	}
+
+	return out
+}
+
+// Splits install commands into their packages, which are sorted.
+func formatCommand(cmd Command) formattedCommand {
+	var withoutPackages Command
+	var packages []string
+
+	switch c := cmd.(type) {
+	case *command.DnfInstall:
+		withoutPackages, packages = &command.DnfInstall{Yes: c.Yes}, c.Packages
+	case *command.YumInstall:
+		withoutPackages, packages = &command.YumInstall{Yes: c.Yes}, c.Packages
+	case *command.MicrodnfInstall:
+		withoutPackages, packages = &command.MicrodnfInstall{Yes: c.Yes}, c.Packages
+	case *command.ZypperInstall:
+		withoutPackages, packages = &command.ZypperInstall{Yes: c.Yes}, c.Packages
+	case *command.AptGetInstall:
+		withoutPackages, packages = &command.AptGetInstall{Yes: c.Yes}, c.Packages
+	case *command.ApkAdd:
+		withoutPackages, packages = &command.ApkAdd{NoCache: c.NoCache}, c.Packages
+	case *command.RpmOstreeInstall:
+		withoutPackages, packages = &command.RpmOstreeInstall{}, c.Packages
+	default:
+		return formattedCommand{prefix: cmd.Command().Unredacted()}
+	}
+
+	quoted := []string{}
+	for _, pkg := range packages {
+		quoted = append(quoted, command.ShellQuote(pkg))
+	}
+
+	slices.Sort(quoted)
+
+	return formattedCommand{prefix: withoutPackages.Command().Unredacted(), packages: quoted}
+}
+
+// Recognizes install commands within a RUN line so that their packages can be
+// formatted. Commands with quotes or escapes are kept verbatim since
+// re-rendering them could change how the shell expands them, as are commands
+// with comments, which would otherwise be dropped.
+func formatShellCommand(s string) formattedCommand {
+	verbatim := formattedCommand{prefix: s}
+
+	if strings.ContainsAny(s, `'"\`) || keepShellVerbatim(s) {
+		return verbatim
+	}
+
+	cmd, err := command.ParseShellWords(s)
+	if err != nil {
+		return verbatim
+	}
+
+	argv, env, _ := cmd.ArgvWithSecretRefs(nil)
+	if len(env) != 0 {
+		return verbatim
+	}
+
+	recognized, ok := command.Recognize(argv)
+	if !ok {
+		return verbatim
+	}
+
+	formatted := formatCommand(recognized)
+	if formatted.packages == nil {
+		return verbatim
+	}
+
+	return formatted
+}
@@ -0,0 +1,153 @@
+package containerfile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	cf := &Containerfile{
+		Tag: "quay.io/zack/app:latest",
+		Stages: []*Stage{
+			{
+				Name:  "builder",
+				Image: "quay.io/centos/stream:9",
+				Steps: []ContainerfileStep{
+					&MultiCommandRunStep{
+						Mounts: []*Mount{
+							CacheMount{Target: "/var/cache/dnf"}.Mount(),
+							SecretMount{ID: "creds"}.Mount(),
+						},
+						Commands: []Command{
+							&command.DnfInstall{Yes: true, Packages: []string{"golang", "git", "make", "gcc", "rpm-build", "python3-devel", "redhat-rpm-config"}},
+							command.CommandLiteral{"make", "all"},
+						},
+					},
+					&RunStep{Command: "useradd zack"},
+				},
+			},
+			{
+				Image: "quay.io/centos/stream:9",
+				Steps: []ContainerfileStep{
+					&CommandRunStep{Command: &command.DnfInstall{Yes: true, Packages: []string{"jq", "git"}}},
+					&RunStep{Command: "dnf install -y vim-enhanced tmux zsh && echo 'done && dusted'"},
+					&LabelStep{Labels: []command.Label{
+						{Name: "org.opencontainers.image.title", Value: "My Image"},
+						{Name: "org.opencontainers.image.source", Value: "https://github.com/org/repo"},
+					}},
+					&CopyStep{From: "builder", Src: "/app", Dest: "/usr/bin/app"},
+				},
+			},
+		},
+	}
+
+	expected := `# Stage 1 of 2: builder
+FROM quay.io/centos/stream:9 AS builder
+RUN --mount=type=cache,target=/var/cache/dnf \
+    --mount=type=secret,id=creds \
+    dnf install -y \
+        gcc \
+        git \
+        golang \
+        make \
+        python3-devel \
+        redhat-rpm-config \
+        rpm-build \
+    && make all
+RUN useradd zack
+
+# Stage 2 of 2, tagged quay.io/zack/app:latest
+FROM quay.io/centos/stream:9
+RUN dnf install -y git jq
+RUN dnf install -y tmux vim-enhanced zsh \
+    && echo 'done && dusted'
+LABEL org.opencontainers.image.title="My Image" \
+      org.opencontainers.image.source=https://github.com/org/repo
+COPY --from=builder /app /usr/bin/app
+`
+
+	out := cf.Format(FormatOpts{Comments: true})
+	assert.Equal(t, expected, out)
+
+	// Formatting is idempotent, including the generated comments.
+	parsed, err := Parse(strings.NewReader(out))
+	require.NoError(t, err)
+	parsed.Tag = cf.Tag
+	assert.Equal(t, expected, parsed.Format(FormatOpts{Comments: true}))
+}
+
+func TestFormatParsed(t *testing.T) {
+	in := `ARG BASE=fedora:40
+# The build stage.
+from $BASE as builder
+run dnf  -y install \
+      golang git &&\
+    make
+
+# Ship it.
+FROM --platform=$BUILDPLATFORM fedora:40
+env FOO=bar
+RUN ["/bin/sh", "-c", "echo hi"]
+`
+
+	expected := `ARG BASE=fedora:40
+
+# The build stage.
+FROM $BASE AS builder
+RUN dnf install -y git golang \
+    && make
+
+# Ship it.
+FROM --platform=$BUILDPLATFORM fedora:40
+ENV FOO=bar
+RUN ["/bin/sh", "-c", "echo hi"]
+`
+
+	cf, err := Parse(strings.NewReader(in))
+	require.NoError(t, err)
+
+	assert.Equal(t, expected, cf.Format(FormatOpts{}))
+}
+
+func TestFormatKeepsShellVerbatim(t *testing.T) {
+	in := `FROM fedora:40
+RUN dnf install -y git # pin later
+RUN make install # see docs
+RUN true # dnf install -y zsh bash && echo hi
+RUN dnf install -y vim nano && echo '# not a comment'
+RUN dnf install -y vim nano
+RUN (cd /src && make) || true
+RUN dnf install -y vim nano && make; make install
+RUN dnf install -y vim nano && rpm -qa | sort
+RUN dnf install -y vim nano && echo 'a || b; c'
+`
+
+	expected := `FROM fedora:40
+RUN dnf install -y git # pin later
+RUN make install # see docs
+RUN true # dnf install -y zsh bash && echo hi
+RUN dnf install -y nano vim \
+    && echo '# not a comment'
+RUN dnf install -y nano vim
+RUN (cd /src && make) || true
+RUN dnf install -y vim nano && make; make install
+RUN dnf install -y vim nano && rpm -qa | sort
+RUN dnf install -y nano vim \
+    && echo 'a || b; c'
+`
+
+	cf, err := Parse(strings.NewReader(in))
+	require.NoError(t, err)
+
+	formatted := cf.Format(FormatOpts{})
+	assert.Equal(t, expected, formatted)
+
+	// Formatting is idempotent.
+	reparsed, err := Parse(strings.NewReader(formatted))
+	require.NoError(t, err)
+	assert.Equal(t, expected, reparsed.Format(FormatOpts{}))
+}
@@ -0,0 +1,109 @@
+package containerfile
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Runs a script given inline as a heredoc, e.g.:
+//
+//	RUN <<'EOF'
+//	set -euo pipefail
+//	dnf install -y git
+//	EOF
+type HeredocRunStep struct {
+	Flags  []string
+	Mounts []*Mount
+	// The command which reads the script on stdin, e.g., python3. When empty,
+	// the script is run by the default shell.
+	Command string
+	Script  string
+	// Defaults to EOF, or a variant thereof which does not appear in Script.
+	Delimiter string
+	// Expand build args and environment variables within the script. When
+	// false, the delimiter is quoted so that the script is used verbatim.
+	Expand bool
+}
+
+func (h *HeredocRunStep) Line() string {
+	open, body := heredoc(h.Delimiter, h.Script, h.Expand)
+
+	out := []string{"RUN"}
+
+	for _, mount := range h.Mounts {
+		out = append(out, mount.flag())
+	}
+
+	out = append(out, h.Flags...)
+
+	if h.Command != "" {
+		out = append(out, h.Command)
+	}
+
+	out = append(out, open)
+
+	return strings.Join(out, " ") + "\n" + body
+}
+
+// Writes a file with the given content into the image using a COPY heredoc,
+// e.g.:
+//
+//	COPY --chmod=0644 <<'EOF' /etc/app.conf
+//	key = value
+//	EOF
+//
+// This should be preferred over running echo with a redirection since it does
+// not require a shell within the image.
+type InlineFileStep struct {
+	Dest string
+	// The content of the file. A trailing newline is added if missing.
+	Content string
+	// Passed to COPY, e.g., --chmod=0644 or --chown=1000:1000.
+	Flags []string
+	// Defaults to EOF, or a variant thereof which does not appear in Content.
+	Delimiter string
+	// Expand build args and environment variables within the content. When
+	// false, the delimiter is quoted so that the content is used verbatim.
+	Expand bool
+}
+
+func (i *InlineFileStep) Line() string {
+	open, body := heredoc(i.Delimiter, i.Content, i.Expand)
+
+	out := append([]string{"COPY"}, i.Flags...)
+	out = append(out, open, i.Dest)
+
+	return strings.Join(out, " ") + "\n" + body
+}
+
+// Returns the opening marker of a heredoc along with its body, which ends
+// with the delimiter.
+func heredoc(delimiter, content string, expand bool) (string, string) {
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+
+	if delimiter == "" {
+		delimiter = heredocDelimiter(content)
+	}
+
+	open := "<<" + delimiter
+	if !expand {
+		open = fmt.Sprintf("<<'%s'", delimiter)
+	}
+
+	return open, content + delimiter
+}
+
+// Picks a delimiter which does not appear as a line within the content.
+func heredocDelimiter(content string) string {
+	lines := strings.Split(content, "\n")
+
+	delimiter := "EOF"
+	for i := 2; slices.Contains(lines, delimiter); i++ {
+		delimiter = fmt.Sprintf("EOF%d", i)
+	}
+
+	return delimiter
+}
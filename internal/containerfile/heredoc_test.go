@@ -0,0 +1,93 @@
+package containerfile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeredocSteps(t *testing.T) {
+	testCases := []struct {
+		name     string
+		step     ContainerfileStep
+		expected string
+	}{
+		{
+			name:     "Shell script",
+			step:     &HeredocRunStep{Script: "set -e\ndnf install -y git"},
+			expected: "RUN <<'EOF'\nset -e\ndnf install -y git\nEOF",
+		},
+		{
+			name: "With mounts, flags, and a command",
+			step: &HeredocRunStep{
+				Mounts:  []*Mount{SecretMount{ID: "creds"}.Mount()},
+				Flags:   []string{"--network=none"},
+				Command: "python3",
+				Script:  "print('hi')\n",
+				Expand:  true,
+			},
+			expected: "RUN --mount=type=secret,id=creds --network=none python3 <<EOF\nprint('hi')\nEOF",
+		},
+		{
+			name:     "Delimiter within the content",
+			step:     &InlineFileStep{Dest: "/etc/motd", Content: "EOF\nEOF2\n"},
+			expected: "COPY <<'EOF3' /etc/motd\nEOF\nEOF2\nEOF3",
+		},
+		{
+			name:     "Inline file with flags",
+			step:     &InlineFileStep{Dest: "/etc/app.conf", Content: "home = $HOME", Flags: []string{"--chmod=0644"}, Delimiter: "CONF"},
+			expected: "COPY --chmod=0644 <<'CONF' /etc/app.conf\nhome = $HOME\nCONF",
+		},
+		{
+			name:     "Empty inline file",
+			step:     &InlineFileStep{Dest: "/etc/empty"},
+			expected: "COPY <<'EOF' /etc/empty\nEOF",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, testCase.step.Line())
+
+			// Each step parses back into itself.
+			cf, err := Parse(strings.NewReader("FROM fedora\n" + testCase.step.Line() + "\n"))
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, cf.Stages[0].Steps[0].Line())
+		})
+	}
+}
+
+func TestComments(t *testing.T) {
+	cf := &Containerfile{
+		Directives: []Directive{{Name: "syntax", Value: "docker/dockerfile:1.4"}},
+		Stages: []*Stage{
+			{
+				Comment: "Builds the app.\n\nSee the README.",
+				Image:   "fedora:40",
+				Steps: []ContainerfileStep{
+					NewCommentStep("Nothing to see here."),
+					WithComment("Write the config.", &InlineFileStep{Dest: "/etc/app.conf", Content: "a = b\n"}),
+				},
+			},
+		},
+	}
+
+	expected := `# syntax=docker/dockerfile:1.4
+
+# Builds the app.
+#
+# See the README.
+FROM fedora:40
+# Nothing to see here.
+# Write the config.
+COPY <<'EOF' /etc/app.conf
+a = b
+EOF
+
+`
+
+	assert.Equal(t, expected, cf.String())
+	assert.Equal(t, strings.TrimSuffix(expected, "\n"), cf.Format(FormatOpts{Comments: true}))
+}
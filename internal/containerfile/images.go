@@ -0,0 +1,155 @@
+package containerfile
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Returns the unique images which the Containerfile pulls, in sorted order:
+// the base images of its stages and any images copied from with COPY --from.
+// References to other stages and scratch are omitted, as are images which
+// are already pinned by digest or which contain variables.
+func (c *Containerfile) ExternalImages() []string {
+	out := []string{}
+
+	collect := func(image string) string {
+		out = append(out, image)
+		return image
+	}
+
+	c.walkStages(func(stage *Stage, stages []string) {
+		if isExternalImage(stage.Image, stages) {
+			out = append(out, stage.Image)
+		}
+
+		for _, step := range stage.Steps {
+			replaceCopyFrom(step, stages, collect)
+		}
+	})
+
+	slices.Sort(out)
+
+	return slices.Compact(out)
+}
+
+// Returns a copy of the Containerfile with each external image, as returned
+// by ExternalImages, replaced by the reference it is keyed by, e.g., to pin
+// fedora:40 to fedora:40@sha256:.... Images which are not given are kept.
+// The given Containerfile is not modified.
+func (c *Containerfile) ReplaceImages(refs map[string]string) *Containerfile {
+	out := &Containerfile{
+		Directives: c.Directives,
+		Header:     c.Header,
+		Tag:        c.Tag,
+	}
+
+	for _, stage := range c.Stages {
+		out.Stages = append(out.Stages, &Stage{
+			Name:     stage.Name,
+			Image:    stage.Image,
+			Platform: stage.Platform,
+			Comment:  stage.Comment,
+			Steps:    slices.Clone(stage.Steps),
+		})
+	}
+
+	replace := func(image string) string {
+		if ref, ok := refs[image]; ok {
+			return ref
+		}
+
+		return image
+	}
+
+	out.walkStages(func(stage *Stage, stages []string) {
+		if isExternalImage(stage.Image, stages) {
+			stage.Image = replace(stage.Image)
+		}
+
+		for i, step := range stage.Steps {
+			stage.Steps[i] = replaceCopyFrom(step, stages, replace)
+		}
+	})
+
+	return out
+}
+
+// Calls visit with each stage along with the names by which the stages
+// before it may be referred to, i.e., their indexes and names.
+func (c *Containerfile) walkStages(visit func(*Stage, []string)) {
+	stages := []string{}
+
+	for i, stage := range c.Stages {
+		visit(stage, stages)
+
+		stages = append(stages, strconv.Itoa(i))
+		if stage.Name != "" {
+			stages = append(stages, stage.Name)
+		}
+	}
+}
+
+// Replaces the image given to COPY --from, if it is external. Steps are
+// copied rather than modified, so the result may be discarded when only the
+// images are of interest.
+func replaceCopyFrom(step ContainerfileStep, stages []string, replace func(string) string) ContainerfileStep {
+	switch s := step.(type) {
+	case *CommentedStep:
+		replaced := replaceCopyFrom(s.Step, stages, replace)
+		if replaced == s.Step {
+			return s
+		}
+
+		return WithComment(s.Comment, replaced)
+	case *CopyStep:
+		if !isExternalImage(s.From, stages) {
+			return s
+		}
+
+		out := *s
+		out.From = replace(s.From)
+		return &out
+	case *RawStep:
+		line := s.Line()
+		head, body, hasBody := strings.Cut(line, "\n")
+
+		instruction, rest := cutWord(head)
+		if !strings.EqualFold(instruction, "COPY") && !strings.EqualFold(instruction, "ADD") {
+			return s
+		}
+
+		flags, _ := cutFlags(rest)
+
+		for _, flag := range flags {
+			from, ok := strings.CutPrefix(flag, "--from=")
+			if !ok || !isExternalImage(from, stages) {
+				continue
+			}
+
+			head = strings.Replace(head, flag, "--from="+replace(from), 1)
+		}
+
+		if hasBody {
+			head += "\n" + body
+		}
+
+		if head == line {
+			return s
+		}
+
+		return NewRawStep(head)
+	}
+
+	return step
+}
+
+// Reports whether the given image is pulled rather than being one of the
+// given stages, scratch, pinned by digest, or dependent on a variable.
+func isExternalImage(image string, stages []string) bool {
+	return image != "" &&
+		image != "scratch" &&
+		!slices.Contains(stages, image) &&
+		!strings.Contains(image, "@") &&
+		!strings.Contains(image, "$")
+}
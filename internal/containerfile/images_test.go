@@ -0,0 +1,93 @@
+package containerfile
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExternalImages(t *testing.T) {
+	in := `ARG BASE=fedora
+FROM quay.io/centos/stream:9 AS builder
+COPY --from=docker.io/library/golang:1.23 /usr/local/go /usr/local/go
+# Copy the tools.
+COPY --from=quay.io/org/tools:latest --chown=0:0 /bin/tool /bin/tool
+FROM builder AS test
+COPY --from=0 /app /app
+FROM $BASE AS variable
+FROM registry.access.redhat.com/ubi9@sha256:0123 AS pinned
+FROM scratch
+COPY --from=builder /app /app
+FROM quay.io/centos/stream:9
+`
+
+	cf := mustParse(t, in)
+
+	assert.Equal(t, []string{
+		"docker.io/library/golang:1.23",
+		"quay.io/centos/stream:9",
+		"quay.io/org/tools:latest",
+	}, cf.ExternalImages())
+
+	pinned := cf.ReplaceImages(map[string]string{
+		"quay.io/centos/stream:9":       "quay.io/centos/stream:9@sha256:aaaa",
+		"docker.io/library/golang:1.23": "docker.io/library/golang:1.23@sha256:bbbb",
+		"quay.io/org/tools:latest":      "quay.io/org/tools:latest@sha256:cccc",
+	})
+
+	assert.Equal(t, "quay.io/centos/stream:9@sha256:aaaa", pinned.Stages[0].Image)
+	assert.Equal(t, &CopyStep{From: "docker.io/library/golang:1.23@sha256:bbbb", Src: "/usr/local/go", Dest: "/usr/local/go"}, pinned.Stages[0].Steps[0])
+	assert.Equal(t, WithComment("Copy the tools.", &CopyStep{
+		From:  "quay.io/org/tools:latest@sha256:cccc",
+		Src:   "/bin/tool",
+		Dest:  "/bin/tool",
+		Flags: []string{"--chown=0:0"},
+	}), pinned.Stages[0].Steps[1])
+	assert.Equal(t, "builder", pinned.Stages[1].Image)
+	assert.Equal(t, "quay.io/centos/stream:9@sha256:aaaa", pinned.Stages[5].Image)
+	assert.Empty(t, pinned.ExternalImages())
+
+	// The original is not modified.
+	assert.Equal(t, "quay.io/centos/stream:9", cf.Stages[0].Image)
+	assert.Equal(t, "docker.io/library/golang:1.23", cf.Stages[0].Steps[0].(*CopyStep).From)
+}
+
+func TestReplaceImagesRawSteps(t *testing.T) {
+	cf := &Containerfile{
+		Stages: []*Stage{
+			{
+				Image: "fedora",
+				Steps: []ContainerfileStep{
+					NewRawStep("COPY --from=quay.io/org/tools:latest /a /b /dest/"),
+					NewRawStep("COPY /a /b /dest/"),
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, []string{"fedora", "quay.io/org/tools:latest"}, cf.ExternalImages())
+
+	pinned := cf.ReplaceImages(map[string]string{"quay.io/org/tools:latest": "quay.io/org/tools:latest@sha256:cccc"})
+
+	assert.Equal(t, []ContainerfileStep{
+		NewRawStep("COPY --from=quay.io/org/tools:latest@sha256:cccc /a /b /dest/"),
+		NewRawStep("COPY /a /b /dest/"),
+	}, pinned.Stages[0].Steps)
+	assert.Equal(t, "fedora", pinned.Stages[0].Image)
+}
+
+func TestExternalImagesDoesNotModify(t *testing.T) {
+	cf := mustParse(t, "FROM fedora\nCOPY --from=golang:1.23 /go /go\n# Copy the tools.\nCOPY --from=tools /bin/tool /bin/tool\n")
+
+	stage := cf.Stages[0]
+	steps := slices.Clone(stage.Steps)
+
+	assert.Equal(t, []string{"fedora", "golang:1.23", "tools"}, cf.ExternalImages())
+
+	assert.Same(t, stage, cf.Stages[0])
+	assert.Equal(t, "fedora", stage.Image)
+	for i := range steps {
+		assert.Same(t, steps[i], stage.Steps[i])
+	}
+}
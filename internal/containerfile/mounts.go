@@ -0,0 +1,136 @@
+package containerfile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Represents a --mount=type=secret option. The secret must be supplied at
+// build time with a matching --secret id=... flag. Usage:
+//
+//	Mounts: []*Mount{SecretMount{ID: "creds", Required: true}.Mount()}
+type SecretMount struct {
+	// The secret ID. This is required for validation.
+	ID string
+	// Defaults to /run/secrets/<id> when empty.
+	Target string
+	// Exposes the secret as the given env var instead of (or in addition to) a file.
+	Env      string
+	Required bool
+	Mode     string
+	UID      string
+	GID      string
+}
+
+func (s SecretMount) Mount() *Mount {
+	return &Mount{
+		Type:   "secret",
+		ID:     s.ID,
+		Target: s.Target,
+		Opts: mountOpts([][2]string{
+			{"env", s.Env},
+			{"required", boolOpt(s.Required)},
+			{"mode", s.Mode},
+			{"uid", s.UID},
+			{"gid", s.GID},
+		}),
+	}
+}
+
+// Represents a --mount=type=ssh option. The SSH agent socket or keys must be
+// supplied at build time with a matching --ssh flag.
+type SSHMount struct {
+	// Defaults to "default" when empty.
+	ID       string
+	Target   string
+	Required bool
+	Mode     string
+	UID      string
+	GID      string
+}
+
+func (s SSHMount) Mount() *Mount {
+	return &Mount{
+		Type:   "ssh",
+		ID:     s.ID,
+		Target: s.Target,
+		Opts: mountOpts([][2]string{
+			{"required", boolOpt(s.Required)},
+			{"mode", s.Mode},
+			{"uid", s.UID},
+			{"gid", s.GID},
+		}),
+	}
+}
+
+// Represents a --mount=type=cache option such as the one used to persist a
+// package manager cache between builds.
+type CacheMount struct {
+	ID     string
+	Target string
+	From   string
+	Source string
+	// One of shared, private, or locked.
+	Sharing  string
+	ReadOnly bool
+	Mode     string
+	UID      string
+	GID      string
+}
+
+func (c CacheMount) Mount() *Mount {
+	return &Mount{
+		Type:   "cache",
+		ID:     c.ID,
+		From:   c.From,
+		Source: c.Source,
+		Target: c.Target,
+		Opts: mountOpts([][2]string{
+			{"sharing", c.Sharing},
+			{"ro", boolOpt(c.ReadOnly)},
+			{"mode", c.Mode},
+			{"uid", c.UID},
+			{"gid", c.GID},
+		}),
+	}
+}
+
+// Represents a --mount=type=tmpfs option.
+type TmpfsMount struct {
+	Target string
+	// The size limit of the tmpfs mount, e.g., 64m.
+	Size string
+}
+
+func (t TmpfsMount) Mount() *Mount {
+	return &Mount{
+		Type:   "tmpfs",
+		Target: t.Target,
+		Opts: mountOpts([][2]string{
+			{"size", t.Size},
+		}),
+	}
+}
+
+// Renders ordered key/value pairs into a comma-separated option string,
+// skipping any empty values.
+func mountOpts(pairs [][2]string) string {
+	out := []string{}
+
+	for _, pair := range pairs {
+		if pair[1] != "" {
+			out = append(out, fmt.Sprintf("%s=%s", pair[0], pair[1]))
+		}
+	}
+
+	return strings.Join(out, ",")
+}
+
+// Only renders a boolean option when it is set.
+func boolOpt(val bool) string {
+	if !val {
+		return ""
+	}
+
+	return "true"
+}
@@ -0,0 +1,115 @@
+package containerfile
+
+import (
+	"testing"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypedMounts(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mount    *Mount
+		expected string
+	}{
+		{
+			name:     "Secret",
+			mount:    SecretMount{ID: "creds", Target: "/run/secrets/creds", Required: true, Mode: "0400"}.Mount(),
+			expected: "--mount=type=secret,id=creds,target=/run/secrets/creds,required=true,mode=0400",
+		},
+		{
+			name:     "Secret as env var",
+			mount:    SecretMount{ID: "token", Env: "TOKEN"}.Mount(),
+			expected: "--mount=type=secret,id=token,env=TOKEN",
+		},
+		{
+			name:     "SSH",
+			mount:    SSHMount{}.Mount(),
+			expected: "--mount=type=ssh",
+		},
+		{
+			name:     "Cache",
+			mount:    CacheMount{ID: "dnf", Target: "/var/cache/dnf", Sharing: "locked"}.Mount(),
+			expected: "--mount=type=cache,id=dnf,target=/var/cache/dnf,sharing=locked",
+		},
+		{
+			name:     "Tmpfs",
+			mount:    TmpfsMount{Target: "/tmp", Size: "64m"}.Mount(),
+			expected: "--mount=type=tmpfs,target=/tmp,size=64m",
+		},
+		{
+			name:     "Untyped without opts",
+			mount:    &Mount{Type: "bind", Source: "src", Target: "/src"},
+			expected: "--mount=type=bind,source=src,target=/src",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, testCase.mount.flag())
+		})
+	}
+}
+
+func TestValidateBuildOpts(t *testing.T) {
+	cf := &Containerfile{
+		Stages: []*Stage{
+			{
+				Image: "registry.fedoraproject.org/fedora:latest",
+				Steps: []ContainerfileStep{
+					&CommandRunStep{
+						Mounts: []*Mount{
+							SecretMount{ID: "creds"}.Mount(),
+							SSHMount{}.Mount(),
+						},
+						Command: &command.DnfInstall{Yes: true, Packages: []string{"git"}},
+					},
+					&RunStep{
+						Mounts:  []*Mount{SecretMount{Target: "/etc/pki/ca.pem"}.Mount()},
+						Command: "update-ca-trust",
+					},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, []string{"ca.pem", "creds"}, cf.SecretIDs())
+	assert.Equal(t, []string{"default"}, cf.SSHIDs())
+
+	err := cf.ValidateBuildOpts(&command.BuildOpts{
+		Secrets: []command.BuildSecret{{ID: "creds", Src: "/path/to/creds"}},
+	})
+	assert.ErrorContains(t, err, "missing secret(s): ca.pem")
+	assert.ErrorContains(t, err, "missing ssh id(s): default")
+
+	assert.NoError(t, cf.ValidateBuildOpts(&command.BuildOpts{
+		Secrets: []command.BuildSecret{
+			{ID: "creds", Src: "/path/to/creds"},
+			{ID: "ca.pem", Src: "/path/to/ca.pem"},
+		},
+		SSH: []string{"default=/run/ssh-agent.sock"},
+	}))
+}
+
+func TestValidateBuildOptsSecretWithoutID(t *testing.T) {
+	cf := &Containerfile{
+		Stages: []*Stage{
+			{
+				Name:  "builder",
+				Image: "registry.fedoraproject.org/fedora:latest",
+				Steps: []ContainerfileStep{
+					&RunStep{
+						Mounts:  []*Mount{SecretMount{Env: "TOKEN"}.Mount()},
+						Command: "make",
+					},
+				},
+			},
+		},
+	}
+
+	assert.Empty(t, cf.SecretIDs())
+
+	err := cf.ValidateBuildOpts(&command.BuildOpts{})
+	assert.EqualError(t, err, "secret mount(s) without an id or target: builder: RUN --mount=type=secret,env=TOKEN make")
+}
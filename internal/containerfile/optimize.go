@@ -0,0 +1,345 @@
+package containerfile
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+)
+
+// Controls which transformations Optimize performs. Each one is opt-in.
+type OptimizeOpts struct {
+	// Merges adjacent RUN steps which have identical flags and mounts. Since
+	// merged commands share a single shell, steps are not merged into one
+	// which changes the shell's state, e.g., with cd, export, or set.
+	MergeRunSteps bool
+	// Appends the package manager cache cleanup to each RUN step which invokes
+	// the package manager.
+	CleanCache bool
+	// Mounts the package manager cache directories as type=cache mounts on
+	// each RUN step which invokes the package manager instead of cleaning them
+	// up. Takes precedence over CleanCache.
+	UseCacheMounts bool
+	// The package manager to use for every stage. If nil, it is detected from
+	// each stage's base image.
+	PackageManager command.PackageManager
+}
+
+// Returns a new Containerfile with the requested layer-hygiene
+// transformations applied. The given Containerfile is not modified.
+func Optimize(c *Containerfile, opts OptimizeOpts) *Containerfile {
+	out := &Containerfile{
+		Directives: c.Directives,
+		Header:     c.Header,
+		Tag:        c.Tag,
+		Stages:     []*Stage{},
+	}
+
+	// Stages which use a previous stage as their base inherit its package
+	// manager.
+	stagePMs := map[string]command.PackageManager{}
+
+	for _, stage := range c.Stages {
+		pm := opts.PackageManager
+		if pm == nil {
+			pm = stagePMs[stage.Image]
+		}
+
+		if pm == nil {
+			pm, _ = command.DetectPackageManager(stage.Image)
+		}
+
+		if stage.Name != "" {
+			stagePMs[stage.Name] = pm
+		}
+
+		out.Stages = append(out.Stages, &Stage{
+			Name:     stage.Name,
+			Image:    stage.Image,
+			Platform: stage.Platform,
+			Comment:  stage.Comment,
+			Steps:    optimizeSteps(stage.Steps, pm, opts),
+		})
+	}
+
+	return out
+}
+
+// Applies the requested transformations to the steps of a single stage.
+func optimizeSteps(steps []ContainerfileStep, pm command.PackageManager, opts OptimizeOpts) []ContainerfileStep {
+	out := []ContainerfileStep{}
+
+	var pending *runStepParts
+
+	flush := func() {
+		if pending == nil {
+			return
+		}
+
+		if pm != nil && pending.invokes(packageManagerBinary(pm)) {
+			if opts.UseCacheMounts {
+				pending.addCacheMounts(pm.CacheDirs())
+			} else if opts.CleanCache {
+				pending.addCommands(pm.CleanCache())
+			}
+		}
+
+		out = append(out, pending.step())
+		pending = nil
+	}
+
+	for _, step := range steps {
+		parts, ok := newRunStepParts(step)
+		if !ok {
+			flush()
+			out = append(out, step)
+			continue
+		}
+
+		if opts.MergeRunSteps && pending != nil && pending.compatible(parts) {
+			pending.merge(parts)
+			continue
+		}
+
+		flush()
+		pending = parts
+	}
+
+	flush()
+
+	return out
+}
+
+// Holds the constituent parts of any of the RUN step types so that they may
+// be merged and modified uniformly.
+type runStepParts struct {
+	original ContainerfileStep
+	// The comment attached to the step, if any.
+	comment  string
+	modified bool
+	flags    []string
+	mounts   []*Mount
+	// Only populated when every command is typed.
+	typed []Command
+	// The rendered form of each command.
+	rendered []string
+}
+
+// Breaks a RUN step into its parts. Returns false if the step is not a RUN
+// step.
+func newRunStepParts(step ContainerfileStep) (*runStepParts, bool) {
+	switch s := step.(type) {
+	case *CommentedStep:
+		parts, ok := newRunStepParts(s.Step)
+		if !ok {
+			return nil, false
+		}
+
+		parts.original = s
+		parts.comment = s.Comment
+		return parts, true
+	case *RunStep:
+		return &runStepParts{original: s, flags: s.Flags, mounts: s.Mounts, rendered: []string{s.Command}}, true
+	case *MultiRunStep:
+		return &runStepParts{original: s, flags: s.Flags, mounts: s.Mounts, rendered: s.Commands}, true
+	case *CommandRunStep:
+		return &runStepParts{
+			original: s,
+			flags:    s.Flags,
+			mounts:   s.Mounts,
+			typed:    []Command{s.Command},
+			rendered: []string{s.Command.Command().Unredacted()},
+		}, true
+	case *MultiCommandRunStep:
+		rendered := []string{}
+		for _, cmd := range s.Commands {
+			rendered = append(rendered, cmd.Command().Unredacted())
+		}
+
+		return &runStepParts{original: s, flags: s.Flags, mounts: s.Mounts, typed: s.Commands, rendered: rendered}, true
+	}
+
+	return nil, false
+}
+
+// Two RUN steps are compatible when their flags and mounts are identical. A
+// step with a comment is never merged into the one before it so that the
+// comment stays with it. Neither is a step merged into one which changes the
+// state of its shell since the merged commands would then run differently.
+func (r *runStepParts) compatible(other *runStepParts) bool {
+	return other.comment == "" &&
+		slices.Equal(r.flags, other.flags) &&
+		slices.Equal(renderMounts(r.mounts), renderMounts(other.mounts)) &&
+		!slices.ContainsFunc(r.rendered, changesShellState)
+}
+
+// Builtins which change the state of the shell which runs them, or which stop
+// it from running any later commands.
+var shellStateBuiltins = []string{
+	"cd", "pushd", "popd", "export", "unset", "set", "shopt", "umask",
+	"ulimit", "alias", "unalias", "source", ".", "eval", "exec", "exit",
+	"return", "trap", "declare", "typeset", "readonly", "local",
+}
+
+// Matches the operators which separate the commands of a shell line.
+var shellSeparators = regexp.MustCompile(`&&|\|\||[;&|()\n]`)
+
+// Reports whether the given shell line runs a builtin which changes the state
+// of the shell, or assigns a shell variable. Quotes are not considered, so
+// this errs on the side of reporting a change.
+func changesShellState(line string) bool {
+	for _, cmd := range shellSeparators.Split(line, -1) {
+		words := strings.Fields(cmd)
+
+		assigned := false
+		for len(words) != 0 && isShellAssignment(words[0]) {
+			assigned = true
+			words = words[1:]
+		}
+
+		if len(words) == 0 {
+			// A bare assignment, such as FOO=bar, sets a shell variable.
+			if assigned {
+				return true
+			}
+
+			continue
+		}
+
+		if slices.Contains(shellStateBuiltins, words[0]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isShellAssignment(word string) bool {
+	name, _, ok := strings.Cut(word, "=")
+	if !ok || name == "" || !isNameStart(name[0]) {
+		return false
+	}
+
+	for i := 1; i < len(name); i++ {
+		if !isNameChar(name[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *runStepParts) merge(other *runStepParts) {
+	if r.typed != nil && other.typed != nil {
+		r.typed = append(slices.Clone(r.typed), other.typed...)
+	} else {
+		r.typed = nil
+	}
+
+	r.rendered = append(slices.Clone(r.rendered), other.rendered...)
+	r.modified = true
+}
+
+func (r *runStepParts) addCommands(cmds []command.Commander) {
+	for _, cmd := range cmds {
+		rendered := cmd.Command().Unredacted()
+		if slices.Contains(r.rendered, rendered) {
+			continue
+		}
+
+		if r.typed != nil {
+			r.typed = append(slices.Clone(r.typed), cmd)
+		}
+
+		r.rendered = append(slices.Clone(r.rendered), rendered)
+		r.modified = true
+	}
+}
+
+func (r *runStepParts) addCacheMounts(dirs []string) {
+	for _, dir := range dirs {
+		if slices.ContainsFunc(r.mounts, func(m *Mount) bool { return m.Target == dir }) {
+			continue
+		}
+
+		r.mounts = append(slices.Clone(r.mounts), CacheMount{Target: dir, Sharing: "locked"}.Mount())
+		r.modified = true
+	}
+}
+
+// Determines whether any of the commands within this step invoke the given
+// binary, including those chained together with &&, ||, ;, or the like within
+// a string literal.
+func (r *runStepParts) invokes(binary string) bool {
+	for _, rendered := range r.rendered {
+		for _, segment := range shellSeparators.Split(rendered, -1) {
+			if firstCommandWord(segment) == binary {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Reassembles the parts into the simplest equivalent step. If nothing was
+// changed, the original step is returned as-is.
+func (r *runStepParts) step() ContainerfileStep {
+	if !r.modified {
+		return r.original
+	}
+
+	step := r.reassemble()
+	if r.comment != "" {
+		return WithComment(r.comment, step)
+	}
+
+	return step
+}
+
+func (r *runStepParts) reassemble() ContainerfileStep {
+	if r.typed != nil {
+		if len(r.typed) == 1 {
+			return &CommandRunStep{Flags: r.flags, Mounts: r.mounts, Command: r.typed[0]}
+		}
+
+		return &MultiCommandRunStep{Flags: r.flags, Mounts: r.mounts, Commands: r.typed}
+	}
+
+	if len(r.rendered) == 1 {
+		return &RunStep{Flags: r.flags, Mounts: r.mounts, Command: r.rendered[0]}
+	}
+
+	return &MultiRunStep{Flags: r.flags, Mounts: r.mounts, Commands: r.rendered}
+}
+
+// Renders each mount into its flag form for comparison.
+func renderMounts(mounts []*Mount) []string {
+	out := []string{}
+
+	for _, mount := range mounts {
+		out = append(out, mount.flag())
+	}
+
+	return out
+}
+
+// Returns the binary which the given package manager invokes to install
+// packages.
+func packageManagerBinary(pm command.PackageManager) string {
+	installs := pm.Install([]string{"pkg"})
+	return firstCommandWord(installs[len(installs)-1].Command().String())
+}
+
+// Returns the first word of a shell command, skipping any leading env var
+// assignments.
+func firstCommandWord(cmd string) string {
+	for _, field := range strings.Fields(cmd) {
+		if !strings.Contains(field, "=") {
+			return field
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,257 @@
+package containerfile
+
+import (
+	"testing"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptimize(t *testing.T) {
+	newContainerfile := func(image string) *Containerfile {
+		return &Containerfile{
+			Stages: []*Stage{
+				{
+					Name:  "builder",
+					Image: image,
+					Steps: []ContainerfileStep{
+						&CommandRunStep{
+							Command: &command.DnfInstall{Yes: true, Packages: []string{"git"}},
+						},
+						&RunStep{Command: "useradd zack"},
+						NewUserStep("zack"),
+						&RunStep{Command: "make all"},
+					},
+				},
+				{
+					Image: "builder",
+					Steps: []ContainerfileStep{
+						&MultiRunStep{Commands: []string{"dnf install -y make", "make install"}},
+					},
+				},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		image    string
+		opts     OptimizeOpts
+		expected string
+	}{
+		{
+			name:  "No-op",
+			image: "quay.io/centos/stream:9",
+			expected: `FROM quay.io/centos/stream:9 AS builder
+RUN dnf install -y git
+RUN useradd zack
+USER zack
+RUN make all
+
+FROM builder
+RUN dnf install -y make && make install
+
+`,
+		},
+		{
+			name:  "Merge and clean",
+			image: "quay.io/centos/stream:9",
+			opts:  OptimizeOpts{MergeRunSteps: true, CleanCache: true},
+			expected: `FROM quay.io/centos/stream:9 AS builder
+RUN dnf install -y git && useradd zack && dnf clean all
+USER zack
+RUN make all
+
+FROM builder
+RUN dnf install -y make && make install && dnf clean all
+
+`,
+		},
+		{
+			name:  "Cache mounts",
+			image: "ubuntu:latest",
+			opts:  OptimizeOpts{UseCacheMounts: true, PackageManager: command.Dnf{}},
+			expected: `FROM ubuntu:latest AS builder
+RUN --mount=type=cache,target=/var/cache/dnf,sharing=locked dnf install -y git
+RUN useradd zack
+USER zack
+RUN make all
+
+FROM builder
+RUN --mount=type=cache,target=/var/cache/dnf,sharing=locked dnf install -y make && make install
+
+`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			cf := newContainerfile(testCase.image)
+			original := cf.String()
+
+			optimized := Optimize(cf, testCase.opts)
+			assert.Equal(t, testCase.expected, optimized.String())
+			assert.Equal(t, original, cf.String())
+		})
+	}
+}
+
+func TestOptimizeMergeIncompatible(t *testing.T) {
+	cf := &Containerfile{
+		Stages: []*Stage{
+			{
+				Image: "ubuntu:latest",
+				Steps: []ContainerfileStep{
+					&MultiCommandRunStep{
+						Commands: command.AptGet{}.Install([]string{"git"}),
+					},
+					&RunStep{
+						Mounts:  []*Mount{SecretMount{ID: "creds"}.Mount()},
+						Command: "git clone https://example.com/repo.git",
+					},
+					&RunStep{Command: "make"},
+					&RunStep{Command: "make install"},
+				},
+			},
+		},
+	}
+
+	expected := `FROM ubuntu:latest
+RUN apt-get update && apt-get install -y git && apt-get clean && rm -f -r /var/lib/apt/lists/*
+RUN --mount=type=secret,id=creds git clone https://example.com/repo.git
+RUN make && make install
+
+`
+
+	assert.Equal(t, expected, Optimize(cf, OptimizeOpts{MergeRunSteps: true, CleanCache: true}).String())
+}
+
+func TestOptimizeKeepsComments(t *testing.T) {
+	cf := &Containerfile{
+		Stages: []*Stage{
+			{
+				Comment: "The only stage.",
+				Image:   "quay.io/centos/stream:9",
+				Steps: []ContainerfileStep{
+					WithComment("Install the tools.", &RunStep{Command: "dnf install -y git"}),
+					&RunStep{Command: "git --version"},
+					WithComment("Build it.", &RunStep{Command: "make"}),
+				},
+			},
+		},
+	}
+
+	expected := `# The only stage.
+FROM quay.io/centos/stream:9
+# Install the tools.
+RUN dnf install -y git && git --version && dnf clean all
+# Build it.
+RUN make
+
+`
+
+	assert.Equal(t, expected, Optimize(cf, OptimizeOpts{MergeRunSteps: true, CleanCache: true}).String())
+}
+
+func TestOptimizeMergeShellState(t *testing.T) {
+	cf := &Containerfile{
+		Stages: []*Stage{
+			{
+				Image: "quay.io/centos/stream:9",
+				Steps: []ContainerfileStep{
+					&RunStep{Command: "mkdir /src"},
+					&RunStep{Command: "cd /src && git clone https://example.com/repo.git"},
+					&RunStep{Command: "make"},
+					&RunStep{Command: "export GOFLAGS=-mod=vendor"},
+					&RunStep{Command: "go build"},
+					&RunStep{Command: "set -o pipefail; curl -s https://example.com | sh"},
+					&RunStep{Command: "VERSION=1"},
+					&RunStep{Command: "echo $VERSION"},
+					&RunStep{Command: "CGO_ENABLED=0 go build"},
+					&RunStep{Command: "go test"},
+				},
+			},
+		},
+	}
+
+	// Commands which previously started with a fresh shell are not merged into
+	// a step which changes the shell's state, while the step which changes it
+	// may itself be merged into the one before it.
+	expected := `FROM quay.io/centos/stream:9
+RUN mkdir /src && cd /src && git clone https://example.com/repo.git
+RUN make && export GOFLAGS=-mod=vendor
+RUN go build && set -o pipefail; curl -s https://example.com | sh
+RUN VERSION=1
+RUN echo $VERSION && CGO_ENABLED=0 go build && go test
+
+`
+
+	assert.Equal(t, expected, Optimize(cf, OptimizeOpts{MergeRunSteps: true}).String())
+}
+
+func TestChangesShellState(t *testing.T) {
+	testCases := []struct {
+		line     string
+		expected bool
+	}{
+		{line: "make install", expected: false},
+		{line: "CGO_ENABLED=0 go build", expected: false},
+		{line: "echo cd", expected: false},
+		{line: "cd /src", expected: true},
+		{line: "make || exit 1", expected: true},
+		{line: "true; . /etc/profile", expected: true},
+		{line: "umask 077", expected: true},
+		{line: "PATH=/opt/bin:$PATH", expected: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.line, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, changesShellState(testCase.line))
+		})
+	}
+}
+
+func TestOptimizeOtherSeparators(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     OptimizeOpts
+		expected string
+	}{
+		{
+			name: "Clean cache",
+			opts: OptimizeOpts{CleanCache: true, PackageManager: command.AptGet{}},
+			expected: `FROM ubuntu:latest
+RUN apt-get update; apt-get install -y curl && apt-get clean && rm -f -r /var/lib/apt/lists/*
+RUN true || apt-get install -y git && apt-get clean && rm -f -r /var/lib/apt/lists/*
+
+`,
+		},
+		{
+			name: "Cache mounts",
+			opts: OptimizeOpts{UseCacheMounts: true, PackageManager: command.AptGet{}},
+			expected: `FROM ubuntu:latest
+RUN --mount=type=cache,target=/var/cache/apt,sharing=locked --mount=type=cache,target=/var/lib/apt/lists,sharing=locked apt-get update; apt-get install -y curl
+RUN --mount=type=cache,target=/var/cache/apt,sharing=locked --mount=type=cache,target=/var/lib/apt/lists,sharing=locked true || apt-get install -y git
+
+`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			cf := &Containerfile{
+				Stages: []*Stage{
+					{
+						Image: "ubuntu:latest",
+						Steps: []ContainerfileStep{
+							&RunStep{Command: "apt-get update; apt-get install -y curl"},
+							&RunStep{Command: "true || apt-get install -y git"},
+						},
+					},
+				},
+			}
+
+			assert.Equal(t, testCase.expected, Optimize(cf, testCase.opts).String())
+		})
+	}
+}
@@ -0,0 +1,634 @@
+package containerfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+)
+
+// Returned when a Containerfile cannot be parsed.
+type ParseError struct {
+	// The line on which the offending instruction begins.
+	Line int
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// Parses a Containerfile into typed steps where possible. RUN, COPY, LABEL,
+// WORKDIR, and USER instructions become their typed steps, including RUN and
+// COPY heredocs, while all other instructions are kept verbatim as RawSteps.
+// Forms which the typed steps cannot represent, such as exec form RUN or COPY
+// with multiple sources, are also kept as RawSteps. Comments directly above a
+// FROM or other instruction are attached to it while those followed by a blank
+// line become CommentSteps. Line continuations are joined and blank lines are
+// otherwise dropped.
+func Parse(r io.Reader) (*Containerfile, error) {
+	cf := &Containerfile{}
+
+	raw, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	cf.Directives, raw = parseDirectives(raw)
+
+	escape := byte('\\')
+	for _, directive := range cf.Directives {
+		if directive.Name == "escape" {
+			if len(directive.Value) != 1 {
+				return nil, &ParseError{Line: 1, Msg: fmt.Sprintf("invalid escape directive %q", directive.Value)}
+			}
+
+			escape = directive.Value[0]
+		}
+	}
+
+	lines, err := logicalLines(raw, len(cf.Directives), escape)
+	if err != nil {
+		return nil, err
+	}
+
+	var stage *Stage
+	comments := []string{}
+
+	add := func(step ContainerfileStep) {
+		if stage == nil {
+			cf.Header = append(cf.Header, step)
+		} else {
+			stage.Steps = append(stage.Steps, step)
+		}
+	}
+
+	flushComments := func() {
+		if len(comments) != 0 {
+			add(NewCommentStep(strings.Join(comments, "\n")))
+			comments = []string{}
+		}
+	}
+
+	for _, line := range lines {
+		switch {
+		case line.text == "":
+			flushComments()
+			continue
+		case strings.HasPrefix(line.text, "#"):
+			comment := strings.TrimPrefix(line.text, "#")
+			comments = append(comments, strings.TrimPrefix(comment, " "))
+			continue
+		}
+
+		keyword, rest := cutWord(line.text)
+		keyword = strings.ToUpper(keyword)
+
+		if keyword == "FROM" {
+			s, err := parseFrom(rest)
+			if err != nil {
+				return nil, &ParseError{Line: line.num, Msg: err.Error()}
+			}
+
+			stage = s
+			stage.Comment = strings.Join(comments, "\n")
+			comments = []string{}
+			cf.Stages = append(cf.Stages, stage)
+			continue
+		}
+
+		if stage == nil && keyword != "ARG" {
+			return nil, &ParseError{Line: line.num, Msg: fmt.Sprintf("%s before the first FROM", keyword)}
+		}
+
+		var step ContainerfileStep
+
+		switch keyword {
+		case "RUN":
+			step = parseRun(rest, line.heredocs)
+		case "COPY":
+			step = parseCopy(rest, line.heredocs)
+		case "LABEL":
+			if escape == '\\' {
+				step = parseLabel(rest)
+			}
+		case "WORKDIR":
+			step = NewWorkDirStep(rest)
+		case "USER":
+			step = NewUserStep(rest)
+		}
+
+		if step == nil {
+			step = NewRawStep(line.raw(keyword + " " + rest))
+		}
+
+		if len(comments) != 0 {
+			step = WithComment(strings.Join(comments, "\n"), step)
+			comments = []string{}
+		}
+
+		add(step)
+	}
+
+	flushComments()
+
+	if len(cf.Stages) == 0 {
+		return nil, &ParseError{Line: 1, Msg: "no FROM instruction found"}
+	}
+
+	return cf, nil
+}
+
+// Parses the Containerfile at the given path.
+func ParseFile(path string) (*Containerfile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	cf, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+
+	return cf, nil
+}
+
+func readLines(r io.Reader) ([]string, error) {
+	lines := []string{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	return lines, scanner.Err()
+}
+
+var directivePattern = regexp.MustCompile(`^#\s*([a-zA-Z][a-zA-Z0-9]*)\s*=\s*(.+?)\s*$`)
+
+// Parses the directives at the top of the file, returning the remaining
+// lines. Directives are only recognized before any other line, including
+// blank lines and comments.
+func parseDirectives(lines []string) ([]Directive, []string) {
+	directives := []Directive{}
+
+	for len(lines) != 0 {
+		m := directivePattern.FindStringSubmatch(lines[0])
+		if m == nil {
+			break
+		}
+
+		directives = append(directives, Directive{Name: strings.ToLower(m[1]), Value: m[2]})
+		lines = lines[1:]
+	}
+
+	if len(directives) == 0 {
+		directives = nil
+	}
+
+	return directives, lines
+}
+
+// An instruction, comment, or blank line along with the line it begins on and
+// any heredocs which follow it.
+type logicalLine struct {
+	num      int
+	text     string
+	heredocs []heredocBody
+}
+
+// Renders the given instruction followed by its heredocs, if any.
+func (l logicalLine) raw(instruction string) string {
+	out := strings.TrimSpace(instruction)
+
+	for _, h := range l.heredocs {
+		out += "\n" + h.body + h.delimiter
+	}
+
+	return out
+}
+
+// The body of a heredoc, e.g., <<EOF, along with how it was opened.
+type heredocBody struct {
+	// The marker which opened the heredoc, e.g., <<'EOF'.
+	marker    string
+	delimiter string
+	quoted    bool
+	// Leading tabs are stripped from <<- heredocs.
+	stripTabs bool
+	body      string
+}
+
+var heredocPattern = regexp.MustCompile(`<<(-?)(["']?)([A-Za-z_][A-Za-z0-9_]*)(["']?)`)
+
+// Splits the given lines into instructions, comments, and blank lines,
+// joining line continuations and reading the bodies of any RUN, COPY, or ADD
+// heredocs. Comments and blank lines within a continued instruction are
+// skipped, as they are by buildah.
+func logicalLines(lines []string, offset int, escape byte) ([]logicalLine, error) {
+	out := []logicalLine{}
+	current := []string{}
+	start := 0
+
+	for i := 0; i < len(lines); i++ {
+		num := offset + i + 1
+		line := strings.TrimSpace(lines[i])
+
+		if len(current) == 0 {
+			if line == "" || strings.HasPrefix(line, "#") {
+				out = append(out, logicalLine{num: num, text: line})
+				continue
+			}
+
+			start = num
+		} else if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasSuffix(line, string(escape)) {
+			current = append(current, strings.TrimSpace(strings.TrimSuffix(line, string(escape))))
+			continue
+		}
+
+		current = append(current, line)
+		instruction := logicalLine{num: start, text: strings.Join(current, " ")}
+		current = []string{}
+
+		keyword, _ := cutWord(instruction.text)
+		if !slices.Contains([]string{"RUN", "COPY", "ADD"}, strings.ToUpper(keyword)) {
+			out = append(out, instruction)
+			continue
+		}
+
+		for _, m := range heredocPattern.FindAllStringSubmatch(instruction.text, -1) {
+			if m[2] != m[4] {
+				continue
+			}
+
+			h := heredocBody{marker: m[0], delimiter: m[3], quoted: m[2] != "", stripTabs: m[1] == "-"}
+			body := &strings.Builder{}
+
+			for {
+				i++
+				if i == len(lines) {
+					return nil, &ParseError{Line: start, Msg: fmt.Sprintf("unterminated heredoc %s", h.marker)}
+				}
+
+				bodyLine := lines[i]
+				if h.stripTabs {
+					bodyLine = strings.TrimLeft(bodyLine, "\t")
+				}
+
+				if bodyLine == h.delimiter {
+					break
+				}
+
+				fmt.Fprintln(body, bodyLine)
+			}
+
+			h.body = body.String()
+			instruction.heredocs = append(instruction.heredocs, h)
+		}
+
+		out = append(out, instruction)
+	}
+
+	if len(current) != 0 {
+		return nil, &ParseError{Line: start, Msg: "unterminated line continuation"}
+	}
+
+	return out, nil
+}
+
+// Splits off the first whitespace-separated word.
+func cutWord(s string) (string, string) {
+	s = strings.TrimSpace(s)
+
+	i := strings.IndexAny(s, " \t")
+	if i == -1 {
+		return s, ""
+	}
+
+	return s[:i], strings.TrimSpace(s[i:])
+}
+
+// Splits off any leading --flags.
+func cutFlags(s string) ([]string, string) {
+	flags := []string{}
+
+	for strings.HasPrefix(s, "--") {
+		var flag string
+		flag, s = cutWord(s)
+		flags = append(flags, flag)
+	}
+
+	return flags, s
+}
+
+func parseFrom(rest string) (*Stage, error) {
+	flags, rest := cutFlags(rest)
+
+	stage := &Stage{}
+
+	for _, flag := range flags {
+		platform, ok := strings.CutPrefix(flag, "--platform=")
+		if !ok {
+			return nil, fmt.Errorf("unsupported FROM flag %q", flag)
+		}
+
+		stage.Platform = platform
+	}
+
+	words := strings.Fields(rest)
+
+	switch {
+	case len(words) == 1:
+	case len(words) == 3 && strings.EqualFold(words[1], "AS"):
+		stage.Name = words[2]
+	default:
+		return nil, fmt.Errorf("expected FROM IMAGE [AS NAME], got %q", rest)
+	}
+
+	stage.Image = words[0]
+
+	return stage, nil
+}
+
+func parseRun(rest string, heredocs []heredocBody) ContainerfileStep {
+	flags, cmd := cutFlags(rest)
+
+	if len(heredocs) != 0 {
+		return parseHeredocRun(flags, cmd, heredocs)
+	}
+
+	// Exec form and here-strings cannot be represented.
+	if cmd == "" || strings.HasPrefix(cmd, "[") || strings.Contains(cmd, "<<") {
+		return nil
+	}
+
+	mounts, otherFlags := parseRunFlags(flags)
+
+	commands := SplitAndAnd(cmd)
+	if len(commands) == 1 {
+		return &RunStep{Flags: otherFlags, Mounts: mounts, Command: cmd}
+	}
+
+	return &MultiRunStep{Flags: otherFlags, Mounts: mounts, Commands: commands}
+}
+
+// Parses RUN [FLAGS] [COMMAND] <<EOF where the heredoc is the script.
+func parseHeredocRun(flags []string, cmd string, heredocs []heredocBody) ContainerfileStep {
+	h := heredocs[0]
+
+	command, ok := strings.CutSuffix(cmd, h.marker)
+	if len(heredocs) != 1 || h.stripTabs || !ok || strings.Contains(command, "<<") {
+		return nil
+	}
+
+	mounts, otherFlags := parseRunFlags(flags)
+
+	return &HeredocRunStep{
+		Flags:     otherFlags,
+		Mounts:    mounts,
+		Command:   strings.TrimSpace(command),
+		Script:    h.body,
+		Delimiter: h.delimiter,
+		Expand:    !h.quoted,
+	}
+}
+
+// Splits RUN flags into mounts and everything else.
+func parseRunFlags(flags []string) ([]*Mount, []string) {
+	mounts := []*Mount{}
+	otherFlags := []string{}
+
+	for _, flag := range flags {
+		if opts, ok := strings.CutPrefix(flag, "--mount="); ok {
+			mounts = append(mounts, parseMount(opts))
+		} else {
+			otherFlags = append(otherFlags, flag)
+		}
+	}
+
+	if len(mounts) == 0 {
+		mounts = nil
+	}
+
+	if len(otherFlags) == 0 {
+		otherFlags = nil
+	}
+
+	return mounts, otherFlags
+}
+
+// Parses the comma-separated options of a --mount flag.
+func parseMount(opts string) *Mount {
+	m := &Mount{}
+	extra := []string{}
+
+	for _, opt := range strings.Split(opts, ",") {
+		key, val, _ := strings.Cut(opt, "=")
+
+		switch key {
+		case "type":
+			m.Type = val
+		case "id":
+			m.ID = val
+		case "from":
+			m.From = val
+		case "source", "src":
+			m.Source = val
+		case "target", "dst", "destination":
+			m.Target = val
+		case "bind-propagation":
+			m.BindPropagation = val
+		default:
+			extra = append(extra, opt)
+		}
+	}
+
+	m.Opts = strings.Join(extra, ",")
+
+	return m
+}
+
+// Splits a shell command line on each && which is not within quotes or
+// escaped, e.g., to inspect each command of a RUN instruction separately.
+func SplitAndAnd(s string) []string {
+	out := []string{}
+	start := 0
+	var quote byte
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case c == '\\' && quote != '\'':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '&' && i+1 < len(s) && s[i+1] == '&':
+			out = append(out, strings.TrimSpace(s[start:i]))
+			start = i + 2
+			i++
+		}
+	}
+
+	return append(out, strings.TrimSpace(s[start:]))
+}
+
+func parseCopy(rest string, heredocs []heredocBody) ContainerfileStep {
+	flags, rest := cutFlags(rest)
+
+	if strings.HasPrefix(rest, "[") {
+		return nil
+	}
+
+	words := strings.Fields(rest)
+	if len(words) != 2 {
+		return nil
+	}
+
+	if len(heredocs) != 0 {
+		h := heredocs[0]
+
+		if len(heredocs) != 1 || h.stripTabs || words[0] != h.marker || slices.ContainsFunc(flags, isFromFlag) {
+			return nil
+		}
+
+		step := &InlineFileStep{Dest: words[1], Content: h.body, Delimiter: h.delimiter, Expand: !h.quoted}
+		if len(flags) != 0 {
+			step.Flags = flags
+		}
+
+		return step
+	}
+
+	if strings.Contains(rest, "<<") {
+		return nil
+	}
+
+	c := &CopyStep{Src: words[0], Dest: words[1]}
+
+	for _, flag := range flags {
+		if isFromFlag(flag) {
+			c.From = strings.TrimPrefix(flag, "--from=")
+		} else {
+			c.Flags = append(c.Flags, flag)
+		}
+	}
+
+	return c
+}
+
+func isFromFlag(flag string) bool {
+	return strings.HasPrefix(flag, "--from=")
+}
+
+// Parses KEY=VALUE pairs. Variable references are kept verbatim by returning
+// nil, as is the legacy LABEL KEY VALUE form.
+func parseLabel(rest string) ContainerfileStep {
+	if strings.Contains(rest, "$") {
+		return nil
+	}
+
+	words, ok := splitLabelWords(rest)
+	if !ok || len(words) == 0 {
+		return nil
+	}
+
+	step := &LabelStep{}
+
+	for _, word := range words {
+		if word.assignAt == -1 {
+			return nil
+		}
+
+		step.Labels = append(step.Labels, command.Label{
+			Name:  word.text[:word.assignAt],
+			Value: word.text[word.assignAt+1:],
+		})
+	}
+
+	return step
+}
+
+// A LABEL word and the position of its unquoted equal sign, if any.
+type labelWord struct {
+	text     string
+	assignAt int
+}
+
+// Splits on unquoted whitespace, removing quotes and escapes.
+func splitLabelWords(s string) ([]labelWord, bool) {
+	out := []labelWord{}
+	current := &strings.Builder{}
+	inWord := false
+	assignAt := -1
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case c == ' ' || c == '\t':
+			if inWord {
+				out = append(out, labelWord{text: current.String(), assignAt: assignAt})
+			}
+
+			current.Reset()
+			inWord = false
+			assignAt = -1
+		case c == '\\':
+			if i+1 == len(s) {
+				return nil, false
+			}
+
+			i++
+			inWord = true
+			current.WriteByte(s[i])
+		case c == '"' || c == '\'':
+			end := i + 1
+			for ; end < len(s) && s[end] != c; end++ {
+				if c == '"' && s[end] == '\\' && end+1 < len(s) {
+					end++
+				}
+
+				current.WriteByte(s[end])
+			}
+
+			if end == len(s) {
+				return nil, false
+			}
+
+			inWord = true
+			i = end
+		default:
+			if c == '=' && assignAt == -1 {
+				assignAt = current.Len()
+			}
+
+			inWord = true
+			current.WriteByte(c)
+		}
+	}
+
+	if inWord {
+		out = append(out, labelWord{text: current.String(), assignAt: assignAt})
+	}
+
+	return out, true
+}
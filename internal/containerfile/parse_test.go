@@ -0,0 +1,166 @@
+package containerfile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	in := `# syntax=docker/dockerfile:1
+ARG VERSION=1.0
+
+# A free-standing comment.
+
+# The build stage.
+FROM quay.io/centos/stream:9 AS builder
+# Install git.
+#
+# Caches are kept between builds.
+RUN --mount=type=cache,target=/var/cache/dnf,sharing=locked --network=none \
+    dnf install -y git
+RUN make && make install
+COPY --from=builder --chown=1000:1000 /app /usr/bin/app
+COPY a b c /dest/
+LABEL version=1.0 "description"="a \"quoted\" value" ref=$VERSION
+LABEL title="My Image" licenses='Apache-2.0'
+WORKDIR /src
+USER zack
+CMD ["/usr/bin/app"]
+`
+
+	cf, err := Parse(strings.NewReader(in))
+	require.NoError(t, err)
+
+	assert.Equal(t, []Directive{{Name: "syntax", Value: "docker/dockerfile:1"}}, cf.Directives)
+
+	assert.Equal(t, []ContainerfileStep{
+		NewRawStep("ARG VERSION=1.0"),
+		NewCommentStep("A free-standing comment."),
+	}, cf.Header)
+
+	require.Len(t, cf.Stages, 1)
+	assert.Equal(t, "builder", cf.Stages[0].Name)
+	assert.Equal(t, "quay.io/centos/stream:9", cf.Stages[0].Image)
+	assert.Equal(t, "The build stage.", cf.Stages[0].Comment)
+
+	assert.Equal(t, []ContainerfileStep{
+		WithComment("Install git.\n\nCaches are kept between builds.", &RunStep{
+			Flags:   []string{"--network=none"},
+			Mounts:  []*Mount{{Type: "cache", Target: "/var/cache/dnf", Opts: "sharing=locked"}},
+			Command: "dnf install -y git",
+		}),
+		&MultiRunStep{Commands: []string{"make", "make install"}},
+		&CopyStep{From: "builder", Src: "/app", Dest: "/usr/bin/app", Flags: []string{"--chown=1000:1000"}},
+		NewRawStep("COPY a b c /dest/"),
+		NewRawStep(`LABEL version=1.0 "description"="a \"quoted\" value" ref=$VERSION`),
+		&LabelStep{Labels: []command.Label{{Name: "title", Value: "My Image"}, {Name: "licenses", Value: "Apache-2.0"}}},
+		NewWorkDirStep("/src"),
+		NewUserStep("zack"),
+		NewRawStep(`CMD ["/usr/bin/app"]`),
+	}, cf.Stages[0].Steps)
+}
+
+func TestParseErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		in       string
+		expected string
+	}{
+		{
+			name:     "No FROM",
+			in:       "# Nothing here\n",
+			expected: "line 1: no FROM instruction found",
+		},
+		{
+			name:     "Instruction before FROM",
+			in:       "RUN make\nFROM fedora\n",
+			expected: "line 1: RUN before the first FROM",
+		},
+		{
+			name:     "Unsupported FROM flag",
+			in:       "FROM --quiet fedora\n",
+			expected: `line 1: unsupported FROM flag "--quiet"`,
+		},
+		{
+			name:     "Malformed FROM",
+			in:       "FROM fedora\n\nFROM fedora builder\n",
+			expected: `line 3: expected FROM IMAGE [AS NAME], got "fedora builder"`,
+		},
+		{
+			name:     "Unterminated heredoc",
+			in:       "FROM fedora\nRUN <<EOF\nmake\n",
+			expected: "line 2: unterminated heredoc <<EOF",
+		},
+		{
+			name:     "Invalid escape directive",
+			in:       "# escape=ab\nFROM fedora\n",
+			expected: `line 1: invalid escape directive "ab"`,
+		},
+		{
+			name:     "Unterminated continuation",
+			in:       "FROM fedora\nRUN make \\\n",
+			expected: "line 2: unterminated line continuation",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(testCase.in))
+			assert.EqualError(t, err, testCase.expected)
+		})
+	}
+}
+
+func TestParseHeredocs(t *testing.T) {
+	in := `FROM fedora
+RUN --mount=type=cache,target=/var/cache/dnf <<EOF
+dnf install -y git
+echo "$HOME"
+EOF
+RUN python3 <<'PY'
+print("hi")
+PY
+COPY --chmod=0644 <<"EOF" /etc/app.conf
+key = $value
+EOF
+COPY <<a <<b /etc/
+one
+a
+two
+b
+RUN cat <<-EOF > /etc/motd
+	hello
+	EOF
+`
+
+	cf, err := Parse(strings.NewReader(in))
+	require.NoError(t, err)
+
+	assert.Equal(t, []ContainerfileStep{
+		&HeredocRunStep{
+			Mounts:    []*Mount{{Type: "cache", Target: "/var/cache/dnf"}},
+			Script:    "dnf install -y git\necho \"$HOME\"\n",
+			Delimiter: "EOF",
+			Expand:    true,
+		},
+		&HeredocRunStep{Command: "python3", Script: "print(\"hi\")\n", Delimiter: "PY"},
+		&InlineFileStep{Dest: "/etc/app.conf", Content: "key = $value\n", Flags: []string{"--chmod=0644"}, Delimiter: "EOF"},
+		NewRawStep("COPY <<a <<b /etc/\none\na\ntwo\nb"),
+		NewRawStep("RUN cat <<-EOF > /etc/motd\nhello\nEOF"),
+	}, cf.Stages[0].Steps)
+}
+
+func TestParseEscapeDirective(t *testing.T) {
+	in := "# escape=`\nFROM mcr.microsoft.com/windows/servercore\nRUN dir c:\\ && `\n    echo done\n"
+
+	cf, err := Parse(strings.NewReader(in))
+	require.NoError(t, err)
+
+	assert.Equal(t, []Directive{{Name: "escape", Value: "`"}}, cf.Directives)
+	assert.Equal(t, []ContainerfileStep{&MultiRunStep{Commands: []string{`dir c:\`, "echo done"}}}, cf.Stages[0].Steps)
+	assert.Equal(t, "# escape=`\n\nFROM mcr.microsoft.com/windows/servercore\nRUN dir c:\\ `\n    && echo done\n", cf.Format(FormatOpts{}))
+}
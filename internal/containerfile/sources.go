@@ -0,0 +1,142 @@
+package containerfile
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// A COPY or ADD instruction which reads from the build context rather than
+// from another stage or image.
+type ContextCopy struct {
+	// The stage's name, or "stage N" for unnamed stages.
+	Stage string
+	// The instruction as it appears in the Containerfile.
+	Line string
+	// Paths or globs relative to the root of the build context.
+	Sources []string
+	Dest    string
+}
+
+// Returns each COPY and ADD instruction which reads from the build context.
+// Heredocs, URLs, and git repositories given to ADD are not part of the
+// build context and are omitted, as are instructions which copy from another
+// stage. Variables within sources are not substituted; use Evaluate first if
+// they should be.
+func (c *Containerfile) ContextCopies() []ContextCopy {
+	out := []ContextCopy{}
+
+	for i, stage := range c.Stages {
+		for _, step := range stage.Steps {
+			cp, ok := contextCopy(UnwrapStep(step))
+			if !ok || len(cp.Sources) == 0 {
+				continue
+			}
+
+			cp.Stage = stageLabel(c.Stages, i)
+			out = append(out, cp)
+		}
+	}
+
+	return out
+}
+
+// Returns the unique sources of every COPY and ADD instruction which reads
+// from the build context, in sorted order.
+func (c *Containerfile) ContextSources() []string {
+	out := []string{}
+
+	for _, cp := range c.ContextCopies() {
+		out = append(out, cp.Sources...)
+	}
+
+	slices.Sort(out)
+
+	return slices.Compact(out)
+}
+
+func contextCopy(step ContainerfileStep) (ContextCopy, bool) {
+	switch s := step.(type) {
+	case *CopyStep:
+		if s.From != "" || slices.ContainsFunc(s.Flags, isFromFlag) {
+			return ContextCopy{}, false
+		}
+
+		words := strings.Fields(s.Src)
+		return ContextCopy{Line: s.Line(), Sources: contextSources(words), Dest: s.Dest}, true
+	case *RawStep:
+		return parseContextCopy(s.Line())
+	}
+
+	return ContextCopy{}, false
+}
+
+// Parses a COPY or ADD instruction which could not be parsed into a typed
+// step, e.g., one with several sources.
+func parseContextCopy(line string) (ContextCopy, bool) {
+	head, _, _ := strings.Cut(line, "\n")
+
+	instruction, rest := cutWord(head)
+	instruction = strings.ToUpper(instruction)
+
+	if instruction != "COPY" && instruction != "ADD" {
+		return ContextCopy{}, false
+	}
+
+	flags, rest := cutFlags(rest)
+	if slices.ContainsFunc(flags, isFromFlag) {
+		return ContextCopy{}, false
+	}
+
+	words := strings.Fields(rest)
+
+	if strings.HasPrefix(rest, "[") {
+		// The JSON form, e.g., COPY ["a b", "/dest/"].
+		words = []string{}
+		if err := json.Unmarshal([]byte(rest), &words); err != nil {
+			return ContextCopy{}, false
+		}
+	}
+
+	if len(words) < 2 {
+		return ContextCopy{}, false
+	}
+
+	cp := ContextCopy{Line: head, Dest: words[len(words)-1]}
+
+	sources := words[:len(words)-1]
+	if instruction == "ADD" {
+		sources = slices.DeleteFunc(slices.Clone(sources), isRemoteSource)
+	}
+
+	cp.Sources = contextSources(sources)
+
+	return cp, true
+}
+
+// Omits heredocs, which are not read from the build context.
+func contextSources(words []string) []string {
+	out := []string{}
+
+	for _, word := range words {
+		if strings.HasPrefix(word, "<<") {
+			continue
+		}
+
+		out = append(out, word)
+	}
+
+	return out
+}
+
+// Reports whether the given ADD source is fetched rather than read from the
+// build context.
+func isRemoteSource(src string) bool {
+	for _, prefix := range []string{"http://", "https://", "git@", "git://"} {
+		if strings.HasPrefix(src, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
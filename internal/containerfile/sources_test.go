@@ -0,0 +1,31 @@
+package containerfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextCopies(t *testing.T) {
+	in := `FROM golang AS builder
+COPY go.mod go.sum ./
+COPY --chown=1000:1000 ./cmd /src/cmd
+COPY ["with space", "/dest/"]
+COPY <<EOF /etc/app.conf
+key = value
+EOF
+ADD https://example.com/app.tar.gz vendor.tar.gz /src/
+ADD git@github.com:org/repo.git /src/repo
+FROM fedora
+COPY --from=builder /app /usr/bin/app
+`
+
+	assert.Equal(t, []ContextCopy{
+		{Stage: "builder", Line: "COPY go.mod go.sum ./", Sources: []string{"go.mod", "go.sum"}, Dest: "./"},
+		{Stage: "builder", Line: "COPY --chown=1000:1000 ./cmd /src/cmd", Sources: []string{"./cmd"}, Dest: "/src/cmd"},
+		{Stage: "builder", Line: `COPY ["with space", "/dest/"]`, Sources: []string{"with space"}, Dest: "/dest/"},
+		{Stage: "builder", Line: "ADD https://example.com/app.tar.gz vendor.tar.gz /src/", Sources: []string{"vendor.tar.gz"}, Dest: "/src/"},
+	}, mustParse(t, in).ContextCopies())
+
+	assert.Equal(t, []string{"./cmd", "go.mod", "go.sum", "vendor.tar.gz", "with space"}, mustParse(t, in).ContextSources())
+}
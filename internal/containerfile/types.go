@@ -9,6 +9,10 @@ import (
 
 // Top-level Containerfile object
 type Containerfile struct {
+	// Parser directives, which must come before anything else.
+	Directives []Directive
+	// Instructions which come before the first FROM, e.g., global ARGs.
+	Header []ContainerfileStep
 	Stages []*Stage
 	Tag    string
 }
@@ -16,6 +20,18 @@ type Containerfile struct {
 func (c *Containerfile) String() string {
 	sb := &strings.Builder{}
 
+	for _, directive := range c.Directives {
+		fmt.Fprintln(sb, directive.Line())
+	}
+
+	for _, step := range c.Header {
+		fmt.Fprintln(sb, step.Line())
+	}
+
+	if len(c.Directives) != 0 || len(c.Header) != 0 {
+		fmt.Fprintln(sb)
+	}
+
 	for _, stage := range c.Stages {
 		fmt.Fprintln(sb, stage.Line())
 	}
@@ -28,26 +44,49 @@ func (c *Containerfile) String() string {
 type Stage struct {
 	Name  string
 	Image string
-	Steps []ContainerfileStep
+	// The platform to pull the image for, e.g., $BUILDPLATFORM.
+	Platform string
+	// Rendered above the FROM instruction.
+	Comment string
+	Steps   []ContainerfileStep
 }
 
 func (s *Stage) Line() string {
 	sb := &strings.Builder{}
 
+	if s.Comment != "" {
+		fmt.Fprintln(sb, commentLines(s.Comment))
+	}
+
 	from := &FromStep{
-		Image: s.Image,
-		As:    s.Name,
+		Image:    s.Image,
+		As:       s.Name,
+		Platform: s.Platform,
 	}
 
 	fmt.Fprintln(sb, from.Line())
 
 	for _, step := range s.Steps {
-		fmt.Fprintln(sb, step.Line())
+		// Steps which do nothing, such as a LABEL without any labels, render
+		// nothing.
+		if line := step.Line(); line != "" {
+			fmt.Fprintln(sb, line)
+		}
 	}
 
 	return sb.String()
 }
 
+// Represents a parser directive, e.g., # syntax=docker/dockerfile:1.
+type Directive struct {
+	Name  string
+	Value string
+}
+
+func (d Directive) Line() string {
+	return fmt.Sprintf("# %s=%s", d.Name, d.Value)
+}
+
 // Catch-all for a given step in a Containerfile. Each *Step type must
 // implement this interface. For now, this just emits a string representation
 // of what the given step should do.
@@ -57,12 +96,16 @@ type ContainerfileStep interface {
 
 // Represents a FROM statement.
 type FromStep struct {
-	Image string
-	As    string
+	Image    string
+	As       string
+	Platform string
 }
 
 func (f *FromStep) Line() string {
 	from := fmt.Sprintf("FROM %s", f.Image)
+	if f.Platform != "" {
+		from = fmt.Sprintf("FROM --platform=%s %s", f.Platform, f.Image)
+	}
 	if f.As == "" {
 		return from
 	}
@@ -70,7 +113,11 @@ func (f *FromStep) Line() string {
 	return fmt.Sprintf("%s AS %s", from, f.As)
 }
 
+// Represents a --mount option given to a RUN statement. The typed
+// SecretMount, SSHMount, CacheMount, and TmpfsMount structs may be used to
+// construct one of these.
 type Mount struct {
+	ID              string
 	From            string
 	Source          string
 	Target          string
@@ -84,6 +131,7 @@ func (m *Mount) flag() string {
 
 	ordered := []string{
 		"type",
+		"id",
 		"from",
 		"source",
 		"target",
@@ -92,6 +140,7 @@ func (m *Mount) flag() string {
 
 	mountOpts := map[string]string{
 		"type":             m.Type,
+		"id":               m.ID,
 		"from":             m.From,
 		"source":           m.Source,
 		"target":           m.Target,
@@ -107,16 +156,18 @@ func (m *Mount) flag() string {
 
 	splitOpts := strings.Split(m.Opts, ",")
 	for _, item := range splitOpts {
-		out = append(out, item)
+		if item != "" {
+			out = append(out, item)
+		}
 	}
 
 	return fmt.Sprintf("--mount=%s", strings.Join(out, ","))
 }
 
 // A Command primitive knows how to construct a CLI command given its options.
-type Command interface {
-	Command() *command.Command
-}
+// Commands are rendered into RUN instructions with any secret values revealed
+// since the build must execute them.
+type Command = command.Commander
 
 // Chains multiple Commands together with && in between them.
 type MultiCommandRunStep struct {
@@ -131,7 +182,7 @@ type MultiCommandRunStep struct {
 func (m *MultiCommandRunStep) Line() string {
 	cmds := []string{}
 	for _, cmd := range m.Commands {
-		cmds = append(cmds, cmd.Command().String())
+		cmds = append(cmds, cmd.Command().Unredacted())
 	}
 
 	s := &MultiRunStep{
@@ -153,7 +204,7 @@ func (c *CommandRunStep) Line() string {
 	r := &RunStep{
 		Flags:   c.Flags,
 		Mounts:  c.Mounts,
-		Command: c.Command.Command().String(),
+		Command: c.Command.Command().Unredacted(),
 	}
 
 	return r.Line()
@@ -200,16 +251,90 @@ func (m *MultiRunStep) Line() string {
 	return r.Line()
 }
 
-// Represents a LABEL statement.
+// Represents a LABEL statement. Key and Value, when set, are rendered before
+// any Labels.
 type LabelStep struct {
-	Key   string
-	Value string
+	Key    string
+	Value  string
+	Labels []command.Label
+}
+
+// Returns a LABEL statement which sets each of the given labels.
+func NewLabelStep(labels ...command.Label) ContainerfileStep {
+	return &LabelStep{Labels: labels}
+}
+
+// Returns a LABEL statement which sets each of the given OCI image
+// annotations.
+func NewImageAnnotationsStep(a command.ImageAnnotations) ContainerfileStep {
+	return NewLabelStep(a.Labels()...)
 }
 
+// Renders nothing if there are no labels since a bare LABEL is invalid.
 func (l *LabelStep) Line() string {
-	return fmt.Sprintf("LABEL %s=%s", l.Key, l.Value)
+	pairs := l.pairs()
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "LABEL " + strings.Join(pairs, " ")
+}
+
+// Ensures that there is at least one label and that each can be rendered.
+// Since a Containerfile instruction cannot span lines, neither keys nor
+// values may contain newlines.
+func (l *LabelStep) Validate() error {
+	labels := l.labels()
+	if len(labels) == 0 {
+		return fmt.Errorf("LABEL must set at least one label")
+	}
+
+	for _, label := range labels {
+		if label.Name == "" {
+			return fmt.Errorf("LABEL key must not be empty")
+		}
+
+		if strings.ContainsAny(label.Name+label.Value, "\r\n") {
+			return fmt.Errorf("LABEL %s must not contain newlines", label.Name)
+		}
+	}
+
+	return nil
+}
+
+func (l *LabelStep) labels() []command.Label {
+	if l.Key == "" {
+		return l.Labels
+	}
+
+	return append([]command.Label{{Name: l.Key, Value: l.Value}}, l.Labels...)
+}
+
+// Returns each label as a quoted KEY=VALUE pair.
+func (l *LabelStep) pairs() []string {
+	out := []string{}
+
+	for _, label := range l.labels() {
+		out = append(out, fmt.Sprintf("%s=%s", quoteLabelWord(label.Name), quoteLabelWord(label.Value)))
+	}
+
+	return out
+}
+
+// Double-quotes the given label key or value if it is empty or contains
+// whitespace, quotes, or backslashes. Within double quotes, a backslash
+// escapes " and \. Variable references such as $VERSION are left as they are
+// so that build args are still substituted.
+func quoteLabelWord(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+
+	return `"` + labelEscaper.Replace(s) + `"`
 }
 
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
 // Represents a COPY statement.
 type CopyStep struct {
 	From  string
@@ -233,7 +358,10 @@ func (c *CopyStep) Line() string {
 	}
 
 	out := []string{"COPY"}
-	out = append(out, fmt.Sprintf("--from=%s", c.From))
+	if c.From != "" {
+		out = append(out, fmt.Sprintf("--from=%s", c.From))
+	}
+
 	out = append(out, c.Flags...)
 	out = append(out, []string{c.Src, c.Dest}...)
 	return strings.Join(out, " ")
@@ -262,3 +390,67 @@ func NewUserStep(user string) ContainerfileStep {
 func (u *UserStep) Line() string {
 	return fmt.Sprintf("USER %s", *u)
 }
+
+// An instruction which has no typed step, such as ENV or CMD. It is rendered
+// verbatim.
+type RawStep string
+
+func NewRawStep(line string) ContainerfileStep {
+	r := RawStep(line)
+	return &r
+}
+
+func (r *RawStep) Line() string {
+	return string(*r)
+}
+
+// Represents a comment on its own. Each line of the comment is prefixed with
+// #.
+type CommentStep string
+
+func NewCommentStep(comment string) ContainerfileStep {
+	c := CommentStep(comment)
+	return &c
+}
+
+func (c *CommentStep) Line() string {
+	return commentLines(string(*c))
+}
+
+// Attaches a comment to the step which follows it.
+type CommentedStep struct {
+	Comment string
+	Step    ContainerfileStep
+}
+
+func WithComment(comment string, step ContainerfileStep) ContainerfileStep {
+	return &CommentedStep{Comment: comment, Step: step}
+}
+
+func (c *CommentedStep) Line() string {
+	return commentLines(c.Comment) + "\n" + c.Step.Line()
+}
+
+// Returns the step which the given step wraps if it is a CommentedStep.
+// Otherwise, the step is returned as-is.
+func UnwrapStep(step ContainerfileStep) ContainerfileStep {
+	if commented, ok := step.(*CommentedStep); ok {
+		return UnwrapStep(commented.Step)
+	}
+
+	return step
+}
+
+func commentLines(comment string) string {
+	lines := strings.Split(comment, "\n")
+
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = "#"
+		} else {
+			lines[i] = "# " + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,123 @@
+package containerfile
+
+import (
+	"testing"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabelStep(t *testing.T) {
+	testCases := []struct {
+		name     string
+		step     ContainerfileStep
+		expected string
+	}{
+		{
+			name:     "Single pair",
+			step:     &LabelStep{Key: "maintainer", Value: "zack"},
+			expected: "LABEL maintainer=zack",
+		},
+		{
+			name:     "Value with spaces",
+			step:     &LabelStep{Key: "description", Value: "a test image"},
+			expected: `LABEL description="a test image"`,
+		},
+		{
+			name:     "Empty value",
+			step:     &LabelStep{Key: "empty"},
+			expected: `LABEL empty=""`,
+		},
+		{
+			name:     "Quotes, backslashes, and variables",
+			step:     NewLabelStep(command.Label{Name: "quote", Value: `say "hi" \ $HOME`}),
+			expected: `LABEL quote="say \"hi\" \\ $HOME"`,
+		},
+		{
+			name:     "Build arg",
+			step:     &LabelStep{Key: "version", Value: "$VERSION"},
+			expected: "LABEL version=$VERSION",
+		},
+		{
+			name:     "No labels",
+			step:     NewLabelStep(),
+			expected: "",
+		},
+		{
+			name: "Multiple pairs",
+			step: &LabelStep{
+				Key:    "maintainer",
+				Value:  "zack",
+				Labels: []command.Label{{Name: "version", Value: "1.0"}, {Name: "my key", Value: "val"}},
+			},
+			expected: `LABEL maintainer=zack version=1.0 "my key"=val`,
+		},
+		{
+			name: "Image annotations",
+			step: NewImageAnnotationsStep(command.ImageAnnotations{
+				Title:    "My Image",
+				Licenses: "Apache-2.0",
+			}),
+			expected: `LABEL org.opencontainers.image.licenses=Apache-2.0 org.opencontainers.image.title="My Image"`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, testCase.step.Line())
+		})
+	}
+}
+
+func TestLabelStepValidate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		step    *LabelStep
+		errorIs string
+	}{
+		{
+			name: "Valid",
+			step: &LabelStep{Key: "version", Value: "$VERSION", Labels: []command.Label{{Name: "empty"}}},
+		},
+		{
+			name:    "No labels",
+			step:    &LabelStep{},
+			errorIs: "LABEL must set at least one label",
+		},
+		{
+			name:    "Empty key",
+			step:    &LabelStep{Labels: []command.Label{{Value: "val"}}},
+			errorIs: "LABEL key must not be empty",
+		},
+		{
+			name:    "Newline in value",
+			step:    &LabelStep{Key: "description", Value: "first\nsecond"},
+			errorIs: "LABEL description must not contain newlines",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := testCase.step.Validate()
+			if testCase.errorIs == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, testCase.errorIs)
+			}
+		})
+	}
+}
+
+func TestContainerfileValidate(t *testing.T) {
+	cf := &Containerfile{
+		Stages: []*Stage{
+			{Image: "fedora", Steps: []ContainerfileStep{NewLabelStep()}},
+		},
+	}
+
+	assert.EqualError(t, cf.Validate(), "stage 1: LABEL must set at least one label")
+	assert.Equal(t, "FROM fedora\n\n", cf.String())
+
+	cf.Stages[0].Steps = []ContainerfileStep{WithComment("Labels.", &LabelStep{Key: "a", Value: "b"})}
+	assert.NoError(t, cf.Validate())
+}
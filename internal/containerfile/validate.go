@@ -0,0 +1,182 @@
+package containerfile
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+)
+
+// Ensures that each step of the Containerfile can be rendered as a valid
+// instruction.
+func (c *Containerfile) Validate() error {
+	for i, stage := range c.Stages {
+		for _, step := range stage.Steps {
+			label, ok := UnwrapStep(step).(*LabelStep)
+			if !ok {
+				continue
+			}
+
+			if err := label.Validate(); err != nil {
+				return fmt.Errorf("%s: %w", stageLabel(c.Stages, i), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Ensures that every secret and SSH mount referenced by a RUN step in the
+// Containerfile is supplied by the given build options. Secret mounts must
+// have an ID or a target so that the secret can be identified.
+func (c *Containerfile) ValidateBuildOpts(opts *command.BuildOpts) error {
+	if err := c.validateSecretMounts(); err != nil {
+		return err
+	}
+
+	suppliedSecrets := map[string]bool{}
+	for _, secret := range opts.Secrets {
+		suppliedSecrets[secret.ID] = true
+	}
+
+	suppliedSSH := map[string]bool{}
+	for _, ssh := range opts.SSH {
+		id, _, _ := strings.Cut(ssh, "=")
+		suppliedSSH[id] = true
+	}
+
+	missingSecrets := map[string]bool{}
+	missingSSH := map[string]bool{}
+
+	for _, secretID := range c.SecretIDs() {
+		if !suppliedSecrets[secretID] {
+			missingSecrets[secretID] = true
+		}
+	}
+
+	for _, sshID := range c.SSHIDs() {
+		if !suppliedSSH[sshID] {
+			missingSSH[sshID] = true
+		}
+	}
+
+	errs := []string{}
+
+	if len(missingSecrets) != 0 {
+		errs = append(errs, fmt.Sprintf("missing secret(s): %s", strings.Join(sortedSet(missingSecrets), ", ")))
+	}
+
+	if len(missingSSH) != 0 {
+		errs = append(errs, fmt.Sprintf("missing ssh id(s): %s", strings.Join(sortedSet(missingSSH), ", ")))
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("containerfile requires build options that were not supplied: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// Reports each secret mount which has neither an ID nor a target, since
+// buildah cannot tell which secret it refers to.
+func (c *Containerfile) validateSecretMounts() error {
+	errs := []string{}
+
+	for i, stage := range c.Stages {
+		for _, step := range stage.Steps {
+			for _, mount := range stepMounts(step) {
+				if mount.Type == "secret" && mount.ID == "" && mount.Target == "" {
+					line, _, _ := strings.Cut(UnwrapStep(step).Line(), "\n")
+					errs = append(errs, fmt.Sprintf("%s: %s", stageLabel(c.Stages, i), line))
+				}
+			}
+		}
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("secret mount(s) without an id or target: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// Returns the unique IDs of all secret mounts referenced within the
+// Containerfile.
+func (c *Containerfile) SecretIDs() []string {
+	return c.mountIDs("secret", func(m *Mount) string {
+		// Buildah defaults the ID to the basename of the target.
+		if m.Target != "" {
+			return filepath.Base(m.Target)
+		}
+
+		return ""
+	})
+}
+
+// Returns the unique IDs of all SSH mounts referenced within the
+// Containerfile.
+func (c *Containerfile) SSHIDs() []string {
+	return c.mountIDs("ssh", func(_ *Mount) string {
+		return "default"
+	})
+}
+
+// Collects the unique IDs of all mounts of the given type, falling back to
+// the given default func when a mount has no ID.
+func (c *Containerfile) mountIDs(mountType string, defaultID func(*Mount) string) []string {
+	ids := map[string]bool{}
+
+	for _, stage := range c.Stages {
+		for _, step := range stage.Steps {
+			for _, mount := range stepMounts(step) {
+				if mount.Type != mountType {
+					continue
+				}
+
+				id := mount.ID
+				if id == "" {
+					id = defaultID(mount)
+				}
+
+				if id != "" {
+					ids[id] = true
+				}
+			}
+		}
+	}
+
+	return sortedSet(ids)
+}
+
+// Returns the mounts for the given step if it is a RUN step.
+func stepMounts(step ContainerfileStep) []*Mount {
+	switch s := UnwrapStep(step).(type) {
+	case *RunStep:
+		return s.Mounts
+	case *MultiRunStep:
+		return s.Mounts
+	case *CommandRunStep:
+		return s.Mounts
+	case *MultiCommandRunStep:
+		return s.Mounts
+	case *HeredocRunStep:
+		return s.Mounts
+	default:
+		return nil
+	}
+}
+
+// Returns the keys of the given set in sorted order.
+func sortedSet(set map[string]bool) []string {
+	out := []string{}
+
+	for key := range set {
+		out = append(out, key)
+	}
+
+	sort.Strings(out)
+
+	return out
+}
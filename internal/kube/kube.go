@@ -0,0 +1,405 @@
+package kube
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+	"github.com/cheesesashimi/zacks-container-playground/internal/plan"
+	"gopkg.in/yaml.v3"
+)
+
+// The user within the quay.io/buildah/stable image which rootless builds run
+// as.
+const buildahUID int64 = 1000
+
+// Where rootless buildah keeps its container storage within the
+// quay.io/buildah/stable image.
+const buildahStoragePath = "/home/build/.local/share/containers"
+
+// Options for generating a Pod or Job manifest.
+type Opts struct {
+	Name      string
+	Namespace string
+	Labels    map[string]string
+	// The image which each command runs within, e.g., quay.io/buildah/stable.
+	Image              string
+	ServiceAccountName string
+	// Mounted into every container. Volumes without a HostPath become emptyDir
+	// volumes, volumes whose HostPath is a key of SecretVolumes become secret
+	// volumes, and the rest become hostPath volumes. An Opts of "ro" mounts the
+	// volume read-only.
+	Volumes []command.Volume
+	// Maps a HostPath from Volumes onto the name of the Secret to mount in its
+	// place.
+	SecretVolumes map[string]string
+	// The name of the Secret holding the values of any secret args, flags, or
+	// environment variables, keyed by environment variable name. Secret values
+	// are never written into the manifest.
+	SecretName string
+	// Run each container with a security context suitable for rootless buildah
+	// builds and give buildah an emptyDir for its storage.
+	RootlessBuildah bool
+}
+
+// Returns a Pod which runs the given command.
+func PodForCommand(cmd command.Commander, opts Opts) (*Pod, error) {
+	c := cmd.Command()
+
+	container, err := opts.container(containerName(c.Name()), c)
+	if err != nil {
+		return nil, err
+	}
+
+	return opts.pod([]Container{*container})
+}
+
+// Returns a Pod which runs each step of the given plan in dependency order.
+// Every step but the last runs as an init container so that the steps run
+// sequentially; a failing step therefore fails the Pod. Since each container
+// has its own filesystem, the directory containing each file a step outputs
+// is shared between the containers with an emptyDir, unless it is already
+// within one of the given volumes. Action steps run Go code, which cannot run
+// within a Pod, so plans containing them are rejected.
+func PodForPlan(p *plan.Plan, opts Opts) (*Pod, error) {
+	ordered, err := p.Order()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, step := range ordered {
+		if step.Command == nil {
+			return nil, fmt.Errorf("step %q runs Go code and cannot be run in a Pod", step.Name)
+		}
+	}
+
+	shared, err := sharedVolumes(ordered, opts.allVolumes())
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Volumes = append(slices.Clone(opts.Volumes), shared...)
+
+	containers := []Container{}
+
+	for _, step := range ordered {
+		container, err := opts.container(containerName(step.Name), step.Command.Command())
+		if err != nil {
+			return nil, fmt.Errorf("step %q: %w", step.Name, err)
+		}
+
+		containers = append(containers, *container)
+	}
+
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("plan has no command steps")
+	}
+
+	return opts.pod(containers)
+}
+
+// Wraps the given Pod in a Job which does not retry it.
+func JobForPod(pod *Pod) *Job {
+	backoffLimit := int32(0)
+
+	return &Job{
+		APIVersion: "batch/v1",
+		Kind:       "Job",
+		Metadata:   pod.Metadata,
+		Spec: JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: PodTemplateSpec{
+				Metadata: ObjectMeta{Labels: pod.Metadata.Labels},
+				Spec:     pod.Spec,
+			},
+		},
+	}
+}
+
+// Renders the given objects as a YAML stream suitable for oc apply -f.
+func Marshal(objs ...any) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+
+	for _, obj := range objs {
+		if err := enc.Encode(obj); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Returns a security context suitable for rootless buildah builds within the
+// quay.io/buildah/stable image. The SETUID and SETGID capabilities, along with
+// privilege escalation, are required by newuidmap and newgidmap to set up the
+// user namespace.
+func RootlessBuildahSecurityContext() *SecurityContext {
+	uid := buildahUID
+	nonRoot := true
+	escalation := true
+
+	return &SecurityContext{
+		RunAsUser:                &uid,
+		RunAsNonRoot:             &nonRoot,
+		AllowPrivilegeEscalation: &escalation,
+		Capabilities: &Capabilities{
+			Add: []string{"SETUID", "SETGID"},
+		},
+	}
+}
+
+func (o *Opts) pod(containers []Container) (*Pod, error) {
+	if o.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+
+	volumes, err := o.volumes()
+	if err != nil {
+		return nil, err
+	}
+
+	spec := PodSpec{
+		ServiceAccountName: o.ServiceAccountName,
+		RestartPolicy:      "Never",
+		InitContainers:     containers[:len(containers)-1],
+		Containers:         containers[len(containers)-1:],
+		Volumes:            volumes,
+	}
+
+	if len(spec.InitContainers) == 0 {
+		spec.InitContainers = nil
+	}
+
+	return &Pod{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Metadata: ObjectMeta{
+			Name:      o.Name,
+			Namespace: o.Namespace,
+			Labels:    o.Labels,
+		},
+		Spec: spec,
+	}, nil
+}
+
+func (o *Opts) container(name string, cmd *command.Command) (*Container, error) {
+	if o.Image == "" {
+		return nil, fmt.Errorf("image is required")
+	}
+
+	argv, env, secrets := kubeArgv(cmd)
+
+	container := &Container{
+		Name:    name,
+		Image:   o.Image,
+		Command: argv[:1],
+		Args:    argv[1:],
+	}
+
+	if o.RootlessBuildah {
+		for name, val := range map[string]string{"BUILDAH_ISOLATION": "chroot", "STORAGE_DRIVER": "vfs"} {
+			if _, ok := env[name]; !ok {
+				env[name] = val
+			}
+		}
+
+		container.SecurityContext = RootlessBuildahSecurityContext()
+	}
+
+	for _, name := range sortedKeys(env) {
+		container.Env = append(container.Env, EnvVar{Name: name, Value: env[name]})
+	}
+
+	if len(secrets) != 0 && o.SecretName == "" {
+		return nil, fmt.Errorf("command has secret values but no secret name was given")
+	}
+
+	for _, secret := range secrets {
+		container.Env = append(container.Env, EnvVar{
+			Name: secret.EnvVar,
+			ValueFrom: &EnvVarSource{
+				SecretKeyRef: &SecretKeySelector{Name: o.SecretName, Key: secret.EnvVar},
+			},
+		})
+	}
+
+	volumes, err := o.volumes()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, volume := range o.allVolumes() {
+		container.VolumeMounts = append(container.VolumeMounts, VolumeMount{
+			Name:      volumes[i].Name,
+			MountPath: volume.ContainerPath,
+			ReadOnly:  slices.Contains(strings.Split(volume.Opts, ","), "ro"),
+		})
+	}
+
+	return container, nil
+}
+
+// Returns the given volumes as well as the buildah storage volume, if needed.
+func (o *Opts) allVolumes() []command.Volume {
+	volumes := slices.Clone(o.Volumes)
+
+	if o.RootlessBuildah {
+		volumes = append(volumes, command.Volume{ContainerPath: buildahStoragePath})
+	}
+
+	return volumes
+}
+
+func (o *Opts) volumes() ([]Volume, error) {
+	out := []Volume{}
+	names := map[string]bool{}
+
+	for _, volume := range o.allVolumes() {
+		if volume.ContainerPath == "" {
+			return nil, fmt.Errorf("volume %q has no container path", volume.HostPath)
+		}
+
+		name := dnsLabel(volume.ContainerPath)
+		for i := 2; names[name]; i++ {
+			name = fmt.Sprintf("%s-%d", dnsLabel(volume.ContainerPath), i)
+		}
+
+		names[name] = true
+
+		v := Volume{Name: name}
+
+		switch secretName, isSecret := o.SecretVolumes[volume.HostPath]; {
+		case volume.HostPath == "":
+			v.EmptyDir = &EmptyDirVolumeSource{}
+		case isSecret:
+			v.Secret = &SecretVolumeSource{SecretName: secretName}
+		default:
+			v.HostPath = &HostPathVolumeSource{Path: volume.HostPath}
+		}
+
+		out = append(out, v)
+	}
+
+	return out, nil
+}
+
+// Returns emptyDir volumes for the directories containing the files which the
+// given steps output, omitting those within the given volumes. Outputs which
+// are not absolute paths, such as image names, are skipped. Inputs which no
+// step outputs are left alone since they come from the image or a volume.
+func sharedVolumes(steps []*plan.Step, volumes []command.Volume) ([]command.Volume, error) {
+	dirs := []string{}
+
+	for _, step := range steps {
+		for _, output := range step.Outputs {
+			if !path.IsAbs(output) {
+				continue
+			}
+
+			dir := path.Dir(path.Clean(output))
+			if dir == "/" {
+				return nil, fmt.Errorf("step %q: output %q cannot be shared since it is not within a directory", step.Name, output)
+			}
+
+			dirs = append(dirs, dir)
+		}
+	}
+
+	// Sorting places each directory before those nested within it.
+	slices.Sort(dirs)
+
+	out := []command.Volume{}
+	covered := []string{}
+
+	for _, volume := range volumes {
+		covered = append(covered, path.Clean(volume.ContainerPath))
+	}
+
+	for _, dir := range dirs {
+		if slices.ContainsFunc(covered, func(parent string) bool { return isWithin(dir, parent) }) {
+			continue
+		}
+
+		covered = append(covered, dir)
+		out = append(out, command.Volume{ContainerPath: dir})
+	}
+
+	return out, nil
+}
+
+// Reports whether the given path is the given directory or is nested within
+// it.
+func isWithin(p, dir string) bool {
+	return p == dir || dir == "/" || strings.HasPrefix(p, dir+"/")
+}
+
+// Stands in for a reference to a secret environment variable until the
+// literal text around it has been escaped.
+var secretPlaceholder = regexp.MustCompile("\x00([^\x00]+)\x00")
+
+// Returns the argv and environment of the given command for a container.
+// Kubernetes expands $(VAR) within the command, args, and env values from the
+// container env, so secret values are referenced that way while any literal
+// $( is escaped as $$(.
+func kubeArgv(cmd *command.Command) ([]string, map[string]string, []command.SecretRef) {
+	argv, env, secrets := cmd.ArgvWithSecretRefs(func(envVar string) string {
+		return "\x00" + envVar + "\x00"
+	})
+
+	for i, arg := range argv {
+		argv[i] = kubeEscape(arg)
+	}
+
+	for name, val := range env {
+		env[name] = kubeEscape(val)
+	}
+
+	return argv, env, secrets
+}
+
+func kubeEscape(s string) string {
+	return secretPlaceholder.ReplaceAllString(strings.ReplaceAll(s, "$(", "$$("), "$$($1)")
+}
+
+var dnsLabelInvalidChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// Converts the given string into a valid DNS-1123 label, as required for
+// container and volume names.
+func dnsLabel(s string) string {
+	label := strings.Trim(dnsLabelInvalidChars.ReplaceAllString(strings.ToLower(s), "-"), "-")
+
+	if len(label) > 63 {
+		label = strings.TrimRight(label[:63], "-")
+	}
+
+	if label == "" {
+		return "volume"
+	}
+
+	return label
+}
+
+func containerName(s string) string {
+	return dnsLabel(s[strings.LastIndex(s, "/")+1:])
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,212 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+	"github.com/cheesesashimi/zacks-container-playground/internal/plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPodForCommand(t *testing.T) {
+	opts := Opts{
+		Name:      "build",
+		Namespace: "ci",
+		Image:     "quay.io/buildah/stable:latest",
+		Volumes: []command.Volume{
+			{HostPath: "/var/run/secrets/registry", ContainerPath: "/var/run/secrets/registry", Opts: "ro"},
+			{HostPath: "/etc/pki/ca-trust", ContainerPath: "/etc/pki/ca-trust", Opts: "ro"},
+			{ContainerPath: "/workspace"},
+		},
+		SecretVolumes:   map[string]string{"/var/run/secrets/registry": "registry-creds"},
+		SecretName:      "build-secrets",
+		RootlessBuildah: true,
+	}
+
+	login := &command.BuildahLogin{
+		Authfile: "/workspace/auth.json",
+		Username: "robot",
+		Password: "hunter2",
+		Registry: "quay.io",
+	}
+
+	pod, err := PodForCommand(login, opts)
+	require.NoError(t, err)
+
+	out, err := Marshal(JobForPod(pod))
+	require.NoError(t, err)
+
+	expected := `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: build
+  namespace: ci
+spec:
+  backoffLimit: 0
+  template:
+    spec:
+      restartPolicy: Never
+      containers:
+        - name: buildah
+          image: quay.io/buildah/stable:latest
+          command:
+            - buildah
+          args:
+            - login
+            - --authfile
+            - /workspace/auth.json
+            - --username
+            - robot
+            - --password
+            - $(PASSWORD)
+            - quay.io
+          env:
+            - name: BUILDAH_ISOLATION
+              value: chroot
+            - name: STORAGE_DRIVER
+              value: vfs
+            - name: PASSWORD
+              valueFrom:
+                secretKeyRef:
+                  name: build-secrets
+                  key: PASSWORD
+          volumeMounts:
+            - name: var-run-secrets-registry
+              mountPath: /var/run/secrets/registry
+              readOnly: true
+            - name: etc-pki-ca-trust
+              mountPath: /etc/pki/ca-trust
+              readOnly: true
+            - name: workspace
+              mountPath: /workspace
+            - name: home-build-local-share-containers
+              mountPath: /home/build/.local/share/containers
+          securityContext:
+            runAsUser: 1000
+            runAsNonRoot: true
+            allowPrivilegeEscalation: true
+            capabilities:
+              add:
+                - SETUID
+                - SETGID
+      volumes:
+        - name: var-run-secrets-registry
+          secret:
+            secretName: registry-creds
+        - name: etc-pki-ca-trust
+          hostPath:
+            path: /etc/pki/ca-trust
+        - name: workspace
+          emptyDir: {}
+        - name: home-build-local-share-containers
+          emptyDir: {}
+`
+
+	assert.Equal(t, expected, string(out))
+	assert.NotContains(t, string(out), "hunter2")
+}
+
+func TestPodForPlan(t *testing.T) {
+	bp := &plan.BuildahBuildAndPush{
+		CADestRoot: "/etc/pki/ca-trust/extracted",
+		Login: &command.BuildahLogin{
+			Authfile: "/auth/auth.json",
+			Username: "robot",
+			Password: "hunter2",
+			Registry: "quay.io",
+		},
+		Build: &command.BuildahBuild{
+			BuildOpts: command.BuildOpts{
+				File:     "Containerfile",
+				Tag:      "quay.io/org/image:latest",
+				Authfile: "/auth/auth.json",
+			},
+		},
+		Tags: []string{"quay.io/org/image:v1"},
+		Push: &command.BuildahPush{
+			Authfile: "/auth/auth.json",
+		},
+	}
+
+	opts := Opts{
+		Name:       "build-and-push",
+		Image:      "quay.io/buildah/stable",
+		SecretName: "build-secrets",
+		Volumes:    []command.Volume{{ContainerPath: "/etc/pki/ca-trust/extracted/pem"}},
+	}
+
+	pod, err := PodForPlan(bp.Plan(), opts)
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, container := range pod.Spec.InitContainers {
+		names = append(names, container.Name)
+	}
+
+	assert.Equal(t, []string{"extract-ca-openssl", "extract-ca-pem", "login", "build", "tag"}, names)
+	require.Len(t, pod.Spec.Containers, 1)
+	assert.Equal(t, "push", pod.Spec.Containers[0].Name)
+	assert.Equal(t, []string{"push", "--authfile", "/auth/auth.json", "quay.io/org/image:latest"}, pod.Spec.Containers[0].Args)
+
+	// The directories holding the CA bundle and the authfile are shared, apart
+	// from the PEM bundle's, which is already within a volume.
+	assert.Equal(t, []Volume{
+		{Name: "etc-pki-ca-trust-extracted-pem", EmptyDir: &EmptyDirVolumeSource{}},
+		{Name: "auth", EmptyDir: &EmptyDirVolumeSource{}},
+		{Name: "etc-pki-ca-trust-extracted-openssl", EmptyDir: &EmptyDirVolumeSource{}},
+	}, pod.Spec.Volumes)
+
+	// The login step which writes the authfile and the push step which reads
+	// it share the same mount.
+	authMount := VolumeMount{Name: "auth", MountPath: "/auth"}
+	assert.Contains(t, pod.Spec.InitContainers[2].VolumeMounts, authMount)
+	assert.Contains(t, pod.Spec.Containers[0].VolumeMounts, authMount)
+
+	// The caller's volumes are not modified.
+	assert.Len(t, opts.Volumes, 1)
+}
+
+func TestPodForPlanActionStep(t *testing.T) {
+	bp := &plan.BuildahBuildAndPush{
+		Build: &command.BuildahBuild{
+			BuildOpts: command.BuildOpts{Tag: "quay.io/org/image:latest"},
+		},
+		Push: &command.BuildahPush{Digestfile: "/workspace/digestfile"},
+	}
+
+	_, err := PodForPlan(bp.Plan(), Opts{Name: "build-and-push", Image: "quay.io/buildah/stable"})
+	assert.EqualError(t, err, `step "capture-digest" runs Go code and cannot be run in a Pod`)
+}
+
+func TestPodForCommandEscapesVariableReferences(t *testing.T) {
+	cmd := command.NewCommandWithEnv("sh", []command.Arg{
+		command.PositionalArg("-c"),
+		command.PositionalArg("echo $(date) $HOME"),
+		&command.SecretValueFlag{Name: "token", Prefix: "$(literal)", Value: "hunter2"},
+	}, map[string]string{"GREETING": "$(hello)"})
+
+	pod, err := PodForCommand(cmd, Opts{Name: "echo", Image: "fedora", SecretName: "secrets"})
+	require.NoError(t, err)
+
+	container := pod.Spec.Containers[0]
+	assert.Equal(t, []string{"sh"}, container.Command)
+	assert.Equal(t, []string{"-c", "echo $$(date) $HOME", "--token", "$$(literal)$(TOKEN)"}, container.Args)
+	assert.Equal(t, []EnvVar{
+		{Name: "GREETING", Value: "$$(hello)"},
+		{Name: "TOKEN", ValueFrom: &EnvVarSource{SecretKeyRef: &SecretKeySelector{Name: "secrets", Key: "TOKEN"}}},
+	}, container.Env)
+}
+
+func TestPodErrors(t *testing.T) {
+	login := &command.BuildahLogin{Password: "hunter2", Registry: "quay.io"}
+
+	_, err := PodForCommand(login, Opts{Name: "login"})
+	assert.EqualError(t, err, "image is required")
+
+	_, err = PodForCommand(login, Opts{Name: "login", Image: "quay.io/buildah/stable"})
+	assert.EqualError(t, err, "command has secret values but no secret name was given")
+
+	_, err = PodForPlan(plan.New(), Opts{Name: "empty", Image: "quay.io/buildah/stable"})
+	assert.EqualError(t, err, "plan has no command steps")
+}
@@ -0,0 +1,109 @@
+package kube
+
+// The subset of the Kubernetes API types needed to run commands in-cluster.
+// These are defined here rather than importing k8s.io/api so that this module
+// does not need to depend upon it; the field names and YAML keys match the
+// upstream types.
+
+type ObjectMeta struct {
+	Name        string            `yaml:"name,omitempty"`
+	Namespace   string            `yaml:"namespace,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+type Pod struct {
+	APIVersion string     `yaml:"apiVersion"`
+	Kind       string     `yaml:"kind"`
+	Metadata   ObjectMeta `yaml:"metadata"`
+	Spec       PodSpec    `yaml:"spec"`
+}
+
+type PodSpec struct {
+	ServiceAccountName string      `yaml:"serviceAccountName,omitempty"`
+	RestartPolicy      string      `yaml:"restartPolicy,omitempty"`
+	InitContainers     []Container `yaml:"initContainers,omitempty"`
+	Containers         []Container `yaml:"containers"`
+	Volumes            []Volume    `yaml:"volumes,omitempty"`
+}
+
+type Container struct {
+	Name            string           `yaml:"name"`
+	Image           string           `yaml:"image"`
+	Command         []string         `yaml:"command,omitempty"`
+	Args            []string         `yaml:"args,omitempty"`
+	Env             []EnvVar         `yaml:"env,omitempty"`
+	VolumeMounts    []VolumeMount    `yaml:"volumeMounts,omitempty"`
+	SecurityContext *SecurityContext `yaml:"securityContext,omitempty"`
+}
+
+type EnvVar struct {
+	Name      string        `yaml:"name"`
+	Value     string        `yaml:"value,omitempty"`
+	ValueFrom *EnvVarSource `yaml:"valueFrom,omitempty"`
+}
+
+type EnvVarSource struct {
+	SecretKeyRef *SecretKeySelector `yaml:"secretKeyRef,omitempty"`
+}
+
+type SecretKeySelector struct {
+	Name string `yaml:"name"`
+	Key  string `yaml:"key"`
+}
+
+type VolumeMount struct {
+	Name      string `yaml:"name"`
+	MountPath string `yaml:"mountPath"`
+	ReadOnly  bool   `yaml:"readOnly,omitempty"`
+}
+
+type Volume struct {
+	Name     string                `yaml:"name"`
+	HostPath *HostPathVolumeSource `yaml:"hostPath,omitempty"`
+	EmptyDir *EmptyDirVolumeSource `yaml:"emptyDir,omitempty"`
+	Secret   *SecretVolumeSource   `yaml:"secret,omitempty"`
+}
+
+type HostPathVolumeSource struct {
+	Path string `yaml:"path"`
+}
+
+type EmptyDirVolumeSource struct {
+	Medium string `yaml:"medium,omitempty"`
+}
+
+type SecretVolumeSource struct {
+	SecretName string `yaml:"secretName"`
+}
+
+type SecurityContext struct {
+	RunAsUser                *int64        `yaml:"runAsUser,omitempty"`
+	RunAsGroup               *int64        `yaml:"runAsGroup,omitempty"`
+	RunAsNonRoot             *bool         `yaml:"runAsNonRoot,omitempty"`
+	Privileged               *bool         `yaml:"privileged,omitempty"`
+	AllowPrivilegeEscalation *bool         `yaml:"allowPrivilegeEscalation,omitempty"`
+	Capabilities             *Capabilities `yaml:"capabilities,omitempty"`
+}
+
+type Capabilities struct {
+	Add  []string `yaml:"add,omitempty"`
+	Drop []string `yaml:"drop,omitempty"`
+}
+
+type Job struct {
+	APIVersion string     `yaml:"apiVersion"`
+	Kind       string     `yaml:"kind"`
+	Metadata   ObjectMeta `yaml:"metadata"`
+	Spec       JobSpec    `yaml:"spec"`
+}
+
+type JobSpec struct {
+	BackoffLimit *int32          `yaml:"backoffLimit,omitempty"`
+	Template     PodTemplateSpec `yaml:"template"`
+}
+
+type PodTemplateSpec struct {
+	Metadata ObjectMeta `yaml:"metadata,omitempty"`
+	Spec     PodSpec    `yaml:"spec"`
+}
@@ -0,0 +1,160 @@
+package lockfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/containerfile"
+	"gopkg.in/yaml.v3"
+)
+
+// The current version of the lockfile format.
+const Version = "v1"
+
+// Maps each external image of a Containerfile to the digest it is pinned to.
+type Lockfile struct {
+	Version string `json:"version" yaml:"version"`
+	// Sorted by image.
+	Images []LockedImage `json:"images" yaml:"images"`
+}
+
+// An image reference, as it appears in the Containerfile, and the digest of
+// its manifest, e.g., sha256:.... For multi-arch images, this is the digest
+// of the manifest list.
+type LockedImage struct {
+	Image  string `json:"image" yaml:"image"`
+	Digest string `json:"digest" yaml:"digest"`
+}
+
+// Creates an empty lockfile.
+func New() *Lockfile {
+	return &Lockfile{Version: Version, Images: []LockedImage{}}
+}
+
+// Parses a lockfile. Since JSON is valid YAML, either may be given.
+func Parse(r io.Reader) (*Lockfile, error) {
+	lock := New()
+
+	if err := yaml.NewDecoder(r).Decode(lock); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if lock.Version != Version {
+		return nil, fmt.Errorf("unsupported lockfile version %q", lock.Version)
+	}
+
+	for _, img := range lock.Images {
+		if img.Image == "" || img.Digest == "" {
+			return nil, fmt.Errorf("lockfile entry %v must have both an image and a digest", img)
+		}
+	}
+
+	lock.sort()
+
+	return lock, nil
+}
+
+// Reads the lockfile at the given path.
+func ReadFile(name string) (*Lockfile, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	lock, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", name, err)
+	}
+
+	return lock, nil
+}
+
+// Renders the lockfile as YAML.
+func (l *Lockfile) Marshal() ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(l); err != nil {
+		return nil, err
+	}
+
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Writes the lockfile as YAML to the given path.
+func (l *Lockfile) WriteFile(name string) error {
+	out, err := l.Marshal()
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(name, out, 0o644)
+}
+
+// Returns the digest the given image is pinned to, if any.
+func (l *Lockfile) Digest(image string) (string, bool) {
+	idx, found := slices.BinarySearchFunc(l.Images, image, compareImage)
+	if !found {
+		return "", false
+	}
+
+	return l.Images[idx].Digest, true
+}
+
+// Pins the given image to the given digest, replacing any existing entry.
+func (l *Lockfile) Set(image, digest string) {
+	idx, found := slices.BinarySearchFunc(l.Images, image, compareImage)
+	if found {
+		l.Images[idx].Digest = digest
+		return
+	}
+
+	l.Images = slices.Insert(l.Images, idx, LockedImage{Image: image, Digest: digest})
+}
+
+// Returns a copy of the Containerfile with each of its external images
+// rewritten to image@digest, e.g., fedora:40@sha256:.... The tag is kept so
+// that readers can still tell which version is in use. Returns an error
+// naming any images which are not in the lockfile.
+func (l *Lockfile) Pin(cf *containerfile.Containerfile) (*containerfile.Containerfile, error) {
+	refs := map[string]string{}
+	missing := []string{}
+
+	for _, image := range cf.ExternalImages() {
+		digest, ok := l.Digest(image)
+		if !ok {
+			missing = append(missing, image)
+			continue
+		}
+
+		refs[image] = image + "@" + digest
+	}
+
+	if len(missing) != 0 {
+		return nil, fmt.Errorf("images not in the lockfile: %s", strings.Join(missing, ", "))
+	}
+
+	return cf.ReplaceImages(refs), nil
+}
+
+func (l *Lockfile) sort() {
+	slices.SortFunc(l.Images, func(a, b LockedImage) int {
+		return strings.Compare(a.Image, b.Image)
+	})
+}
+
+func compareImage(img LockedImage, image string) int {
+	return strings.Compare(img.Image, image)
+}
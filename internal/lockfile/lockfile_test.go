@@ -0,0 +1,142 @@
+package lockfile
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/containerfile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	fedoraDigest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	centosDigest = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	golangDigest = "sha256:3333333333333333333333333333333333333333333333333333333333333333"
+)
+
+func mustParse(t *testing.T, in string) *containerfile.Containerfile {
+	t.Helper()
+
+	cf, err := containerfile.Parse(strings.NewReader(in))
+	require.NoError(t, err)
+
+	return cf
+}
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name     string
+		in       string
+		expected *Lockfile
+		errorIs  string
+	}{
+		{
+			name: "YAML",
+			in: `version: v1
+images:
+  - image: quay.io/fedora/fedora:40
+    digest: ` + fedoraDigest + `
+  - image: docker.io/library/golang:1.23
+    digest: ` + golangDigest + `
+`,
+			expected: &Lockfile{
+				Version: Version,
+				Images: []LockedImage{
+					{Image: "docker.io/library/golang:1.23", Digest: golangDigest},
+					{Image: "quay.io/fedora/fedora:40", Digest: fedoraDigest},
+				},
+			},
+		},
+		{
+			name: "JSON",
+			in:   `{"version": "v1", "images": [{"image": "quay.io/fedora/fedora:40", "digest": "` + fedoraDigest + `"}]}`,
+			expected: &Lockfile{
+				Version: Version,
+				Images:  []LockedImage{{Image: "quay.io/fedora/fedora:40", Digest: fedoraDigest}},
+			},
+		},
+		{
+			name:    "Unsupported version",
+			in:      "version: v2\n",
+			errorIs: `unsupported lockfile version "v2"`,
+		},
+		{
+			name:    "Missing digest",
+			in:      "version: v1\nimages:\n  - image: fedora\n",
+			errorIs: "must have both an image and a digest",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			lock, err := Parse(strings.NewReader(testCase.in))
+			if testCase.errorIs != "" {
+				assert.ErrorContains(t, err, testCase.errorIs)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, lock)
+		})
+	}
+}
+
+func TestReadWriteFile(t *testing.T) {
+	lock := New()
+	lock.Set("quay.io/fedora/fedora:40", fedoraDigest)
+	lock.Set("docker.io/library/golang:1.23", golangDigest)
+
+	name := filepath.Join(t.TempDir(), "containerfile.lock")
+	require.NoError(t, lock.WriteFile(name))
+
+	read, err := ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, lock, read)
+
+	out, err := lock.Marshal()
+	require.NoError(t, err)
+	assert.Equal(t, `version: v1
+images:
+  - image: docker.io/library/golang:1.23
+    digest: `+golangDigest+`
+  - image: quay.io/fedora/fedora:40
+    digest: `+fedoraDigest+`
+`, string(out))
+}
+
+func TestPin(t *testing.T) {
+	cf := mustParse(t, `FROM quay.io/centos/stream:9 AS builder
+COPY --from=docker.io/library/golang:1.23 /usr/local/go /usr/local/go
+RUN go build -o /app .
+FROM quay.io/fedora/fedora:40
+COPY --from=builder /app /app
+`)
+
+	lock := New()
+	lock.Set("quay.io/centos/stream:9", centosDigest)
+	lock.Set("quay.io/fedora/fedora:40", fedoraDigest)
+
+	_, err := lock.Pin(cf)
+	assert.EqualError(t, err, "images not in the lockfile: docker.io/library/golang:1.23")
+
+	lock.Set("docker.io/library/golang:1.23", golangDigest)
+
+	pinned, err := lock.Pin(cf)
+	require.NoError(t, err)
+
+	assert.Equal(t, `FROM quay.io/centos/stream:9@`+centosDigest+` AS builder
+COPY --from=docker.io/library/golang:1.23@`+golangDigest+` /usr/local/go /usr/local/go
+RUN go build -o /app .
+
+FROM quay.io/fedora/fedora:40@`+fedoraDigest+`
+COPY --from=builder /app /app
+
+`, pinned.String())
+
+	// Pinning is idempotent since pinned images are no longer external.
+	again, err := New().Pin(pinned)
+	require.NoError(t, err)
+	assert.Equal(t, pinned.String(), again.String())
+}
@@ -0,0 +1,84 @@
+package lockfile
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+)
+
+// Looks up the current digest of an image reference, e.g., fedora:40.
+type Resolver interface {
+	Resolve(ctx context.Context, image string) (string, error)
+}
+
+// Resolves digests from the registry with skopeo inspect. The digest is
+// computed from the raw manifest so that multi-arch images are pinned to
+// their manifest list rather than to the manifest for the local platform.
+type SkopeoResolver struct {
+	// Defaults to running skopeo as a local process.
+	Executor command.Executor
+	Authfile string
+}
+
+func (s *SkopeoResolver) Resolve(ctx context.Context, image string) (string, error) {
+	executor := s.Executor
+	if executor == nil {
+		executor = command.ExecExecutor{}
+	}
+
+	inspect := &command.SkopeoInspect{
+		Authfile: s.Authfile,
+		Raw:      true,
+		Image:    "docker://" + image,
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	if err := executor.Execute(ctx, inspect.Command(), stdout, stderr); err != nil {
+		return "", fmt.Errorf("could not inspect %s: %w: %s", image, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	if stdout.Len() == 0 {
+		return "", fmt.Errorf("could not inspect %s: no manifest returned", image)
+	}
+
+	sum := sha256.Sum256(stdout.Bytes())
+
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// Resolves digests from a fixed map, for use in tests. Images which are not
+// in the map return an error.
+type FakeResolver struct {
+	Digests  map[string]string
+	mu       sync.Mutex
+	resolved []string
+}
+
+func (f *FakeResolver) Resolve(_ context.Context, image string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.resolved = append(f.resolved, image)
+
+	digest, ok := f.Digests[image]
+	if !ok {
+		return "", fmt.Errorf("could not resolve %s: not found", image)
+	}
+
+	return digest, nil
+}
+
+// Returns the images which have been resolved, in order.
+func (f *FakeResolver) Resolved() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string{}, f.resolved...)
+}
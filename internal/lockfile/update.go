@@ -0,0 +1,84 @@
+package lockfile
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/containerfile"
+)
+
+// Options for updating a lockfile.
+type UpdateOpts struct {
+	// Defaults to a SkopeoResolver.
+	Resolver Resolver
+	// Resolves every image again rather than only those which are not yet in
+	// the lockfile.
+	Refresh bool
+}
+
+// A difference between two versions of a lockfile. Old is empty for added
+// images while New is empty for removed ones.
+type Change struct {
+	Image string `json:"image"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+func (c Change) String() string {
+	switch {
+	case c.Old == "":
+		return fmt.Sprintf("added %s: %s", c.Image, c.New)
+	case c.New == "":
+		return fmt.Sprintf("removed %s: %s", c.Image, c.Old)
+	}
+
+	return fmt.Sprintf("updated %s: %s -> %s", c.Image, c.Old, c.New)
+}
+
+// Returns a lockfile for the external images of the given Containerfile,
+// along with what changed from the given lockfile, which may be nil. Images
+// which are already locked keep their digests unless Refresh is set, while
+// images which the Containerfile no longer uses are removed. The given
+// lockfile is not modified.
+func Update(ctx context.Context, cf *containerfile.Containerfile, lock *Lockfile, opts UpdateOpts) (*Lockfile, []Change, error) {
+	if lock == nil {
+		lock = New()
+	}
+
+	resolver := opts.Resolver
+	if resolver == nil {
+		resolver = &SkopeoResolver{}
+	}
+
+	out := New()
+	changes := []Change{}
+	images := cf.ExternalImages()
+
+	for _, image := range images {
+		old, locked := lock.Digest(image)
+
+		if locked && !opts.Refresh {
+			out.Set(image, old)
+			continue
+		}
+
+		digest, err := resolver.Resolve(ctx, image)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		out.Set(image, digest)
+
+		if digest != old {
+			changes = append(changes, Change{Image: image, Old: old, New: digest})
+		}
+	}
+
+	for _, img := range lock.Images {
+		if _, ok := out.Digest(img.Image); !ok {
+			changes = append(changes, Change{Image: img.Image, Old: img.Digest})
+		}
+	}
+
+	return out, changes, nil
+}
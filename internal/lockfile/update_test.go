@@ -0,0 +1,144 @@
+package lockfile
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdate(t *testing.T) {
+	cf := mustParse(t, `FROM quay.io/centos/stream:9 AS builder
+COPY --from=docker.io/library/golang:1.23 /usr/local/go /usr/local/go
+FROM quay.io/fedora/fedora:40
+COPY --from=builder /app /app
+`)
+
+	newCentosDigest := "sha256:4444444444444444444444444444444444444444444444444444444444444444"
+
+	existing := New()
+	existing.Set("quay.io/centos/stream:9", centosDigest)
+	existing.Set("quay.io/fedora/fedora:39", fedoraDigest)
+
+	testCases := []struct {
+		name             string
+		lock             *Lockfile
+		refresh          bool
+		expected         []LockedImage
+		expectedChanges  []string
+		expectedResolved []string
+	}{
+		{
+			name: "New lockfile",
+			expected: []LockedImage{
+				{Image: "docker.io/library/golang:1.23", Digest: golangDigest},
+				{Image: "quay.io/centos/stream:9", Digest: newCentosDigest},
+				{Image: "quay.io/fedora/fedora:40", Digest: fedoraDigest},
+			},
+			expectedChanges: []string{
+				"added docker.io/library/golang:1.23: " + golangDigest,
+				"added quay.io/centos/stream:9: " + newCentosDigest,
+				"added quay.io/fedora/fedora:40: " + fedoraDigest,
+			},
+			expectedResolved: []string{"docker.io/library/golang:1.23", "quay.io/centos/stream:9", "quay.io/fedora/fedora:40"},
+		},
+		{
+			name: "Only missing images are resolved",
+			lock: existing,
+			expected: []LockedImage{
+				{Image: "docker.io/library/golang:1.23", Digest: golangDigest},
+				{Image: "quay.io/centos/stream:9", Digest: centosDigest},
+				{Image: "quay.io/fedora/fedora:40", Digest: fedoraDigest},
+			},
+			expectedChanges: []string{
+				"added docker.io/library/golang:1.23: " + golangDigest,
+				"added quay.io/fedora/fedora:40: " + fedoraDigest,
+				"removed quay.io/fedora/fedora:39: " + fedoraDigest,
+			},
+			expectedResolved: []string{"docker.io/library/golang:1.23", "quay.io/fedora/fedora:40"},
+		},
+		{
+			name:    "Refresh",
+			lock:    existing,
+			refresh: true,
+			expected: []LockedImage{
+				{Image: "docker.io/library/golang:1.23", Digest: golangDigest},
+				{Image: "quay.io/centos/stream:9", Digest: newCentosDigest},
+				{Image: "quay.io/fedora/fedora:40", Digest: fedoraDigest},
+			},
+			expectedChanges: []string{
+				"added docker.io/library/golang:1.23: " + golangDigest,
+				"updated quay.io/centos/stream:9: " + centosDigest + " -> " + newCentosDigest,
+				"added quay.io/fedora/fedora:40: " + fedoraDigest,
+				"removed quay.io/fedora/fedora:39: " + fedoraDigest,
+			},
+			expectedResolved: []string{"docker.io/library/golang:1.23", "quay.io/centos/stream:9", "quay.io/fedora/fedora:40"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			resolver := &FakeResolver{
+				Digests: map[string]string{
+					"docker.io/library/golang:1.23": golangDigest,
+					"quay.io/centos/stream:9":       newCentosDigest,
+					"quay.io/fedora/fedora:40":      fedoraDigest,
+				},
+			}
+
+			lock, changes, err := Update(context.Background(), cf, testCase.lock, UpdateOpts{Resolver: resolver, Refresh: testCase.refresh})
+			require.NoError(t, err)
+
+			assert.Equal(t, Version, lock.Version)
+			assert.Equal(t, testCase.expected, lock.Images)
+			assert.Equal(t, testCase.expectedResolved, resolver.Resolved())
+
+			changeStrings := []string{}
+			for _, change := range changes {
+				changeStrings = append(changeStrings, change.String())
+			}
+
+			assert.Equal(t, testCase.expectedChanges, changeStrings)
+		})
+	}
+
+	// The existing lockfile is not modified.
+	assert.Equal(t, []LockedImage{
+		{Image: "quay.io/centos/stream:9", Digest: centosDigest},
+		{Image: "quay.io/fedora/fedora:39", Digest: fedoraDigest},
+	}, existing.Images)
+}
+
+func TestUpdateResolveError(t *testing.T) {
+	cf := mustParse(t, "FROM quay.io/fedora/fedora:40\n")
+
+	_, _, err := Update(context.Background(), cf, nil, UpdateOpts{Resolver: &FakeResolver{}})
+	assert.EqualError(t, err, "could not resolve quay.io/fedora/fedora:40: not found")
+}
+
+func TestSkopeoResolver(t *testing.T) {
+	manifest := `{"schemaVersion": 2, "mediaType": "application/vnd.oci.image.index.v1+json", "manifests": []}`
+
+	executor := &command.FakeExecutor{
+		Responses: map[string]command.FakeResponse{
+			"skopeo inspect --authfile /run/auth.json --raw docker://quay.io/fedora/fedora:40": {Stdout: manifest},
+			"skopeo inspect --authfile /run/auth.json --raw docker://quay.io/fedora/fedora:99": {
+				Stderr:   "manifest unknown\n",
+				ExitCode: 1,
+			},
+		},
+	}
+
+	resolver := &SkopeoResolver{Executor: executor, Authfile: "/run/auth.json"}
+
+	digest, err := resolver.Resolve(context.Background(), "quay.io/fedora/fedora:40")
+	require.NoError(t, err)
+	// The digest of the raw manifest, as computed by sha256sum.
+	assert.Equal(t, "sha256:0de3ff5142db66fc26e560dd9a0f9c7e44db177caa5632c534a536a2c9337c5b", digest)
+
+	_, err = resolver.Resolve(context.Background(), "quay.io/fedora/fedora:99")
+	assert.ErrorContains(t, err, "could not inspect quay.io/fedora/fedora:99")
+	assert.ErrorContains(t, err, "manifest unknown")
+}
@@ -0,0 +1,150 @@
+package plan
+
+import (
+	"path/filepath"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+)
+
+// The plan state key which the pushed image digest is stored under.
+const DigestKey = "digest"
+
+// Describes the typical sequence of extracting the CA bundles, logging into
+// the registry, building an image with buildah, tagging it, pushing it, and
+// capturing the pushed digest.
+type BuildahBuildAndPush struct {
+	// If set, the CA bundles are extracted into this directory before the
+	// build, e.g., /etc/pki/ca-trust/extracted.
+	CADestRoot string
+	// If set, a login step is performed before the build and push.
+	Login *command.BuildahLogin
+	// The image is built with the Tag given here.
+	Build *command.BuildahBuild
+	// Additional tags to apply to the built image.
+	Tags []string
+	// The Image field defaults to the build tag if empty. If Digestfile is
+	// set, the digest is captured into the plan state under DigestKey.
+	Push *command.BuildahPush
+	// If set, the login and push steps are executed according to this policy.
+	NetworkPolicy *command.Policy
+}
+
+// Constructs the plan.
+func (b *BuildahBuildAndPush) Plan() *Plan {
+	p := New()
+
+	buildInputs := []string{}
+
+	if b.CADestRoot != "" {
+		opensslBundle := filepath.Join(b.CADestRoot, "openssl/ca-bundle.trust.crt")
+		pemBundle := filepath.Join(b.CADestRoot, "pem/tls-ca-bundle.pem")
+
+		p.Add(
+			&Step{
+				Name: "extract-ca-openssl",
+				Command: &command.P11KitExtract{
+					Format:    "openssl-bundle",
+					Filter:    "certificates",
+					Overwrite: true,
+					Comment:   true,
+					DestPath:  opensslBundle,
+				},
+				Outputs: []string{opensslBundle},
+			},
+			&Step{
+				Name: "extract-ca-pem",
+				Command: &command.P11KitExtract{
+					Format:    "pem-bundle",
+					Filter:    "ca-anchors",
+					Overwrite: true,
+					Comment:   true,
+					Purpose:   "server-auth",
+					DestPath:  pemBundle,
+				},
+				Outputs: []string{pemBundle},
+			},
+		)
+
+		buildInputs = append(buildInputs, opensslBundle, pemBundle)
+	}
+
+	if b.Login != nil {
+		loginStep := &Step{
+			Name:    "login",
+			Command: b.Login,
+			Policy:  b.NetworkPolicy,
+		}
+
+		if b.Login.Authfile != "" {
+			loginStep.Outputs = []string{b.Login.Authfile}
+		}
+
+		p.Add(loginStep)
+	}
+
+	image := b.Build.Tag
+	imageArtifact := "image:" + image
+
+	if b.Build.Authfile != "" {
+		buildInputs = append(buildInputs, b.Build.Authfile)
+	}
+
+	p.Add(&Step{
+		Name:    "build",
+		Command: b.Build,
+		Inputs:  buildInputs,
+		Outputs: []string{imageArtifact},
+	})
+
+	if len(b.Tags) != 0 {
+		p.Add(&Step{
+			Name: "tag",
+			Command: &command.BuildahTag{
+				Image: image,
+				Tags:  b.Tags,
+			},
+			Inputs: []string{imageArtifact},
+		})
+	}
+
+	if b.Push == nil {
+		return p
+	}
+
+	push := *b.Push
+	if push.Image == "" {
+		push.Image = image
+	}
+
+	pushStep := &Step{
+		Name:    "push",
+		Command: &push,
+		Policy:  b.NetworkPolicy,
+		Inputs:  []string{imageArtifact},
+	}
+
+	if len(b.Tags) != 0 {
+		pushStep.DependsOn = []string{"tag"}
+	}
+
+	if push.Authfile != "" {
+		pushStep.Inputs = append(pushStep.Inputs, push.Authfile)
+	}
+
+	if push.Digestfile != "" {
+		pushStep.Outputs = []string{push.Digestfile}
+	}
+
+	p.Add(pushStep)
+
+	if push.Digestfile != "" {
+		p.Add(&Step{
+			Name:        "capture-digest",
+			Description: "read the pushed image digest from " + push.Digestfile,
+			Action:      CaptureDigest(push.Digestfile, DigestKey),
+			Inputs:      []string{push.Digestfile},
+		})
+	}
+
+	return p
+}
@@ -0,0 +1,176 @@
+package plan
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+)
+
+// Options for executing a plan.
+type ExecuteOpts struct {
+	// Defaults to running each command as a local process.
+	Executor command.Executor
+	// If set, each step's combined output is written to
+	// <LogDir>/<NN>-<step>.log.
+	LogDir string
+	// If set, each step's output is streamed here as it runs.
+	Out io.Writer
+}
+
+// The outcome of a single step.
+type StepResult struct {
+	Name string
+	// The rendered command, if this was a command step.
+	Command string
+	// The combined stdout and stderr of the step.
+	Output   []byte
+	Err      error
+	ExitCode int
+	// Each attempt made at running the command.
+	Attempts []command.Attempt
+	// Set when the step was not run because a previous step failed.
+	Skipped  bool
+	Duration time.Duration
+	LogFile  string
+}
+
+// The outcome of executing a plan.
+type Report struct {
+	Steps []*StepResult
+	State *State
+}
+
+// Returns the result of the step which failed, if any.
+func (r *Report) Failed() *StepResult {
+	for _, step := range r.Steps {
+		if step.Err != nil {
+			return step
+		}
+	}
+
+	return nil
+}
+
+// Executes each step in dependency order. Once a step fails, each remaining
+// step is skipped and the error is returned alongside the report.
+func (p *Plan) Execute(ctx context.Context, opts ExecuteOpts) (*Report, error) {
+	ordered, err := p.Order()
+	if err != nil {
+		return nil, err
+	}
+
+	executor := opts.Executor
+	if executor == nil {
+		executor = command.ExecExecutor{}
+	}
+
+	if opts.LogDir != "" {
+		if err := os.MkdirAll(opts.LogDir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &Report{State: newState()}
+
+	var failed *StepResult
+
+	for i, step := range ordered {
+		result := &StepResult{Name: step.Name}
+		report.Steps = append(report.Steps, result)
+
+		if failed != nil {
+			result.Skipped = true
+			continue
+		}
+
+		if step.Command != nil {
+			result.Command = step.Command.Command().String()
+		}
+
+		if opts.Out != nil {
+			fmt.Fprintf(opts.Out, "==> %s\n", describe(step, result))
+		}
+
+		buf := &bytes.Buffer{}
+		var out io.Writer = buf
+		if opts.Out != nil {
+			out = io.MultiWriter(buf, opts.Out)
+		}
+
+		start := time.Now()
+
+		if step.Command != nil {
+			cmd := step.Command.Command()
+			if step.Policy != nil {
+				// Command() may return the caller's own Command, so the policy
+				// is attached to a copy of it.
+				copied := *cmd
+				cmd = copied.WithPolicy(step.Policy)
+			}
+
+			result.Attempts, result.Err = command.ExecuteWithPolicy(ctx, executor, cmd, out, out)
+		} else {
+			result.Err = step.Action(ctx, report.State)
+		}
+
+		result.Duration = time.Since(start)
+		result.Output = buf.Bytes()
+		result.ExitCode = command.ExitCode(result.Err)
+
+		if opts.LogDir != "" {
+			result.LogFile = filepath.Join(opts.LogDir, fmt.Sprintf("%02d-%s.log", i+1, step.Name))
+			if err := os.WriteFile(result.LogFile, result.Output, 0o644); err != nil {
+				return report, err
+			}
+		}
+
+		if result.Err != nil {
+			failed = result
+		}
+	}
+
+	if failed != nil {
+		return report, fmt.Errorf("step %q failed: %w", failed.Name, failed.Err)
+	}
+
+	return report, nil
+}
+
+// Describes the step being run for the streamed output.
+func describe(step *Step, result *StepResult) string {
+	if result.Command != "" {
+		return fmt.Sprintf("%s: %s", step.Name, result.Command)
+	}
+
+	if step.Description != "" {
+		return fmt.Sprintf("%s: %s", step.Name, step.Description)
+	}
+
+	return step.Name
+}
+
+// Returns an action which reads the digest written by buildah push
+// --digestfile into the plan state under the given key.
+func CaptureDigest(digestfile, key string) func(context.Context, *State) error {
+	return func(_ context.Context, state *State) error {
+		digest, err := os.ReadFile(digestfile)
+		if err != nil {
+			return fmt.Errorf("could not read digestfile: %w", err)
+		}
+
+		trimmed := strings.TrimSpace(string(digest))
+		if !strings.HasPrefix(trimmed, "sha256:") {
+			return fmt.Errorf("digestfile %s does not contain a sha256 digest: %q", digestfile, trimmed)
+		}
+
+		state.Set(key, trimmed)
+		return nil
+	}
+}
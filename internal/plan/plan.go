@@ -0,0 +1,240 @@
+package plan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+)
+
+// A single step within a plan. Exactly one of Command or Action must be set.
+type Step struct {
+	// A unique name for this step.
+	Name string
+	// A short, human-readable description shown in dry-run output.
+	Description string
+	// The command to execute.
+	Command command.Commander
+	// If set, the command is executed according to this policy, e.g., to
+	// retry a push against a flaky registry.
+	Policy *command.Policy
+	// Go code to run instead of a command, such as reading a file produced by a
+	// previous step into the plan state.
+	Action func(ctx context.Context, state *State) error
+	// The names of steps which must complete before this one.
+	DependsOn []string
+	// Artifacts (usually file paths) which this step consumes. If another step
+	// declares one of these as an output, this step depends upon it.
+	Inputs []string
+	// Artifacts (usually file paths) which this step produces.
+	Outputs []string
+}
+
+// Holds values produced by Action steps so that they may be consumed by later
+// steps or inspected once the plan has finished.
+type State struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func newState() *State {
+	return &State{values: map[string]string{}}
+}
+
+func (s *State) Set(key, val string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.values[key] = val
+}
+
+func (s *State) Get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	val, ok := s.values[key]
+	return val, ok
+}
+
+// A graph of steps. Steps are ordered by their dependencies; steps which do
+// not depend upon one another retain the order in which they were added.
+type Plan struct {
+	Steps []*Step
+}
+
+// Returns a new plan with the given steps.
+func New(steps ...*Step) *Plan {
+	return &Plan{Steps: steps}
+}
+
+// Adds the given steps to the plan.
+func (p *Plan) Add(steps ...*Step) {
+	p.Steps = append(p.Steps, steps...)
+}
+
+// Returns the names of the steps which the given step depends upon, both
+// explicitly and through its inputs.
+func (p *Plan) dependencies(step *Step, producers map[string]string) []string {
+	out := []string{}
+	seen := map[string]bool{}
+
+	add := func(name string) {
+		if !seen[name] && name != step.Name {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+
+	for _, dep := range step.DependsOn {
+		add(dep)
+	}
+
+	for _, input := range step.Inputs {
+		if producer, ok := producers[input]; ok {
+			add(producer)
+		}
+	}
+
+	return out
+}
+
+// Validates the plan and returns its steps in dependency order.
+func (p *Plan) Order() ([]*Step, error) {
+	byName := map[string]*Step{}
+	producers := map[string]string{}
+	errs := []error{}
+
+	for _, step := range p.Steps {
+		if step.Name == "" {
+			errs = append(errs, fmt.Errorf("step name is required"))
+			continue
+		}
+
+		if _, ok := byName[step.Name]; ok {
+			errs = append(errs, fmt.Errorf("duplicate step name %q", step.Name))
+		}
+
+		byName[step.Name] = step
+
+		if (step.Command == nil) == (step.Action == nil) {
+			errs = append(errs, fmt.Errorf("step %q: exactly one of command or action must be set", step.Name))
+		}
+
+		for _, output := range step.Outputs {
+			if producer, ok := producers[output]; ok {
+				errs = append(errs, fmt.Errorf("step %q: output %q is already produced by step %q", step.Name, output, producer))
+			}
+
+			producers[output] = step.Name
+		}
+	}
+
+	deps := map[string][]string{}
+
+	for _, step := range p.Steps {
+		deps[step.Name] = p.dependencies(step, producers)
+
+		for _, dep := range deps[step.Name] {
+			if _, ok := byName[dep]; !ok {
+				errs = append(errs, fmt.Errorf("step %q depends upon unknown step %q", step.Name, dep))
+			}
+		}
+	}
+
+	if len(errs) != 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	// Repeatedly take the first step in insertion order whose dependencies are
+	// all satisfied so that the ordering is deterministic.
+	out := []*Step{}
+	done := map[string]bool{}
+
+	for len(out) < len(p.Steps) {
+		progressed := false
+
+		for _, step := range p.Steps {
+			if done[step.Name] || !allDone(deps[step.Name], done) {
+				continue
+			}
+
+			done[step.Name] = true
+			out = append(out, step)
+			progressed = true
+			break
+		}
+
+		if !progressed {
+			remaining := []string{}
+			for _, step := range p.Steps {
+				if !done[step.Name] {
+					remaining = append(remaining, step.Name)
+				}
+			}
+
+			return nil, fmt.Errorf("dependency cycle between steps: %s", strings.Join(remaining, ", "))
+		}
+	}
+
+	return out, nil
+}
+
+// Writes a human-readable description of what the plan would do without
+// executing anything.
+func (p *Plan) DryRun(w io.Writer) error {
+	ordered, err := p.Order()
+	if err != nil {
+		return err
+	}
+
+	producers := map[string]string{}
+	for _, step := range p.Steps {
+		for _, output := range step.Outputs {
+			producers[output] = step.Name
+		}
+	}
+
+	for i, step := range ordered {
+		header := fmt.Sprintf("%d. %s", i+1, step.Name)
+
+		if deps := p.dependencies(step, producers); len(deps) != 0 {
+			header = fmt.Sprintf("%s (after: %s)", header, strings.Join(deps, ", "))
+		}
+
+		fmt.Fprintln(w, header)
+
+		if step.Description != "" {
+			fmt.Fprintf(w, "   # %s\n", step.Description)
+		}
+
+		if step.Command != nil {
+			fmt.Fprintf(w, "   $ %s\n", step.Command.Command())
+		} else {
+			fmt.Fprintln(w, "   (action)")
+		}
+
+		if len(step.Inputs) != 0 {
+			fmt.Fprintf(w, "   inputs: %s\n", strings.Join(step.Inputs, ", "))
+		}
+
+		if len(step.Outputs) != 0 {
+			fmt.Fprintf(w, "   outputs: %s\n", strings.Join(step.Outputs, ", "))
+		}
+	}
+
+	return nil
+}
+
+func allDone(names []string, done map[string]bool) bool {
+	for _, name := range names {
+		if !done[name] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,266 @@
+package plan
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func literalStep(name string, argv ...string) *Step {
+	return &Step{Name: name, Command: command.CommandLiteral(argv)}
+}
+
+func TestOrder(t *testing.T) {
+	testCases := []struct {
+		name        string
+		steps       []*Step
+		expected    []string
+		errContains string
+	}{
+		{
+			name: "Insertion order without dependencies",
+			steps: []*Step{
+				literalStep("a", "true"),
+				literalStep("b", "true"),
+			},
+			expected: []string{"a", "b"},
+		},
+		{
+			name: "Explicit and implicit dependencies",
+			steps: []*Step{
+				{Name: "push", Command: command.CommandLiteral{"push"}, Inputs: []string{"image"}, Outputs: []string{"digestfile"}},
+				{Name: "digest", Command: command.CommandLiteral{"cat"}, Inputs: []string{"digestfile"}},
+				{Name: "build", Command: command.CommandLiteral{"build"}, DependsOn: []string{"login"}, Outputs: []string{"image"}},
+				{Name: "login", Command: command.CommandLiteral{"login"}},
+			},
+			expected: []string{"login", "build", "push", "digest"},
+		},
+		{
+			name: "Cycle",
+			steps: []*Step{
+				{Name: "a", Command: command.CommandLiteral{"a"}, DependsOn: []string{"b"}},
+				{Name: "b", Command: command.CommandLiteral{"b"}, DependsOn: []string{"a"}},
+			},
+			errContains: "dependency cycle between steps: a, b",
+		},
+		{
+			name: "Unknown dependency",
+			steps: []*Step{
+				{Name: "a", Command: command.CommandLiteral{"a"}, DependsOn: []string{"missing"}},
+			},
+			errContains: `step "a" depends upon unknown step "missing"`,
+		},
+		{
+			name: "Duplicate output",
+			steps: []*Step{
+				{Name: "a", Command: command.CommandLiteral{"a"}, Outputs: []string{"file"}},
+				{Name: "b", Command: command.CommandLiteral{"b"}, Outputs: []string{"file"}},
+			},
+			errContains: `output "file" is already produced by step "a"`,
+		},
+		{
+			name: "Both command and action",
+			steps: []*Step{
+				{Name: "a", Command: command.CommandLiteral{"a"}, Action: func(context.Context, *State) error { return nil }},
+			},
+			errContains: "exactly one of command or action must be set",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ordered, err := New(testCase.steps...).Order()
+			if testCase.errContains != "" {
+				assert.ErrorContains(t, err, testCase.errContains)
+				return
+			}
+
+			require.NoError(t, err)
+
+			names := []string{}
+			for _, step := range ordered {
+				names = append(names, step.Name)
+			}
+
+			assert.Equal(t, testCase.expected, names)
+		})
+	}
+}
+
+func newBuildAndPush(dir string) *BuildahBuildAndPush {
+	authfile := filepath.Join(dir, "auth.json")
+
+	return &BuildahBuildAndPush{
+		CADestRoot: "/etc/pki/ca-trust/extracted",
+		Login: &command.BuildahLogin{
+			Authfile: authfile,
+			Registry: "quay.io",
+		},
+		Build: &command.BuildahBuild{
+			BuildOpts: command.BuildOpts{
+				Authfile: authfile,
+				File:     "Containerfile",
+				Tag:      "quay.io/org/image:latest",
+			},
+		},
+		Tags: []string{"quay.io/org/image:v1"},
+		Push: &command.BuildahPush{
+			Authfile:   authfile,
+			Digestfile: filepath.Join(dir, "digestfile"),
+		},
+	}
+}
+
+func TestBuildahBuildAndPush(t *testing.T) {
+	dir := t.TempDir()
+	bp := newBuildAndPush(dir)
+	digestfile := bp.Push.Digestfile
+
+	fake := &command.FakeExecutor{
+		Responses: map[string]command.FakeResponse{
+			"buildah push": {
+				Stdout: "Writing manifest to image destination\n",
+				Func: func(context.Context, *command.Command) error {
+					return os.WriteFile(digestfile, []byte("sha256:abcd\n"), 0o644)
+				},
+			},
+		},
+	}
+
+	logDir := filepath.Join(dir, "logs")
+
+	report, err := bp.Plan().Execute(context.Background(), ExecuteOpts{
+		Executor: fake,
+		LogDir:   logDir,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"p11-kit extract --filter certificates --format openssl-bundle --comment --overwrite /etc/pki/ca-trust/extracted/openssl/ca-bundle.trust.crt",
+		"p11-kit extract --filter ca-anchors --format pem-bundle --purpose server-auth --comment --overwrite /etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
+		"buildah login --authfile " + dir + "/auth.json quay.io",
+		"buildah build --authfile " + dir + "/auth.json --file Containerfile --tag quay.io/org/image:latest .",
+		"buildah tag quay.io/org/image:latest quay.io/org/image:v1",
+		"buildah push --authfile " + dir + "/auth.json --digestfile " + digestfile + " quay.io/org/image:latest",
+	}, fake.Executed())
+
+	digest, ok := report.State.Get(DigestKey)
+	assert.True(t, ok)
+	assert.Equal(t, "sha256:abcd", digest)
+	assert.Nil(t, report.Failed())
+
+	pushLog, err := os.ReadFile(filepath.Join(logDir, "06-push.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "Writing manifest to image destination\n", string(pushLog))
+}
+
+func TestExecuteShortCircuits(t *testing.T) {
+	bp := newBuildAndPush(t.TempDir())
+
+	fake := &command.FakeExecutor{
+		Responses: map[string]command.FakeResponse{
+			"buildah build": {Stderr: "Error: no such file\n", ExitCode: 125},
+		},
+	}
+
+	out := &strings.Builder{}
+
+	report, err := bp.Plan().Execute(context.Background(), ExecuteOpts{Executor: fake, Out: out})
+	assert.ErrorContains(t, err, `step "build" failed: exit status 125`)
+
+	failed := report.Failed()
+	require.NotNil(t, failed)
+	assert.Equal(t, "build", failed.Name)
+	assert.Equal(t, 125, failed.ExitCode)
+	assert.Equal(t, "Error: no such file\n", string(failed.Output))
+
+	skipped := []string{}
+	for _, step := range report.Steps {
+		if step.Skipped {
+			skipped = append(skipped, step.Name)
+		}
+	}
+
+	assert.Equal(t, []string{"tag", "push", "capture-digest"}, skipped)
+	assert.Len(t, fake.Executed(), 4)
+	assert.Contains(t, out.String(), "==> build: buildah build")
+}
+
+func TestDryRun(t *testing.T) {
+	bp := newBuildAndPush("/tmp")
+
+	out := &strings.Builder{}
+	require.NoError(t, bp.Plan().DryRun(out))
+
+	expected := `1. extract-ca-openssl
+   $ p11-kit extract --filter certificates --format openssl-bundle --comment --overwrite /etc/pki/ca-trust/extracted/openssl/ca-bundle.trust.crt
+   outputs: /etc/pki/ca-trust/extracted/openssl/ca-bundle.trust.crt
+2. extract-ca-pem
+   $ p11-kit extract --filter ca-anchors --format pem-bundle --purpose server-auth --comment --overwrite /etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem
+   outputs: /etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem
+3. login
+   $ buildah login --authfile /tmp/auth.json quay.io
+   outputs: /tmp/auth.json
+4. build (after: extract-ca-openssl, extract-ca-pem, login)
+   $ buildah build --authfile /tmp/auth.json --file Containerfile --tag quay.io/org/image:latest .
+   inputs: /etc/pki/ca-trust/extracted/openssl/ca-bundle.trust.crt, /etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem, /tmp/auth.json
+   outputs: image:quay.io/org/image:latest
+5. tag (after: build)
+   $ buildah tag quay.io/org/image:latest quay.io/org/image:v1
+   inputs: image:quay.io/org/image:latest
+6. push (after: tag, build, login)
+   $ buildah push --authfile /tmp/auth.json --digestfile /tmp/digestfile quay.io/org/image:latest
+   inputs: image:quay.io/org/image:latest, /tmp/auth.json
+   outputs: /tmp/digestfile
+7. capture-digest (after: push)
+   # read the pushed image digest from /tmp/digestfile
+   (action)
+   inputs: /tmp/digestfile
+`
+
+	assert.Equal(t, expected, out.String())
+}
+
+func TestExecuteRetriesWithPolicy(t *testing.T) {
+	bp := newBuildAndPush(t.TempDir())
+	bp.NetworkPolicy = &command.Policy{Retries: 2, Backoff: time.Millisecond}
+
+	fake := &command.FakeExecutor{
+		Responses: map[string]command.FakeResponse{
+			"buildah push": {Stderr: "Error: 503 Service Unavailable\n", ExitCode: 125},
+		},
+	}
+
+	report, err := bp.Plan().Execute(context.Background(), ExecuteOpts{Executor: fake})
+	assert.ErrorContains(t, err, `step "push" failed: exit status 125`)
+
+	failed := report.Failed()
+	require.NotNil(t, failed)
+	assert.Len(t, failed.Attempts, 3)
+	assert.Equal(t, 3, strings.Count(string(failed.Output), "503 Service Unavailable"))
+
+	for _, step := range report.Steps {
+		if step.Name == "build" {
+			assert.Len(t, step.Attempts, 1)
+		}
+	}
+}
+
+func TestExecuteKeepsCommandPolicy(t *testing.T) {
+	policy := &command.Policy{Timeout: time.Minute}
+	cmd := command.CommandLiteral{"buildah", "push", "quay.io/org/image"}.Command().WithPolicy(policy)
+
+	p := New(&Step{Name: "push", Command: cmd, Policy: &command.Policy{Retries: 2}})
+
+	_, err := p.Execute(context.Background(), ExecuteOpts{Executor: &command.FakeExecutor{}})
+	require.NoError(t, err)
+
+	assert.Same(t, policy, cmd.Policy())
+}
@@ -0,0 +1,215 @@
+package sbom
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// The name recorded as the creator of generated documents.
+const toolName = "zacks-container-playground"
+
+// Options for rendering SPDX and CycloneDX documents.
+type DocumentOpts struct {
+	// The creation timestamp. Defaults to now.
+	Created time.Time
+}
+
+func (d DocumentOpts) created() string {
+	if d.Created.IsZero() {
+		return time.Now().UTC().Format(time.RFC3339)
+	}
+
+	return d.Created.UTC().Format(time.RFC3339)
+}
+
+// The name of the final image, falling back to its base image when untagged.
+func (i *Inventory) imageName() string {
+	if i.Final.Tag != "" {
+		return i.Final.Tag
+	}
+
+	return i.Final.BaseImage
+}
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string            `json:"name"`
+	SPDXID           string            `json:"SPDXID"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	Comment          string            `json:"comment,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// Renders the final image inventory as an SPDX 2.3 JSON document. The image
+// is described by the document and contains each declared package.
+func (i *Inventory) SPDX(opts DocumentOpts) ([]byte, error) {
+	name := i.imageName()
+
+	// Derive the namespace from the inventory so that the same Containerfile
+	// always yields the same namespace.
+	inventoryJSON, err := i.JSON()
+	if err != nil {
+		return nil, err
+	}
+
+	doc := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              name,
+		DocumentNamespace: fmt.Sprintf("https://spdx.org/spdxdocs/%s-%x", toolName, sha256.Sum256(inventoryJSON)),
+		CreationInfo: spdxCreationInfo{
+			Created:  opts.created(),
+			Creators: []string{"Tool: " + toolName},
+		},
+		Packages: []spdxPackage{
+			{
+				Name:             name,
+				SPDXID:           "SPDXRef-Image",
+				DownloadLocation: "NOASSERTION",
+				Comment:          fmt.Sprintf("Based on %s", i.Final.BaseImage),
+			},
+		},
+		Relationships: []spdxRelationship{
+			{SPDXElementID: "SPDXRef-DOCUMENT", RelationshipType: "DESCRIBES", RelatedSPDXElement: "SPDXRef-Image"},
+		},
+	}
+
+	for n, pkg := range i.Final.Packages {
+		id := fmt.Sprintf("SPDXRef-Package-%d", n+1)
+
+		doc.Packages = append(doc.Packages, spdxPackage{
+			Name:             pkg.Name,
+			SPDXID:           id,
+			VersionInfo:      pkg.Version,
+			DownloadLocation: "NOASSERTION",
+			Comment:          fmt.Sprintf("Declared as %q for %s", pkg.Declared, pkg.PackageManager),
+			ExternalRefs: []spdxExternalRef{
+				{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: pkg.PURL},
+			},
+		})
+
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SPDXElementID:      "SPDXRef-Image",
+			RelationshipType:   "CONTAINS",
+			RelatedSPDXElement: id,
+		})
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+type cycloneDXDocument struct {
+	BOMFormat    string                `json:"bomFormat"`
+	SpecVersion  string                `json:"specVersion"`
+	Version      int                   `json:"version"`
+	Metadata     cycloneDXMetadata     `json:"metadata"`
+	Components   []cycloneDXComponent  `json:"components"`
+	Dependencies []cycloneDXDependency `json:"dependencies"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp string             `json:"timestamp"`
+	Tools     []cycloneDXTool    `json:"tools"`
+	Component cycloneDXComponent `json:"component"`
+}
+
+type cycloneDXTool struct {
+	Name string `json:"name"`
+}
+
+type cycloneDXComponent struct {
+	Type       string              `json:"type"`
+	BOMRef     string              `json:"bom-ref"`
+	Name       string              `json:"name"`
+	Version    string              `json:"version,omitempty"`
+	PURL       string              `json:"purl,omitempty"`
+	Properties []cycloneDXProperty `json:"properties,omitempty"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cycloneDXDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+// Renders the final image inventory as a CycloneDX 1.5 JSON document.
+func (i *Inventory) CycloneDX(opts DocumentOpts) ([]byte, error) {
+	image := cycloneDXComponent{
+		Type:   "container",
+		BOMRef: "image",
+		Name:   i.imageName(),
+		Properties: []cycloneDXProperty{
+			{Name: "baseImage", Value: i.Final.BaseImage},
+		},
+	}
+
+	doc := cycloneDXDocument{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.5",
+		Version:     1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: opts.created(),
+			Tools:     []cycloneDXTool{{Name: toolName}},
+			Component: image,
+		},
+		Components: []cycloneDXComponent{},
+	}
+
+	dependsOn := []string{}
+
+	for _, pkg := range i.Final.Packages {
+		doc.Components = append(doc.Components, cycloneDXComponent{
+			Type:    "library",
+			BOMRef:  pkg.PURL,
+			Name:    pkg.Name,
+			Version: pkg.Version,
+			PURL:    pkg.PURL,
+			Properties: []cycloneDXProperty{
+				{Name: "declared", Value: pkg.Declared},
+				{Name: "packageManager", Value: pkg.PackageManager},
+			},
+		})
+
+		dependsOn = append(dependsOn, pkg.PURL)
+	}
+
+	doc.Dependencies = []cycloneDXDependency{{Ref: image.BOMRef, DependsOn: dependsOn}}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
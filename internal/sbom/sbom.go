@@ -0,0 +1,313 @@
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+	"github.com/cheesesashimi/zacks-container-playground/internal/containerfile"
+)
+
+// A package which a Containerfile declares that it installs. This describes
+// intent; the version actually installed may differ when none was pinned.
+type Package struct {
+	// The package name, e.g., git.
+	Name string `json:"name"`
+	// The version, if one was pinned, including any epoch and release.
+	Version string `json:"version,omitempty"`
+	Arch    string `json:"arch,omitempty"`
+	// The package as it was given to the package manager, e.g.,
+	// git-2.43.0-1.fc40.x86_64.
+	Declared       string `json:"declared"`
+	PackageManager string `json:"packageManager"`
+	// A package URL (https://github.com/package-url/purl-spec).
+	PURL string `json:"purl"`
+}
+
+// A file or directory copied into a stage.
+type Artifact struct {
+	// The stage or image the artifact was copied from. Empty when it was copied
+	// from the build context.
+	From string `json:"from,omitempty"`
+	Src  string `json:"src"`
+	Dest string `json:"dest"`
+}
+
+// The declared contents of a single stage.
+type StageInventory struct {
+	Name  string `json:"name,omitempty"`
+	Index int    `json:"index"`
+	// The image given to FROM.
+	BaseImage string `json:"baseImage"`
+	// Set when the base image is a previous stage: its name, or its index if
+	// it is referred to by index.
+	BaseStage      string `json:"baseStage,omitempty"`
+	PackageManager string `json:"packageManager,omitempty"`
+	// The packages installed by this stage, not including those installed by
+	// a base stage.
+	Packages  []Package  `json:"packages"`
+	Artifacts []Artifact `json:"artifacts"`
+	base      *StageInventory
+}
+
+// The declared contents of the final image, i.e., the last stage along with
+// every stage it is based upon.
+type ImageInventory struct {
+	Tag string `json:"tag,omitempty"`
+	// The external image at the root of the final stage's FROM chain.
+	BaseImage      string     `json:"baseImage"`
+	PackageManager string     `json:"packageManager,omitempty"`
+	Packages       []Package  `json:"packages"`
+	Artifacts      []Artifact `json:"artifacts"`
+}
+
+// The declared package inventory of a Containerfile.
+type Inventory struct {
+	Stages []*StageInventory `json:"stages"`
+	Final  *ImageInventory   `json:"final"`
+}
+
+// Walks the stages of the given Containerfile and inventories the packages it
+// installs and the artifacts it copies. Typed install commands are read
+// directly while other RUN instructions are parsed and recognized where
+// possible. Nothing is pulled or scanned.
+func Analyze(cf *containerfile.Containerfile) (*Inventory, error) {
+	if len(cf.Stages) == 0 {
+		return nil, fmt.Errorf("containerfile has no stages")
+	}
+
+	inv := &Inventory{}
+	// Previous stages may be referred to by name or by index, e.g., FROM 0.
+	stages := map[string]*StageInventory{}
+
+	for i, stage := range cf.Stages {
+		si := &StageInventory{
+			Name:      stage.Name,
+			Index:     i,
+			BaseImage: stage.Image,
+			Packages:  []Package{},
+			Artifacts: []Artifact{},
+		}
+
+		if base, ok := stages[stage.Image]; ok {
+			si.BaseStage = stage.Image
+			si.PackageManager = base.PackageManager
+			si.base = base
+		}
+
+		for _, step := range stage.Steps {
+			si.addStep(step)
+		}
+
+		if si.PackageManager == "" && si.BaseStage == "" {
+			if pm, ok := command.DetectPackageManager(stage.Image); ok {
+				si.PackageManager = pm.Name()
+			}
+		}
+
+		inv.Stages = append(inv.Stages, si)
+
+		stages[strconv.Itoa(i)] = si
+		if stage.Name != "" {
+			stages[stage.Name] = si
+		}
+	}
+
+	inv.Final = finalImage(cf.Tag, inv.Stages[len(inv.Stages)-1])
+
+	return inv, nil
+}
+
+// Renders the inventory as JSON.
+func (i *Inventory) JSON() ([]byte, error) {
+	return json.MarshalIndent(i, "", "  ")
+}
+
+func finalImage(tag string, last *StageInventory) *ImageInventory {
+	chain := []*StageInventory{last}
+	for chain[0].base != nil {
+		chain = append([]*StageInventory{chain[0].base}, chain...)
+	}
+
+	final := &ImageInventory{
+		Tag:            tag,
+		BaseImage:      chain[0].BaseImage,
+		PackageManager: last.PackageManager,
+		Packages:       []Package{},
+		Artifacts:      []Artifact{},
+	}
+
+	for _, si := range chain {
+		final.Packages = append(final.Packages, si.Packages...)
+		final.Artifacts = append(final.Artifacts, si.Artifacts...)
+	}
+
+	return final
+}
+
+func (s *StageInventory) addStep(step containerfile.ContainerfileStep) {
+	switch st := containerfile.UnwrapStep(step).(type) {
+	case *containerfile.CommandRunStep:
+		s.addCommand(st.Command)
+	case *containerfile.MultiCommandRunStep:
+		for _, cmd := range st.Commands {
+			s.addCommand(cmd)
+		}
+	case *containerfile.RunStep:
+		s.addShell(st.Command)
+	case *containerfile.MultiRunStep:
+		for _, cmd := range st.Commands {
+			s.addShell(cmd)
+		}
+	case *containerfile.HeredocRunStep:
+		// Scripts run by something other than the shell, e.g., python3, are
+		// skipped.
+		if st.Command == "" {
+			// As the shell does, line continuations are joined before the
+			// script is split into commands.
+			script := strings.ReplaceAll(st.Script, "\\\n", "")
+			for _, line := range strings.Split(script, "\n") {
+				s.addShell(line)
+			}
+		}
+	case *containerfile.CopyStep:
+		s.Artifacts = append(s.Artifacts, Artifact{From: st.From, Src: st.Src, Dest: st.Dest})
+	}
+}
+
+// Adds the packages installed by the given command, recognizing it from its
+// argv if it is not already a typed install command.
+func (s *StageInventory) addCommand(cmd command.Commander) {
+	if s.addInstall(cmd) {
+		return
+	}
+
+	argv, _, _ := cmd.Command().ArgvWithSecretRefs(func(name string) string { return "$" + name })
+	if recognized, ok := command.Recognize(argv); ok {
+		s.addInstall(recognized)
+	}
+}
+
+// Adds the packages installed by each command in the given RUN line. Commands
+// which cannot be parsed, such as pipelines, are skipped.
+func (s *StageInventory) addShell(line string) {
+	for _, segment := range containerfile.SplitAndAnd(line) {
+		cmd, err := command.ParseShellWords(segment)
+		if err != nil {
+			continue
+		}
+
+		s.addCommand(cmd)
+	}
+}
+
+func (s *StageInventory) addInstall(cmd command.Commander) bool {
+	var manager string
+	var packages []string
+
+	switch c := cmd.(type) {
+	case *command.DnfInstall:
+		manager, packages = "dnf", c.Packages
+	case *command.YumInstall:
+		manager, packages = "yum", c.Packages
+	case *command.MicrodnfInstall:
+		manager, packages = "microdnf", c.Packages
+	case *command.ZypperInstall:
+		manager, packages = "zypper", c.Packages
+	case *command.RpmOstreeInstall:
+		manager, packages = "rpm-ostree", c.Packages
+	case *command.AptGetInstall:
+		manager, packages = "apt-get", c.Packages
+	case *command.ApkAdd:
+		manager, packages = "apk", c.Packages
+	default:
+		return false
+	}
+
+	if s.PackageManager == "" {
+		s.PackageManager = manager
+	}
+
+	for _, declared := range packages {
+		pkg := parsePackage(manager, declared)
+
+		if !containsPackage(s.Packages, pkg) {
+			s.Packages = append(s.Packages, pkg)
+		}
+	}
+
+	return true
+}
+
+func containsPackage(packages []Package, pkg Package) bool {
+	for _, existing := range packages {
+		if existing.Declared == pkg.Declared && existing.PackageManager == pkg.PackageManager {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Matches name-[epoch:]version-release[.arch] where the version begins with a
+// digit and the release either begins with a digit or carries a dist tag,
+// e.g., .fc40 or .el9, so that names such as python3-devel or
+// java-17-openjdk are not mistaken for NEVRAs.
+var nevraPattern = regexp.MustCompile(`^(.+)-((?:\d+:)?\d[^-]*-(?:\d[^-]*?|[^-]*?\.[a-z]+\d[^-]*?))(?:\.(x86_64|aarch64|ppc64le|s390x|i686|armv7hl|noarch))?$`)
+
+// Matches name<op>version as accepted by apt-get, apk, and zypper, e.g.,
+// curl=8.5.0-2 or bash~5.2.
+var versionConstraintPattern = regexp.MustCompile(`^([^=<>~]+)(=|~|<=|>=|<|>)(.+)$`)
+
+// Parses the given declared package into its name, version, and arch.
+func parsePackage(manager, declared string) Package {
+	pkg := Package{Name: declared, Declared: declared, PackageManager: manager}
+
+	purlType := "rpm"
+
+	switch manager {
+	case "apt-get":
+		purlType = "deb"
+	case "apk":
+		purlType = "apk"
+	}
+
+	if purlType == "rpm" {
+		if m := nevraPattern.FindStringSubmatch(declared); m != nil {
+			pkg.Name, pkg.Version, pkg.Arch = m[1], m[2], m[3]
+		}
+	}
+
+	if pkg.Version == "" {
+		if m := versionConstraintPattern.FindStringSubmatch(declared); m != nil {
+			pkg.Name = m[1]
+			pkg.Version = strings.TrimPrefix(m[2], "=") + m[3]
+		}
+	}
+
+	// apt-get accepts name:arch.
+	if name, arch, ok := strings.Cut(pkg.Name, ":"); ok && purlType == "deb" {
+		pkg.Name, pkg.Arch = name, arch
+	}
+
+	pkg.PURL = purl(purlType, pkg)
+
+	return pkg
+}
+
+func purl(purlType string, pkg Package) string {
+	out := fmt.Sprintf("pkg:%s/%s", purlType, pkg.Name)
+
+	if pkg.Version != "" {
+		out = fmt.Sprintf("%s@%s", out, pkg.Version)
+	}
+
+	if pkg.Arch != "" {
+		out = fmt.Sprintf("%s?arch=%s", out, pkg.Arch)
+	}
+
+	return out
+}
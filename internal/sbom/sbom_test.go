@@ -0,0 +1,249 @@
+package sbom
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+	"github.com/cheesesashimi/zacks-container-playground/internal/containerfile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newContainerfile() *containerfile.Containerfile {
+	return &containerfile.Containerfile{
+		Tag: "quay.io/zack/app:latest",
+		Stages: []*containerfile.Stage{
+			{
+				Name:  "builder",
+				Image: "quay.io/centos/stream:9",
+				Steps: []containerfile.ContainerfileStep{
+					&containerfile.CommandRunStep{
+						Command: &command.DnfInstall{Yes: true, Packages: []string{"git", "golang-1.22.5-1.el9.x86_64"}},
+					},
+					&containerfile.RunStep{Command: "make all"},
+				},
+			},
+			{
+				Name:  "base",
+				Image: "quay.io/centos/stream:9",
+				Steps: []containerfile.ContainerfileStep{
+					&containerfile.RunStep{Command: "dnf install -y python3-devel && dnf clean all"},
+				},
+			},
+			{
+				Image: "base",
+				Steps: []containerfile.ContainerfileStep{
+					&containerfile.MultiCommandRunStep{
+						Commands: []command.Commander{
+							command.CommandLiteral{"dnf", "install", "-y", "jq", "git"},
+							command.CommandLiteral{"useradd", "zack"},
+						},
+					},
+					&containerfile.CopyStep{From: "builder", Src: "/src/app", Dest: "/usr/bin/app"},
+					&containerfile.RunStep{Command: "curl -L https://example.com | sh"},
+				},
+			},
+		},
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	inv, err := Analyze(newContainerfile())
+	require.NoError(t, err)
+
+	require.Len(t, inv.Stages, 3)
+
+	builder := inv.Stages[0]
+	assert.Equal(t, "dnf", builder.PackageManager)
+	assert.Equal(t, []Package{
+		{Name: "git", Declared: "git", PackageManager: "dnf", PURL: "pkg:rpm/git"},
+		{Name: "golang", Version: "1.22.5-1.el9", Arch: "x86_64", Declared: "golang-1.22.5-1.el9.x86_64", PackageManager: "dnf", PURL: "pkg:rpm/golang@1.22.5-1.el9?arch=x86_64"},
+	}, builder.Packages)
+
+	final := inv.Stages[2]
+	assert.Equal(t, "base", final.BaseStage)
+	assert.Equal(t, []string{"jq", "git"}, packageNames(final.Packages))
+	assert.Equal(t, []Artifact{{From: "builder", Src: "/src/app", Dest: "/usr/bin/app"}}, final.Artifacts)
+
+	assert.Equal(t, "quay.io/zack/app:latest", inv.Final.Tag)
+	assert.Equal(t, "quay.io/centos/stream:9", inv.Final.BaseImage)
+	assert.Equal(t, "dnf", inv.Final.PackageManager)
+	// The builder's packages do not end up in the final image.
+	assert.Equal(t, []string{"python3-devel", "jq", "git"}, packageNames(inv.Final.Packages))
+	assert.Equal(t, final.Artifacts, inv.Final.Artifacts)
+}
+
+func TestAnalyzeDetectsPackageManager(t *testing.T) {
+	inv, err := Analyze(&containerfile.Containerfile{
+		Stages: []*containerfile.Stage{{Image: "ubuntu:24.04"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "apt-get", inv.Final.PackageManager)
+	assert.Empty(t, inv.Final.Packages)
+}
+
+func TestAnalyzeHeredocs(t *testing.T) {
+	inv, err := Analyze(&containerfile.Containerfile{
+		Stages: []*containerfile.Stage{
+			{
+				Image: "quay.io/centos/stream:9",
+				Steps: []containerfile.ContainerfileStep{
+					containerfile.WithComment("Install the tools.", &containerfile.HeredocRunStep{
+						Script: "set -e\ndnf install -y git\nmicrodnf install -y jq && make\ndnf install -y \\\n  make \\\n  gcc\n",
+					}),
+					&containerfile.HeredocRunStep{Command: "python3", Script: "dnf install -y ignored\n"},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"git", "jq", "make", "gcc"}, packageNames(inv.Final.Packages))
+}
+
+func TestAnalyzeStageIndexes(t *testing.T) {
+	cf, err := containerfile.Parse(strings.NewReader(`FROM quay.io/centos/stream:9
+RUN dnf install -y git
+FROM 0
+RUN echo "a && dnf install -y bogus" && dnf install -y jq
+`))
+	require.NoError(t, err)
+
+	inv, err := Analyze(cf)
+	require.NoError(t, err)
+
+	require.Len(t, inv.Stages, 2)
+	assert.Equal(t, "0", inv.Stages[1].BaseStage)
+	assert.Equal(t, "dnf", inv.Stages[1].PackageManager)
+	// The quoted && is not split upon, so nothing is installed by the echo.
+	assert.Equal(t, []string{"jq"}, packageNames(inv.Stages[1].Packages))
+
+	assert.Equal(t, "quay.io/centos/stream:9", inv.Final.BaseImage)
+	assert.Equal(t, []string{"git", "jq"}, packageNames(inv.Final.Packages))
+}
+
+func TestAnalyzeNoStages(t *testing.T) {
+	_, err := Analyze(&containerfile.Containerfile{})
+	assert.Error(t, err)
+}
+
+func TestParsePackage(t *testing.T) {
+	testCases := []struct {
+		manager  string
+		declared string
+		expected Package
+	}{
+		{
+			manager:  "dnf",
+			declared: "python3-devel",
+			expected: Package{Name: "python3-devel", PURL: "pkg:rpm/python3-devel"},
+		},
+		{
+			manager:  "dnf",
+			declared: "kernel-2:6.9.7-200.fc40.noarch",
+			expected: Package{Name: "kernel", Version: "2:6.9.7-200.fc40", Arch: "noarch", PURL: "pkg:rpm/kernel@2:6.9.7-200.fc40?arch=noarch"},
+		},
+		{
+			manager:  "rpm-ostree",
+			declared: "vim-enhanced-9.1.393-1.fc40",
+			expected: Package{Name: "vim-enhanced", Version: "9.1.393-1.fc40", PURL: "pkg:rpm/vim-enhanced@9.1.393-1.fc40"},
+		},
+		{
+			manager:  "dnf",
+			declared: "java-17-openjdk",
+			expected: Package{Name: "java-17-openjdk", PURL: "pkg:rpm/java-17-openjdk"},
+		},
+		{
+			manager:  "dnf",
+			declared: "postgresql-15-server",
+			expected: Package{Name: "postgresql-15-server", PURL: "pkg:rpm/postgresql-15-server"},
+		},
+		{
+			manager:  "dnf",
+			declared: "java-17-openjdk-17.0.12.0.7-2.el9.x86_64",
+			expected: Package{Name: "java-17-openjdk", Version: "17.0.12.0.7-2.el9", Arch: "x86_64", PURL: "pkg:rpm/java-17-openjdk@17.0.12.0.7-2.el9?arch=x86_64"},
+		},
+		{
+			manager:  "dnf",
+			declared: "golang-1.22.5-git.fc40",
+			expected: Package{Name: "golang", Version: "1.22.5-git.fc40", PURL: "pkg:rpm/golang@1.22.5-git.fc40"},
+		},
+		{
+			manager:  "apt-get",
+			declared: "curl=8.5.0-2ubuntu10",
+			expected: Package{Name: "curl", Version: "8.5.0-2ubuntu10", PURL: "pkg:deb/curl@8.5.0-2ubuntu10"},
+		},
+		{
+			manager:  "apt-get",
+			declared: "libc6:amd64",
+			expected: Package{Name: "libc6", Arch: "amd64", PURL: "pkg:deb/libc6?arch=amd64"},
+		},
+		{
+			manager:  "apk",
+			declared: "bash~5.2",
+			expected: Package{Name: "bash", Version: "~5.2", PURL: "pkg:apk/bash@~5.2"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.declared, func(t *testing.T) {
+			testCase.expected.Declared = testCase.declared
+			testCase.expected.PackageManager = testCase.manager
+
+			assert.Equal(t, testCase.expected, parsePackage(testCase.manager, testCase.declared))
+		})
+	}
+}
+
+func TestDocuments(t *testing.T) {
+	inv, err := Analyze(newContainerfile())
+	require.NoError(t, err)
+
+	opts := DocumentOpts{Created: time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)}
+
+	t.Run("SPDX", func(t *testing.T) {
+		out, err := inv.SPDX(opts)
+		require.NoError(t, err)
+
+		doc := spdxDocument{}
+		require.NoError(t, json.Unmarshal(out, &doc))
+
+		assert.Equal(t, "SPDX-2.3", doc.SPDXVersion)
+		assert.Equal(t, "2024-07-01T12:00:00Z", doc.CreationInfo.Created)
+		// The image followed by each of its packages.
+		assert.Len(t, doc.Packages, 4)
+		assert.Equal(t, "quay.io/zack/app:latest", doc.Packages[0].Name)
+		assert.Equal(t, "pkg:rpm/jq", doc.Packages[2].ExternalRefs[0].ReferenceLocator)
+		assert.Equal(t, spdxRelationship{"SPDXRef-Image", "CONTAINS", "SPDXRef-Package-3"}, doc.Relationships[3])
+
+		again, err := inv.SPDX(opts)
+		require.NoError(t, err)
+		assert.Equal(t, string(out), string(again))
+	})
+
+	t.Run("CycloneDX", func(t *testing.T) {
+		out, err := inv.CycloneDX(opts)
+		require.NoError(t, err)
+
+		doc := cycloneDXDocument{}
+		require.NoError(t, json.Unmarshal(out, &doc))
+
+		assert.Equal(t, "1.5", doc.SpecVersion)
+		assert.Equal(t, "container", doc.Metadata.Component.Type)
+		assert.Equal(t, []string{"python3-devel", "jq", "git"}, []string{doc.Components[0].Name, doc.Components[1].Name, doc.Components[2].Name})
+		assert.Equal(t, []string{"pkg:rpm/python3-devel", "pkg:rpm/jq", "pkg:rpm/git"}, doc.Dependencies[0].DependsOn)
+	})
+}
+
+func packageNames(packages []Package) []string {
+	out := []string{}
+	for _, pkg := range packages {
+		out = append(out, pkg.Name)
+	}
+
+	return out
+}
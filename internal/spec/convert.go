@@ -0,0 +1,181 @@
+package spec
+
+import (
+	"fmt"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+	"github.com/cheesesashimi/zacks-container-playground/internal/containerfile"
+)
+
+// Converts the spec into a Containerfile.
+func (s *Spec) Containerfile() (*containerfile.Containerfile, error) {
+	cf := &containerfile.Containerfile{
+		Tag:    s.Tag,
+		Stages: []*containerfile.Stage{},
+	}
+
+	// Stages which use a previous stage as their base inherit its base image
+	// for package manager detection.
+	baseImages := map[string]string{}
+
+	for i, stage := range s.Stages {
+		baseImage := stage.Image
+		if inherited, ok := baseImages[stage.Image]; ok {
+			baseImage = inherited
+		}
+
+		if stage.Name != "" {
+			baseImages[stage.Name] = baseImage
+		}
+
+		steps := []containerfile.ContainerfileStep{}
+
+		for j, step := range stage.Steps {
+			converted, err := convertStep(step, baseImage)
+			if err != nil {
+				return nil, fmt.Errorf("stages[%d].steps[%d]: %w", i, j, err)
+			}
+
+			steps = append(steps, converted)
+		}
+
+		cf.Stages = append(cf.Stages, &containerfile.Stage{
+			Name:  stage.Name,
+			Image: stage.Image,
+			Steps: steps,
+		})
+	}
+
+	return cf, nil
+}
+
+// Converts a single step, using the base image to detect the package manager
+// if needed.
+func convertStep(step StepSpec, baseImage string) (containerfile.ContainerfileStep, error) {
+	switch {
+	case step.Workdir != "":
+		return containerfile.NewWorkDirStep(step.Workdir), nil
+	case step.User != "":
+		return containerfile.NewUserStep(step.User), nil
+	case step.Label != nil:
+		label := &containerfile.LabelStep{Key: step.Label.Key, Value: step.Label.Value}
+		if err := label.Validate(); err != nil {
+			return nil, err
+		}
+
+		return label, nil
+	case step.Copy != nil:
+		return &containerfile.CopyStep{
+			From:  step.Copy.From,
+			Src:   step.Copy.Src,
+			Dest:  step.Copy.Dest,
+			Flags: step.Copy.Flags,
+		}, nil
+	case step.Run != nil:
+		return convertRun(step.Run, baseImage)
+	}
+
+	return nil, fmt.Errorf("empty step")
+}
+
+func convertRun(run *RunSpec, baseImage string) (containerfile.ContainerfileStep, error) {
+	mounts := []*containerfile.Mount{}
+	for _, mount := range run.Mounts {
+		mounts = append(mounts, &containerfile.Mount{
+			Type:            mount.Type,
+			ID:              mount.ID,
+			From:            mount.From,
+			Source:          mount.Source,
+			Target:          mount.Target,
+			BindPropagation: mount.BindPropagation,
+			Opts:            mount.Opts,
+		})
+	}
+
+	if run.Shell != "" {
+		return &containerfile.RunStep{
+			Flags:   run.Flags,
+			Mounts:  mounts,
+			Command: run.Shell,
+		}, nil
+	}
+
+	cmds := []containerfile.Command{}
+
+	for i, cmd := range run.Commands {
+		converted, err := convertCommand(cmd, baseImage)
+		if err != nil {
+			return nil, fmt.Errorf("commands[%d]: %w", i, err)
+		}
+
+		cmds = append(cmds, converted...)
+	}
+
+	return &containerfile.MultiCommandRunStep{
+		Flags:    run.Flags,
+		Mounts:   mounts,
+		Commands: cmds,
+	}, nil
+}
+
+func convertCommand(cmd CommandSpec, baseImage string) ([]containerfile.Command, error) {
+	switch {
+	case len(cmd.Argv) != 0:
+		return []containerfile.Command{command.CommandLiteral(cmd.Argv)}, nil
+	case cmd.Install != nil:
+		pm, err := packageManager(cmd.Install.PackageManager, baseImage)
+		if err != nil {
+			return nil, err
+		}
+
+		return pm.Install(cmd.Install.Packages), nil
+	case cmd.Useradd != nil:
+		return []containerfile.Command{
+			&command.Useradd{
+				Name:       cmd.Useradd.Name,
+				UID:        cmd.Useradd.UID,
+				Groups:     cmd.Useradd.Groups,
+				Shell:      cmd.Useradd.Shell,
+				CreateHome: cmd.Useradd.CreateHome,
+			},
+		}, nil
+	case cmd.Chmod != nil:
+		return []containerfile.Command{
+			&command.Chmod{
+				Path:      cmd.Chmod.Path,
+				Mode:      cmd.Chmod.Mode,
+				Recursive: cmd.Chmod.Recursive,
+			},
+		}, nil
+	case cmd.Delete != nil:
+		return []containerfile.Command{
+			&command.Delete{
+				Path:      cmd.Delete.Path,
+				Recursive: cmd.Delete.Recursive,
+				Force:     cmd.Delete.Force,
+			},
+		}, nil
+	}
+
+	return nil, fmt.Errorf("empty command")
+}
+
+// Looks up the package manager by name, falling back to detecting it from the
+// base image.
+func packageManager(name, baseImage string) (command.PackageManager, error) {
+	if name != "" {
+		pm, ok := command.PackageManagerByName(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown package manager %q", name)
+		}
+
+		return pm, nil
+	}
+
+	pm, ok := command.DetectPackageManager(baseImage)
+	if !ok {
+		return nil, fmt.Errorf("could not detect package manager for %q, please specify one", baseImage)
+	}
+
+	return pm, nil
+}
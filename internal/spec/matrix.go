@@ -0,0 +1,396 @@
+package spec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// The name of the index file written alongside the generated Containerfiles.
+const MatrixIndexFile = "index.json"
+
+// Describes a template spec and the axes along which it should be varied.
+// Every combination of the axes produces a separate Containerfile.
+type Matrix struct {
+	Version string `json:"version" yaml:"version"`
+	// Used as the prefix for each variant name.
+	Name string `json:"name" yaml:"name"`
+	// Either an inline template or a path to one, relative to the matrix file.
+	Template     *Spec  `json:"template,omitempty" yaml:"template,omitempty"`
+	TemplateFile string `json:"template_file,omitempty" yaml:"template_file,omitempty"`
+	// The names of the stages which the base image axis applies to. If empty,
+	// it applies to every stage which is not based upon a previous stage.
+	BaseImageStages []string   `json:"base_image_stages,omitempty" yaml:"base_image_stages,omitempty"`
+	Axes            MatrixAxes `json:"axes" yaml:"axes"`
+}
+
+// The axes of the matrix. Any empty axis is ignored.
+type MatrixAxes struct {
+	BaseImages  []BaseImageAxis  `json:"base_images,omitempty" yaml:"base_images,omitempty"`
+	Arches      []string         `json:"arches,omitempty" yaml:"arches,omitempty"`
+	PackageSets []PackageSetAxis `json:"package_sets,omitempty" yaml:"package_sets,omitempty"`
+	BuildArgs   []BuildArgsAxis  `json:"build_args,omitempty" yaml:"build_args,omitempty"`
+}
+
+// A base image to substitute into the template. The package manager is
+// applied to every install command in the affected stages; if empty, it is
+// detected from the image.
+type BaseImageAxis struct {
+	// If empty, this is derived from the image name and tag.
+	Name           string `json:"name,omitempty" yaml:"name,omitempty"`
+	Image          string `json:"image" yaml:"image"`
+	PackageManager string `json:"package_manager,omitempty" yaml:"package_manager,omitempty"`
+}
+
+// A set of packages to add to every install command in the affected stages.
+type PackageSetAxis struct {
+	Name     string   `json:"name" yaml:"name"`
+	Packages []string `json:"packages" yaml:"packages"`
+}
+
+// A set of build args to use when building the variant.
+type BuildArgsAxis struct {
+	Name string            `json:"name" yaml:"name"`
+	Args map[string]string `json:"args" yaml:"args"`
+}
+
+// A single combination of the matrix axes.
+type Variant struct {
+	Name           string            `json:"name"`
+	File           string            `json:"file"`
+	Tag            string            `json:"tag,omitempty"`
+	BaseImage      string            `json:"base_image,omitempty"`
+	PackageManager string            `json:"package_manager,omitempty"`
+	Arch           string            `json:"arch,omitempty"`
+	Platform       string            `json:"platform,omitempty"`
+	PackageSet     string            `json:"package_set,omitempty"`
+	Packages       []string          `json:"packages,omitempty"`
+	BuildArgsName  string            `json:"build_args_name,omitempty"`
+	BuildArgs      map[string]string `json:"build_args,omitempty"`
+	// The spec for this variant.
+	Spec *Spec `json:"-"`
+}
+
+// The contents of the index file.
+type MatrixIndex struct {
+	Name     string     `json:"name"`
+	Variants []*Variant `json:"variants"`
+}
+
+// Reads and parses the matrix at the given path, loading the template file if
+// one is referenced.
+func ParseMatrixFile(matrixPath string) (*Matrix, error) {
+	data, err := os.ReadFile(matrixPath)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Matrix{}
+	if err := decodeStrict(data, m); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", matrixPath, err)
+	}
+
+	if m.TemplateFile != "" && m.Template == nil {
+		templatePath := m.TemplateFile
+		if !filepath.IsAbs(templatePath) {
+			templatePath = filepath.Join(filepath.Dir(matrixPath), templatePath)
+		}
+
+		m.Template, err = ParseFile(templatePath)
+		if err != nil {
+			return nil, err
+		}
+
+		// The template has been resolved, so the matrix no longer refers to it.
+		m.TemplateFile = ""
+	}
+
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid matrix %s: %w", matrixPath, err)
+	}
+
+	return m, nil
+}
+
+// Validates the matrix and its template.
+func (m *Matrix) Validate() error {
+	errs := []error{}
+
+	if m.Version != CurrentVersion {
+		errs = append(errs, fmt.Errorf("version: unsupported version %q, expected %q", m.Version, CurrentVersion))
+	}
+
+	if m.Name == "" {
+		errs = append(errs, fmt.Errorf("name: name is required"))
+	}
+
+	if (m.Template == nil) == (m.TemplateFile == "") {
+		errs = append(errs, fmt.Errorf("template: exactly one of template or template_file must be set"))
+	}
+
+	if m.Template != nil {
+		if err := m.Template.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("template: %w", err))
+		}
+	}
+
+	for i, base := range m.Axes.BaseImages {
+		if base.Image == "" {
+			errs = append(errs, fmt.Errorf("axes.base_images[%d]: image is required", i))
+		}
+	}
+
+	for i, set := range m.Axes.PackageSets {
+		if set.Name == "" {
+			errs = append(errs, fmt.Errorf("axes.package_sets[%d]: name is required", i))
+		}
+	}
+
+	for i, args := range m.Axes.BuildArgs {
+		if args.Name == "" {
+			errs = append(errs, fmt.Errorf("axes.build_args[%d]: name is required", i))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Produces every combination of the matrix axes in a deterministic order.
+// Base images vary slowest, followed by arches, package sets, and build args.
+func (m *Matrix) Variants() ([]*Variant, error) {
+	// Each empty axis contributes a single nil entry so that it is skipped
+	// without special-casing the loops below.
+	baseImages := axisOrNil(m.Axes.BaseImages)
+	arches := axisOrNil(m.Axes.Arches)
+	packageSets := axisOrNil(m.Axes.PackageSets)
+	buildArgs := axisOrNil(m.Axes.BuildArgs)
+
+	out := []*Variant{}
+	seen := map[string]bool{}
+
+	for _, base := range baseImages {
+		for _, arch := range arches {
+			for _, set := range packageSets {
+				for _, args := range buildArgs {
+					v, err := m.variant(base, arch, set, args)
+					if err != nil {
+						return nil, err
+					}
+
+					if seen[v.Name] {
+						return nil, fmt.Errorf("duplicate variant name %q, please give each axis entry a unique name", v.Name)
+					}
+
+					seen[v.Name] = true
+					out = append(out, v)
+				}
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// Writes each variant's Containerfile along with the index file into the
+// given directory.
+func (m *Matrix) Write(dir string) ([]*Variant, error) {
+	variants, err := m.Variants()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	for _, v := range variants {
+		cf, err := v.Spec.Containerfile()
+		if err != nil {
+			return nil, fmt.Errorf("variant %s: %w", v.Name, err)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, v.File), []byte(cf.String()), 0o644); err != nil {
+			return nil, err
+		}
+	}
+
+	index, err := json.MarshalIndent(&MatrixIndex{Name: m.Name, Variants: variants}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, MatrixIndexFile), append(index, '\n'), 0o644); err != nil {
+		return nil, err
+	}
+
+	return variants, nil
+}
+
+// Constructs a single variant. Any of the axis values may be nil.
+func (m *Matrix) variant(base *BaseImageAxis, arch *string, set *PackageSetAxis, args *BuildArgsAxis) (*Variant, error) {
+	s, err := m.Template.clone()
+	if err != nil {
+		return nil, err
+	}
+
+	nameParts := []string{m.Name}
+	v := &Variant{Spec: s}
+
+	if base != nil {
+		baseName := base.Name
+		if baseName == "" {
+			baseName = imageShortName(base.Image)
+		}
+
+		nameParts = append(nameParts, baseName)
+		v.BaseImage = base.Image
+		v.PackageManager = base.PackageManager
+	}
+
+	if arch != nil {
+		nameParts = append(nameParts, *arch)
+		v.Arch = *arch
+		v.Platform = "linux/" + *arch
+	}
+
+	if set != nil {
+		nameParts = append(nameParts, set.Name)
+		v.PackageSet = set.Name
+		v.Packages = set.Packages
+	}
+
+	if args != nil {
+		nameParts = append(nameParts, args.Name)
+		v.BuildArgsName = args.Name
+		v.BuildArgs = args.Args
+	}
+
+	v.Name = sanitizeName(strings.Join(nameParts, "-"))
+	v.File = "Containerfile." + v.Name
+
+	if s.Tag != "" {
+		repo, _, _ := strings.Cut(s.Tag, "@")
+		if colon := strings.LastIndex(repo, ":"); colon > strings.LastIndex(repo, "/") {
+			repo = repo[:colon]
+		}
+
+		v.Tag = repo + ":" + strings.TrimPrefix(v.Name, sanitizeName(m.Name)+"-")
+		s.Tag = v.Tag
+	}
+
+	if err := m.apply(v); err != nil {
+		return nil, fmt.Errorf("variant %s: %w", v.Name, err)
+	}
+
+	return v, nil
+}
+
+// Applies the base image and package set of the variant to its spec.
+func (m *Matrix) apply(v *Variant) error {
+	if v.BaseImage == "" && len(v.Packages) == 0 {
+		return nil
+	}
+
+	stageNames := map[string]bool{}
+	foundInstall := false
+
+	for i := range v.Spec.Stages {
+		stage := &v.Spec.Stages[i]
+
+		isDerived := stageNames[stage.Image]
+
+		if stage.Name != "" {
+			stageNames[stage.Name] = true
+		}
+
+		if len(m.BaseImageStages) != 0 {
+			if !slices.Contains(m.BaseImageStages, stage.Name) {
+				continue
+			}
+		} else if isDerived {
+			continue
+		}
+
+		if v.BaseImage != "" {
+			stage.Image = v.BaseImage
+		}
+
+		for _, step := range stage.Steps {
+			if step.Run == nil {
+				continue
+			}
+
+			for _, cmd := range step.Run.Commands {
+				if cmd.Install == nil {
+					continue
+				}
+
+				foundInstall = true
+
+				if v.BaseImage != "" {
+					cmd.Install.PackageManager = v.PackageManager
+				}
+
+				cmd.Install.Packages = append(cmd.Install.Packages, v.Packages...)
+			}
+		}
+	}
+
+	if len(v.Packages) != 0 && !foundInstall {
+		return fmt.Errorf("package set %q requires at least one install command in the template", v.PackageSet)
+	}
+
+	return nil
+}
+
+// Deep copies the spec so that each variant may be modified independently.
+func (s *Spec) clone() (*Spec, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &Spec{}
+	if err := json.Unmarshal(data, out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Returns a slice of pointers to each axis item, or a slice containing a
+// single nil pointer if the axis is empty.
+func axisOrNil[T any](items []T) []*T {
+	if len(items) == 0 {
+		return []*T{nil}
+	}
+
+	out := []*T{}
+	for i := range items {
+		out = append(out, &items[i])
+	}
+
+	return out
+}
+
+// Derives a short name from an image pullspec, e.g., stream-9 from
+// quay.io/centos/stream:9.
+func imageShortName(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+
+	name := path.Base(image)
+	return strings.ReplaceAll(name, ":", "-")
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// Ensures that a variant name is safe to use as a file name and image tag.
+func sanitizeName(name string) string {
+	return strings.Trim(unsafeNameChars.ReplaceAllString(strings.ToLower(name), "-"), "-.")
+}
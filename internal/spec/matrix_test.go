@@ -0,0 +1,165 @@
+package spec
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatrixVariants(t *testing.T) {
+	m, err := ParseMatrixFile("../../templatized-containerfile/matrix.yaml")
+	require.NoError(t, err)
+
+	m.Template.Tag = "quay.io/org/devtools:latest"
+	m.Axes.BaseImages = m.Axes.BaseImages[1:]
+	m.Axes.Arches = []string{"amd64", "arm64"}
+	m.Axes.PackageSets = []PackageSetAxis{{Name: "Debug Tools", Packages: []string{"gdb"}}}
+	m.Axes.BuildArgs = []BuildArgsAxis{{Name: "release", Args: map[string]string{"RELEASE": "1"}}}
+
+	variants, err := m.Variants()
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, v := range variants {
+		names = append(names, v.Name)
+	}
+
+	assert.Equal(t, []string{
+		"devtools-fedora-amd64-debug-tools-release",
+		"devtools-fedora-arm64-debug-tools-release",
+		"devtools-ubuntu-amd64-debug-tools-release",
+		"devtools-ubuntu-arm64-debug-tools-release",
+	}, names)
+
+	last := variants[len(variants)-1]
+	assert.Equal(t, "quay.io/org/devtools:ubuntu-arm64-debug-tools-release", last.Tag)
+	assert.Equal(t, "linux/arm64", last.Platform)
+	assert.Equal(t, map[string]string{"RELEASE": "1"}, last.BuildArgs)
+
+	cf, err := last.Spec.Containerfile()
+	require.NoError(t, err)
+	assert.Equal(t, `FROM ubuntu:latest
+RUN apt-get update && apt-get install -y nvim git golang gdb && useradd zack
+USER zack
+
+`, cf.String())
+
+	// The template itself must not be modified.
+	assert.Equal(t, []string{"nvim", "git", "golang"}, m.Template.Stages[0].Steps[0].Run.Commands[0].Install.Packages)
+}
+
+func TestMatrixWrite(t *testing.T) {
+	m, err := ParseMatrixFile("../../templatized-containerfile/matrix.yaml")
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+
+	variants, err := m.Write(dir)
+	require.NoError(t, err)
+	assert.Len(t, variants, 3)
+
+	centos, err := os.ReadFile(filepath.Join(dir, "Containerfile.devtools-centos"))
+	require.NoError(t, err)
+	assert.Equal(t, "FROM quay.io/centos/stream:9\nRUN yum install -y nvim git golang && useradd zack\nUSER zack\n\n", string(centos))
+
+	indexBytes, err := os.ReadFile(filepath.Join(dir, MatrixIndexFile))
+	require.NoError(t, err)
+
+	index := &MatrixIndex{}
+	require.NoError(t, json.Unmarshal(indexBytes, index))
+	assert.Equal(t, "devtools", index.Name)
+	assert.Len(t, index.Variants, 3)
+	assert.Equal(t, "Containerfile.devtools-ubuntu", index.Variants[2].File)
+}
+
+func TestMatrixTemplateFile(t *testing.T) {
+	testCases := []struct {
+		name     string
+		matrix   string
+		errorIs  string
+		expected []string
+	}{
+		{
+			name: "Template file",
+			matrix: `version: v1
+name: golang
+template_file: golang.yaml
+axes:
+  arches: [amd64, arm64]
+`,
+			expected: []string{"golang-amd64", "golang-arm64"},
+		},
+		{
+			name: "Both template and template file",
+			matrix: `version: v1
+name: golang
+template_file: golang.yaml
+template:
+  version: v1
+  stages:
+    - image: fedora
+`,
+			errorIs: "template: exactly one of template or template_file must be set",
+		},
+		{
+			name:    "Neither template nor template file",
+			matrix:  "version: v1\nname: golang\n",
+			errorIs: "template: exactly one of template or template_file must be set",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			template, err := os.ReadFile("testdata/golang.yaml")
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "golang.yaml"), template, 0o644))
+
+			matrixPath := filepath.Join(dir, "matrix.yaml")
+			require.NoError(t, os.WriteFile(matrixPath, []byte(testCase.matrix), 0o644))
+
+			m, err := ParseMatrixFile(matrixPath)
+			if testCase.errorIs != "" {
+				assert.ErrorContains(t, err, testCase.errorIs)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "quay.io/zzlotnik/golang:latest", m.Template.Tag)
+
+			variants, err := m.Variants()
+			require.NoError(t, err)
+
+			names := []string{}
+			for _, v := range variants {
+				names = append(names, v.Name)
+			}
+
+			assert.Equal(t, testCase.expected, names)
+		})
+	}
+}
+
+func TestMatrixDuplicateNames(t *testing.T) {
+	m := &Matrix{
+		Version:  CurrentVersion,
+		Name:     "dup",
+		Template: &Spec{Version: CurrentVersion, Stages: []StageSpec{{Image: "fedora"}}},
+		Axes: MatrixAxes{
+			BaseImages: []BaseImageAxis{
+				{Image: "quay.io/a/fedora:latest"},
+				{Image: "quay.io/b/fedora:latest"},
+			},
+		},
+	}
+
+	require.NoError(t, m.Validate())
+
+	_, err := m.Variants()
+	assert.ErrorContains(t, err, `duplicate variant name "dup-fedora-latest"`)
+}
@@ -0,0 +1,217 @@
+package spec
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The current version of the spec format.
+const CurrentVersion = "v1"
+
+// A declarative description of a Containerfile which can be authored in YAML
+// or JSON without writing any Go code.
+type Spec struct {
+	Version string      `json:"version" yaml:"version"`
+	Tag     string      `json:"tag,omitempty" yaml:"tag,omitempty"`
+	Stages  []StageSpec `json:"stages" yaml:"stages"`
+}
+
+// Describes a single stage. The image may refer to a previous stage by name.
+type StageSpec struct {
+	Name  string     `json:"name,omitempty" yaml:"name,omitempty"`
+	Image string     `json:"image" yaml:"image"`
+	Steps []StepSpec `json:"steps,omitempty" yaml:"steps,omitempty"`
+}
+
+// Describes a single step within a stage. Exactly one field must be set.
+type StepSpec struct {
+	Run     *RunSpec   `json:"run,omitempty" yaml:"run,omitempty"`
+	Copy    *CopySpec  `json:"copy,omitempty" yaml:"copy,omitempty"`
+	Label   *LabelSpec `json:"label,omitempty" yaml:"label,omitempty"`
+	Workdir string     `json:"workdir,omitempty" yaml:"workdir,omitempty"`
+	User    string     `json:"user,omitempty" yaml:"user,omitempty"`
+}
+
+// Describes a RUN step. Either Shell or Commands must be set, but not both.
+type RunSpec struct {
+	Flags  []string    `json:"flags,omitempty" yaml:"flags,omitempty"`
+	Mounts []MountSpec `json:"mounts,omitempty" yaml:"mounts,omitempty"`
+	// A literal shell string to run.
+	Shell string `json:"shell,omitempty" yaml:"shell,omitempty"`
+	// Typed commands which are chained together with &&.
+	Commands []CommandSpec `json:"commands,omitempty" yaml:"commands,omitempty"`
+}
+
+// Describes a --mount option for a RUN step.
+type MountSpec struct {
+	Type            string `json:"type" yaml:"type"`
+	ID              string `json:"id,omitempty" yaml:"id,omitempty"`
+	From            string `json:"from,omitempty" yaml:"from,omitempty"`
+	Source          string `json:"source,omitempty" yaml:"source,omitempty"`
+	Target          string `json:"target,omitempty" yaml:"target,omitempty"`
+	BindPropagation string `json:"bind_propagation,omitempty" yaml:"bind_propagation,omitempty"`
+	Opts            string `json:"opts,omitempty" yaml:"opts,omitempty"`
+}
+
+// Describes a single typed command. Exactly one field must be set.
+type CommandSpec struct {
+	Argv    []string     `json:"argv,omitempty" yaml:"argv,omitempty"`
+	Install *InstallSpec `json:"install,omitempty" yaml:"install,omitempty"`
+	Useradd *UseraddSpec `json:"useradd,omitempty" yaml:"useradd,omitempty"`
+	Chmod   *ChmodSpec   `json:"chmod,omitempty" yaml:"chmod,omitempty"`
+	Delete  *DeleteSpec  `json:"delete,omitempty" yaml:"delete,omitempty"`
+}
+
+// Describes a package installation. If PackageManager is empty, it is
+// detected from the stage's base image.
+type InstallSpec struct {
+	PackageManager string   `json:"package_manager,omitempty" yaml:"package_manager,omitempty"`
+	Packages       []string `json:"packages" yaml:"packages"`
+}
+
+// Describes a useradd command.
+type UseraddSpec struct {
+	Name       string   `json:"name" yaml:"name"`
+	UID        string   `json:"uid,omitempty" yaml:"uid,omitempty"`
+	Groups     []string `json:"groups,omitempty" yaml:"groups,omitempty"`
+	Shell      string   `json:"shell,omitempty" yaml:"shell,omitempty"`
+	CreateHome bool     `json:"create_home,omitempty" yaml:"create_home,omitempty"`
+}
+
+// Describes a chmod command.
+type ChmodSpec struct {
+	Path      string `json:"path" yaml:"path"`
+	Mode      string `json:"mode" yaml:"mode"`
+	Recursive bool   `json:"recursive,omitempty" yaml:"recursive,omitempty"`
+}
+
+// Describes an rm command.
+type DeleteSpec struct {
+	Path      string `json:"path" yaml:"path"`
+	Recursive bool   `json:"recursive,omitempty" yaml:"recursive,omitempty"`
+	Force     bool   `json:"force,omitempty" yaml:"force,omitempty"`
+}
+
+// Describes a COPY step.
+type CopySpec struct {
+	From  string   `json:"from,omitempty" yaml:"from,omitempty"`
+	Src   string   `json:"src" yaml:"src"`
+	Dest  string   `json:"dest" yaml:"dest"`
+	Flags []string `json:"flags,omitempty" yaml:"flags,omitempty"`
+}
+
+// Describes a LABEL step.
+type LabelSpec struct {
+	Key   string `json:"key" yaml:"key"`
+	Value string `json:"value" yaml:"value"`
+}
+
+// The unversioned, single-stage format used by the gomplate datasources in
+// the templatized-containerfile directory.
+type SimpleSpec struct {
+	BaseImage      string   `json:"base_image" yaml:"base_image"`
+	PackageManager string   `json:"package_manager,omitempty" yaml:"package_manager,omitempty"`
+	Username       string   `json:"username,omitempty" yaml:"username,omitempty"`
+	Packages       []string `json:"packages,omitempty" yaml:"packages,omitempty"`
+}
+
+// Converts the simple format into the equivalent versioned spec.
+func (s *SimpleSpec) Spec() *Spec {
+	cmds := []CommandSpec{}
+
+	if len(s.Packages) != 0 {
+		cmds = append(cmds, CommandSpec{
+			Install: &InstallSpec{
+				PackageManager: s.PackageManager,
+				Packages:       s.Packages,
+			},
+		})
+	}
+
+	if s.Username != "" {
+		cmds = append(cmds, CommandSpec{
+			Useradd: &UseraddSpec{Name: s.Username},
+		})
+	}
+
+	steps := []StepSpec{}
+
+	if len(cmds) != 0 {
+		steps = append(steps, StepSpec{Run: &RunSpec{Commands: cmds}})
+	}
+
+	if s.Username != "" {
+		steps = append(steps, StepSpec{User: s.Username})
+	}
+
+	return &Spec{
+		Version: CurrentVersion,
+		Stages: []StageSpec{
+			{
+				Image: s.BaseImage,
+				Steps: steps,
+			},
+		},
+	}
+}
+
+// Reads and parses the spec at the given path.
+func ParseFile(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+// Parses and validates a YAML or JSON spec. Unversioned documents with a
+// base_image key are treated as the simple format. Unknown fields are
+// rejected.
+func Parse(data []byte) (*Spec, error) {
+	keys := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &keys); err != nil {
+		return nil, err
+	}
+
+	_, hasVersion := keys["version"]
+	_, hasBaseImage := keys["base_image"]
+
+	var s *Spec
+
+	if !hasVersion && hasBaseImage {
+		simple := &SimpleSpec{}
+		if err := decodeStrict(data, simple); err != nil {
+			return nil, err
+		}
+
+		s = simple.Spec()
+	} else {
+		s = &Spec{}
+		if err := decodeStrict(data, s); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Decodes the given YAML or JSON document, rejecting unknown fields. Since
+// JSON is a subset of YAML, the YAML decoder handles both.
+func decodeStrict(data []byte, out interface{}) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	return dec.Decode(out)
+}
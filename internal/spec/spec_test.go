@@ -0,0 +1,113 @@
+package spec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFile(t *testing.T) {
+	expectedGolang := `FROM registry.fedoraproject.org/fedora:latest AS builder
+WORKDIR /go/src/github.com/cheesesashimi/zacks-openshift-helpers
+RUN dnf install -y golang
+COPY . .
+RUN make all
+
+FROM registry.fedoraproject.org/fedora:latest AS final
+COPY --from=builder /go/src/github.com/cheesesashimi/zacks-openshift-helpers/_output /usr/local/bin/
+
+`
+
+	testCases := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{
+			name:     "YAML",
+			path:     "testdata/golang.yaml",
+			expected: expectedGolang,
+		},
+		{
+			name:     "JSON",
+			path:     "testdata/golang.json",
+			expected: expectedGolang,
+		},
+		{
+			name: "Simple gomplate datasource",
+			path: "../../templatized-containerfile/ubuntu.yaml",
+			expected: `FROM ubuntu:latest
+RUN apt-get update && apt-get install -y nvim git golang && useradd zack
+USER zack
+
+`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			s, err := ParseFile(testCase.path)
+			require.NoError(t, err)
+
+			cf, err := s.Containerfile()
+			require.NoError(t, err)
+
+			assert.Equal(t, testCase.expected, cf.String())
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	testCases := []struct {
+		name        string
+		input       string
+		errContains []string
+	}{
+		{
+			name:        "Unsupported version",
+			input:       "version: v2\nstages: [{image: fedora}]",
+			errContains: []string{`version: unsupported version "v2"`},
+		},
+		{
+			name:        "Unknown field",
+			input:       "version: v1\nstages: [{image: fedora, from: other}]",
+			errContains: []string{"field from not found"},
+		},
+		{
+			name: "Multiple problems",
+			input: `version: v1
+stages:
+  - steps:
+      - user: zack
+        workdir: /home/zack
+      - run:
+          commands:
+            - install:
+                package_manager: pacman
+                packages: [git]
+`,
+			errContains: []string{
+				"stages[0]: image is required",
+				"stages[0].steps[0]: exactly one of run, copy, label, workdir, or user must be set, got 2",
+				`stages[0].steps[1].run.commands[0].install: unknown package manager "pacman"`,
+			},
+		},
+		{
+			name:        "No stages",
+			input:       `{"version": "v1"}`,
+			errContains: []string{"stages: at least one stage is required"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := Parse([]byte(testCase.input))
+			require.Error(t, err)
+
+			for _, errContains := range testCase.errContains {
+				assert.ErrorContains(t, err, errContains)
+			}
+		})
+	}
+}
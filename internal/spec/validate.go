@@ -0,0 +1,134 @@
+package spec
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cheesesashimi/zacks-container-playground/internal/command"
+)
+
+// Validates the spec against the schema, returning every problem found.
+func (s *Spec) Validate() error {
+	errs := []error{}
+
+	addErr := func(path, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+	}
+
+	if s.Version != CurrentVersion {
+		addErr("version", "unsupported version %q, expected %q", s.Version, CurrentVersion)
+	}
+
+	if len(s.Stages) == 0 {
+		addErr("stages", "at least one stage is required")
+	}
+
+	stageNames := map[string]bool{}
+
+	for i, stage := range s.Stages {
+		stagePath := fmt.Sprintf("stages[%d]", i)
+
+		if stage.Image == "" {
+			addErr(stagePath, "image is required")
+		}
+
+		if stage.Name != "" {
+			if stageNames[stage.Name] {
+				addErr(stagePath, "duplicate stage name %q", stage.Name)
+			}
+
+			stageNames[stage.Name] = true
+		}
+
+		for j, step := range stage.Steps {
+			stepPath := fmt.Sprintf("%s.steps[%d]", stagePath, j)
+
+			if n := countSet(step.Run != nil, step.Copy != nil, step.Label != nil, step.Workdir != "", step.User != ""); n != 1 {
+				addErr(stepPath, "exactly one of run, copy, label, workdir, or user must be set, got %d", n)
+				continue
+			}
+
+			if step.Copy != nil {
+				if step.Copy.Src == "" || step.Copy.Dest == "" {
+					addErr(stepPath+".copy", "src and dest are required")
+				}
+
+				// Copying from an external image is allowed, so only
+				// self-references are an error.
+				if stage.Name != "" && step.Copy.From == stage.Name {
+					addErr(stepPath+".copy", "cannot copy from the current stage")
+				}
+			}
+
+			if step.Label != nil && step.Label.Key == "" {
+				addErr(stepPath+".label", "key is required")
+			}
+
+			if step.Run != nil {
+				validateRun(stepPath+".run", step.Run, addErr)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validates a single RUN step.
+func validateRun(path string, run *RunSpec, addErr func(string, string, ...interface{})) {
+	if n := countSet(run.Shell != "", len(run.Commands) != 0); n != 1 {
+		addErr(path, "exactly one of shell or commands must be set")
+	}
+
+	for i, mount := range run.Mounts {
+		if mount.Type == "" {
+			addErr(fmt.Sprintf("%s.mounts[%d]", path, i), "type is required")
+		}
+	}
+
+	for i, cmd := range run.Commands {
+		cmdPath := fmt.Sprintf("%s.commands[%d]", path, i)
+
+		if n := countSet(len(cmd.Argv) != 0, cmd.Install != nil, cmd.Useradd != nil, cmd.Chmod != nil, cmd.Delete != nil); n != 1 {
+			addErr(cmdPath, "exactly one of argv, install, useradd, chmod, or delete must be set, got %d", n)
+			continue
+		}
+
+		switch {
+		case cmd.Install != nil:
+			if len(cmd.Install.Packages) == 0 {
+				addErr(cmdPath+".install", "at least one package is required")
+			}
+
+			if name := cmd.Install.PackageManager; name != "" {
+				if _, ok := command.PackageManagerByName(name); !ok {
+					addErr(cmdPath+".install", "unknown package manager %q", name)
+				}
+			}
+		case cmd.Useradd != nil:
+			if cmd.Useradd.Name == "" {
+				addErr(cmdPath+".useradd", "name is required")
+			}
+		case cmd.Chmod != nil:
+			if cmd.Chmod.Path == "" || cmd.Chmod.Mode == "" {
+				addErr(cmdPath+".chmod", "path and mode are required")
+			}
+		case cmd.Delete != nil:
+			if cmd.Delete.Path == "" {
+				addErr(cmdPath+".delete", "path is required")
+			}
+		}
+	}
+}
+
+// Counts how many of the given conditions are true.
+func countSet(conds ...bool) int {
+	n := 0
+
+	for _, cond := range conds {
+		if cond {
+			n++
+		}
+	}
+
+	return n
+}